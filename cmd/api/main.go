@@ -8,9 +8,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"plans-features/internal/config"
 	"plans-features/internal/db"
@@ -45,18 +51,38 @@ func main() {
 		log.Fatalf("Migration failed: %v", err)
 	}
 
+	// ctx se cancela con SIGINT/SIGTERM: para los jobs y el servidor
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 3. Crear router con DB inyectada
-	r := router.NewRouter(dbConn) // ← PASAR DB AQUÍ
+	r := router.NewRouter(dbConn, cfg) // ← PASAR DB Y CONFIG AQUÍ
+
+	// 4. Jobs en segundo plano
+	waitJobs := router.StartJobs(ctx, dbConn, cfg)
 
-	// 4. Root router (sin cambios)
+	// 5. Root router (sin cambios)
 	root := chi.NewRouter()
 	root.Get("/swagger/*", httpSwagger.WrapHandler)
 	root.Mount("/", r)
 
+	srv := &http.Server{Addr: ":8080", Handler: root}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("server shutdown error: %v", err)
+		}
+	}()
+
 	fmt.Println("Server running on :8080 with PostgreSQL")
-	if err := http.ListenAndServe(":8080", root); err != nil {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("server error: %v", err)
 	}
+	// la BD se cierra (defer) después de que paren los jobs
+	stop()
+	waitJobs()
 }
 
 func runMigrations(dbURL string) error {
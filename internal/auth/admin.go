@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// AdminValidator resuelve un admin token en bruto a su ID
+type AdminValidator interface {
+	ValidateAdminToken(ctx context.Context, rawToken string) (uuid.UUID, error)
+}
+
+// Admin protege el subárbol /admin con X-Admin-Token o Authorization: Bearer.
+// Las API keys de proyecto no sirven aquí.
+func Admin(validator AdminValidator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get("X-Admin-Token")
+			if token == "" {
+				token = extractKey(r)
+			}
+			if token == "" {
+				utils.Error(w, http.StatusUnauthorized, "missing admin token")
+				return
+			}
+			adminID, err := validator.ValidateAdminToken(r.Context(), token)
+			if err != nil {
+				utils.Error(w, http.StatusUnauthorized, "invalid admin token")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithAdmin(r.Context(), adminID)))
+		})
+	}
+}
+
+// AdminProject da a un admin autenticado acceso completo al proyecto del path,
+// para reutilizar los handlers de /api bajo /admin/projects/{projectId}.
+func AdminProject(param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := AdminIDFromContext(r.Context()); !ok {
+				utils.Error(w, http.StatusUnauthorized, "missing admin token")
+				return
+			}
+			projectID, err := uuid.Parse(chi.URLParam(r, param))
+			if err != nil {
+				utils.Error(w, http.StatusBadRequest, "invalid project ID format")
+				return
+			}
+			p := &Principal{ProjectID: projectID, Scopes: AllScopes, Source: SourceAdmin}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIPResolver obtiene la IP del cliente. X-Forwarded-For solo se tiene en
+// cuenta si la conexión llega desde un proxy de confianza; si no, cualquiera
+// podría falsear su IP con la cabecera.
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver recibe las redes de los balanceadores (CIDR o IP suelta)
+func NewClientIPResolver(trustedProxies []string) (*ClientIPResolver, error) {
+	trusted, err := ParseCIDRs(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	return &ClientIPResolver{trusted: trusted}, nil
+}
+
+// ClientIP recorre X-Forwarded-For de derecha a izquierda saltando proxies de
+// confianza; la primera IP que no lo es corresponde al cliente. Con un
+// resolver nil solo se usa RemoteAddr.
+func (c *ClientIPResolver) ClientIP(r *http.Request) (netip.Addr, bool) {
+	remote, ok := parseAddr(r.RemoteAddr)
+	if !ok || c == nil || !c.isTrusted(remote) {
+		return remote, ok
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, ok := parseAddr(strings.TrimSpace(hops[i]))
+		if !ok {
+			break
+		}
+		client = ip
+		if !c.isTrusted(ip) {
+			break
+		}
+	}
+	return client, true
+}
+
+func (c *ClientIPResolver) isTrusted(ip netip.Addr) bool {
+	return containsAddr(c.trusted, ip)
+}
+
+// ParseCIDRs valida una lista de redes; una IP suelta equivale a /32 o /128
+func ParseCIDRs(cidrs []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(cidrs))
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cidr: %s", s)
+			}
+			out = append(out, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr: %s", s)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+// IPAllowed indica si ip pertenece a alguna de las redes; una lista vacía no restringe
+func IPAllowed(ip netip.Addr, cidrs []string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	prefixes, err := ParseCIDRs(cidrs)
+	if err != nil {
+		// una allowlist corrupta no debe abrir el acceso
+		return false
+	}
+	return containsAddr(prefixes, ip)
+}
+
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr acepta "ip" o "ip:puerto" (RemoteAddr)
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("NewClientIPResolver: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"no header", "203.0.113.7:4000", nil, "203.0.113.7"},
+		{"untrusted peer ignores header", "203.0.113.7:4000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted peer uses last hop", "10.0.0.5:4000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"trusted peer without header", "10.0.0.5:4000", nil, "10.0.0.5"},
+		{"skips trusted hops right to left", "10.0.0.5:4000", []string{"198.51.100.1, 10.1.2.3, 192.168.1.1"}, "198.51.100.1"},
+		{"spoofed leading entries", "10.0.0.5:4000", []string{"1.2.3.4, 5.6.7.8, 198.51.100.1, 10.1.2.3"}, "198.51.100.1"},
+		{"multiple header lines", "10.0.0.5:4000", []string{"1.2.3.4, 198.51.100.1", "10.1.2.3"}, "198.51.100.1"},
+		{"all hops trusted", "10.0.0.5:4000", []string{"10.9.9.9, 10.1.2.3"}, "10.9.9.9"},
+		{"garbage hop stops the walk", "10.0.0.5:4000", []string{"1.2.3.4, not-an-ip, 10.1.2.3"}, "10.1.2.3"},
+		{"garbage last hop keeps peer", "10.0.0.5:4000", []string{"1.2.3.4, bogus"}, "10.0.0.5"},
+		{"single trusted ip", "192.168.1.1:80", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"neighbour of single trusted ip", "192.168.1.2:80", []string{"198.51.100.1"}, "192.168.1.2"},
+		{"ipv6 trusted peer", "[fd00::1]:443", []string{"2001:db8::7"}, "2001:db8::7"},
+		{"ipv4-mapped peer", "[::ffff:10.0.0.5]:443", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"ipv4-mapped hop", "10.0.0.5:4000", []string{"::ffff:198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/plans", nil)
+			r.RemoteAddr = tt.remote
+			for _, h := range tt.xff {
+				r.Header.Add("X-Forwarded-For", h)
+			}
+			got, ok := resolver.ClientIP(r)
+			if !ok {
+				t.Fatalf("ClientIP not resolved")
+			}
+			if want := netip.MustParseAddr(tt.want); got != want {
+				t.Errorf("ClientIP = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestClientIPNilResolver(t *testing.T) {
+	var resolver *ClientIPResolver
+	r := httptest.NewRequest("GET", "/api/plans", nil)
+	r.RemoteAddr = "10.0.0.5:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	got, ok := resolver.ClientIP(r)
+	if !ok || got != netip.MustParseAddr("10.0.0.5") {
+		t.Errorf("ClientIP = %s, %v; want RemoteAddr", got, ok)
+	}
+
+	r.RemoteAddr = "garbage"
+	if _, ok := resolver.ClientIP(r); ok {
+		t.Errorf("ClientIP resolved an invalid RemoteAddr")
+	}
+}
+
+func TestNewClientIPResolverInvalid(t *testing.T) {
+	if _, err := NewClientIPResolver([]string{"10.0.0.0/8", "10.0.0.0/99"}); err == nil {
+		t.Errorf("NewClientIPResolver accepted an invalid cidr")
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		ip    string
+		cidrs []string
+		want  bool
+	}{
+		{"empty list allows all", "203.0.113.7", nil, true},
+		{"inside cidr", "203.0.113.7", []string{"203.0.113.0/24"}, true},
+		{"outside cidr", "203.0.114.7", []string{"203.0.113.0/24"}, false},
+		{"unmasked cidr", "203.0.113.7", []string{"203.0.113.99/24"}, true},
+		{"single ip", "198.51.100.1", []string{"198.51.100.1"}, true},
+		{"single ip neighbour", "198.51.100.2", []string{"198.51.100.1"}, false},
+		{"any of several", "10.1.2.3", []string{"203.0.113.0/24", "10.0.0.0/8"}, true},
+		{"ipv4-mapped address", "::ffff:10.1.2.3", []string{"10.0.0.0/8"}, true},
+		{"ipv6 inside", "2001:db8::1", []string{"2001:db8::/32"}, true},
+		{"ipv6 outside", "2001:db9::1", []string{"2001:db8::/32"}, false},
+		{"ipv4 against ipv6 list", "10.1.2.3", []string{"2001:db8::/32"}, false},
+		{"blank entries ignored", "10.1.2.3", []string{" ", "10.0.0.0/8"}, true},
+		{"corrupt list denies", "10.1.2.3", []string{"10.0.0.0/8", "nope"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IPAllowed(netip.MustParseAddr(tt.ip), tt.cidrs); got != tt.want {
+				t.Errorf("IPAllowed(%s, %v) = %v, want %v", tt.ip, tt.cidrs, got, tt.want)
+			}
+		})
+	}
+}
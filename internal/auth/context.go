@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// ctxKey evita colisiones con otras claves de context (nunca usar strings sueltos)
+type ctxKey int
+
+const (
+	principalKey ctxKey = iota
+	adminKey
+)
+
+// Origen de la credencial del principal
+const (
+	SourceAPIKey = "api_key"
+	SourceToken  = "token"
+	SourceAdmin  = "admin"
+)
+
+// Principal representa al llamador autenticado de /api
+type Principal struct {
+	ProjectID uuid.UUID
+	KeyID     uuid.UUID
+	Scopes    []string
+	Source    string
+	// AllowedCIDRs restringe desde qué redes se puede usar la credencial (vacío = cualquiera)
+	AllowedCIDRs []string
+}
+
+// HasScope indica si el principal tiene el scope indicado
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPrincipal guarda el principal autenticado en el context
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext devuelve el principal guardado por el middleware
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	if !ok || p == nil {
+		return nil, false
+	}
+	return p, true
+}
+
+// ProjectIDFromContext devuelve el project_id derivado de la API key
+func ProjectIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || p.ProjectID == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return p.ProjectID, true
+}
+
+// WithAdmin guarda el ID del admin token autenticado en el context
+func WithAdmin(ctx context.Context, adminID uuid.UUID) context.Context {
+	return context.WithValue(ctx, adminKey, adminID)
+}
+
+// AdminIDFromContext devuelve el ID del admin token autenticado
+func AdminIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(adminKey).(uuid.UUID)
+	if !ok || id == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// ActorSystem identifica los cambios hechos por jobs en segundo plano
+const ActorSystem = "system"
+
+// ActorFromContext describe quién hace la petición para los registros de
+// auditoría: "admin:<token id>", "<source>:<key id>" o ActorSystem
+func ActorFromContext(ctx context.Context) string {
+	if id, ok := AdminIDFromContext(ctx); ok {
+		return SourceAdmin + ":" + id.String()
+	}
+	if p, ok := PrincipalFromContext(ctx); ok && p.KeyID != uuid.Nil {
+		return p.Source + ":" + p.KeyID.String()
+	}
+	return ActorSystem
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"plans-features/internal/utils"
+)
+
+// TokenRequest permite pedir un subconjunto de los scopes de la API key
+type TokenRequest struct {
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+type TokenResponse struct {
+	AccessToken string   `json:"access_token"`
+	TokenType   string   `json:"token_type"`
+	ExpiresIn   int64    `json:"expires_in"`
+	Scopes      []string `json:"scopes"`
+}
+
+type TokenHandler struct {
+	issuer *TokenIssuer
+}
+
+func NewTokenHandler(issuer *TokenIssuer) *TokenHandler {
+	return &TokenHandler{issuer: issuer}
+}
+
+// IssueToken godoc
+// @Summary Exchange an API key for an access token
+// @Description Returns a short-lived signed token (JWT) carrying the project and scopes of the API key. Use it as Authorization: Bearer on /api to skip the API key lookup.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param body body auth.TokenRequest false "Optional scope subset"
+// @Success 200 {object} auth.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/token [post]
+func (h *TokenHandler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	if h.issuer == nil {
+		utils.Error(w, http.StatusServiceUnavailable, "token exchange not configured")
+		return
+	}
+	p, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+	// solo una API key puede obtener tokens (un token no se renueva a sí mismo)
+	if p.Source != SourceAPIKey {
+		utils.Error(w, http.StatusForbidden, "an api key is required to issue tokens")
+		return
+	}
+
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	scopes := p.Scopes
+	if len(req.Scopes) > 0 {
+		for _, sc := range req.Scopes {
+			if !p.HasScope(sc) {
+				utils.Error(w, http.StatusForbidden, "api key lacks scope "+sc)
+				return
+			}
+		}
+		scopes = req.Scopes
+	}
+
+	token, _, err := h.issuer.Issue(&Principal{
+		ProjectID:    p.ProjectID,
+		KeyID:        p.KeyID,
+		Scopes:       scopes,
+		AllowedCIDRs: p.AllowedCIDRs,
+	})
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	utils.JSON(w, http.StatusOK, TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(h.issuer.TTL().Seconds()),
+		Scopes:      scopes,
+	})
+}
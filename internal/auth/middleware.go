@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"plans-features/internal/utils"
+)
+
+// KeyValidator resuelve una API key en bruto a su principal
+type KeyValidator interface {
+	ValidateKey(ctx context.Context, rawKey string) (*Principal, error)
+}
+
+// APIKey autentica la petición con X-API-Key o Authorization: Bearer
+// y guarda el principal en el context. Si tokens no es nil, un Bearer con
+// forma de JWT se verifica sin consultar la BD. Si la key tiene allowlist de
+// redes, la IP del cliente (resuelta con ips) debe estar dentro.
+func APIKey(validator KeyValidator, tokens *TokenIssuer, ips *ClientIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := extractKey(r)
+			if key == "" {
+				utils.Error(w, http.StatusUnauthorized, "missing api key")
+				return
+			}
+			var (
+				p   *Principal
+				err error
+			)
+			if tokens != nil && looksLikeToken(key) {
+				p, err = tokens.Verify(key)
+				if err != nil {
+					utils.Error(w, http.StatusUnauthorized, "invalid access token")
+					return
+				}
+			} else {
+				p, err = validator.ValidateKey(r.Context(), key)
+				if err != nil {
+					utils.Error(w, http.StatusUnauthorized, "invalid api key")
+					return
+				}
+			}
+			if len(p.AllowedCIDRs) > 0 {
+				ip, ok := ips.ClientIP(r)
+				if !ok || !IPAllowed(ip, p.AllowedCIDRs) {
+					utils.Error(w, http.StatusForbidden, "client ip not allowed for this api key")
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// RequireScope rechaza con 403 si el principal no tiene el scope indicado
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				utils.Error(w, http.StatusUnauthorized, "missing project context")
+				return
+			}
+			if !p.HasScope(scope) {
+				utils.Error(w, http.StatusForbidden, "api key lacks scope "+scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func extractKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return ""
+}
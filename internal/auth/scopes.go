@@ -0,0 +1,29 @@
+package auth
+
+// Scopes que puede llevar una API key
+const (
+	ScopeEntitlementsRead = "entitlements:read"
+	ScopeCatalogRead      = "catalog:read"
+	ScopeCatalogWrite     = "catalog:write"
+	ScopeTenantsWrite     = "tenants:write"
+	ScopeUsageWrite       = "usage:write"
+)
+
+// AllScopes es el conjunto completo; se asigna a las keys creadas sin scopes explícitos
+var AllScopes = []string{
+	ScopeEntitlementsRead,
+	ScopeCatalogRead,
+	ScopeCatalogWrite,
+	ScopeTenantsWrite,
+	ScopeUsageWrite,
+}
+
+// IsValidScope indica si el scope es uno de los soportados
+func IsValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"plans-features/internal/config"
+
+	"github.com/google/uuid"
+)
+
+// Algoritmos JWT soportados
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// clockSkew tolera pequeñas diferencias de reloj entre réplicas
+const clockSkew = 30 * time.Second
+
+type signingKey struct {
+	id         string
+	alg        string
+	secret     []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+func (k *signingKey) canSign() bool {
+	return len(k.secret) > 0 || k.privateKey != nil
+}
+
+// TokenIssuer firma y verifica access tokens (JWT) sin consultar la BD
+type TokenIssuer struct {
+	keys   []signingKey
+	ttl    time.Duration
+	issuer string
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type tokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"` // API key ID
+	ProjectID string   `json:"pid"`
+	Scopes    []string `json:"scp"`
+	CIDRs     []string `json:"cidrs,omitempty"` // allowlist de la key
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// NewTokenIssuer carga las claves de firma; devuelve nil si no hay ninguna
+// configurada (el intercambio de tokens queda deshabilitado).
+func NewTokenIssuer(cfg config.Tokens) (*TokenIssuer, error) {
+	if len(cfg.SigningKeys) == 0 {
+		return nil, nil
+	}
+	t := &TokenIssuer{ttl: cfg.TTL, issuer: cfg.Issuer}
+	for i, k := range cfg.SigningKeys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("signing key %d: id is required", i)
+		}
+		sk := signingKey{id: k.ID, alg: k.Algorithm}
+		switch k.Algorithm {
+		case AlgHS256:
+			secret, err := base64.StdEncoding.DecodeString(k.Secret)
+			if err != nil || len(secret) < 32 {
+				return nil, fmt.Errorf("signing key %s: HS256 secret must be base64 with at least 32 bytes", k.ID)
+			}
+			sk.secret = secret
+		case AlgEdDSA:
+			if k.Secret != "" {
+				seed, err := base64.StdEncoding.DecodeString(k.Secret)
+				if err != nil || len(seed) != ed25519.SeedSize {
+					return nil, fmt.Errorf("signing key %s: EdDSA secret must be a base64 32-byte seed", k.ID)
+				}
+				sk.privateKey = ed25519.NewKeyFromSeed(seed)
+				sk.publicKey = sk.privateKey.Public().(ed25519.PublicKey)
+			} else {
+				pub, err := base64.StdEncoding.DecodeString(k.PublicKey)
+				if err != nil || len(pub) != ed25519.PublicKeySize {
+					return nil, fmt.Errorf("signing key %s: EdDSA public_key must be base64 with 32 bytes", k.ID)
+				}
+				sk.publicKey = pub
+			}
+		default:
+			return nil, fmt.Errorf("signing key %s: unsupported algorithm %q", k.ID, k.Algorithm)
+		}
+		t.keys = append(t.keys, sk)
+	}
+	if !t.keys[0].canSign() {
+		return nil, fmt.Errorf("signing key %s: the first key must include its secret", t.keys[0].id)
+	}
+	return t, nil
+}
+
+// TTL devuelve la vida de los tokens emitidos
+func (t *TokenIssuer) TTL() time.Duration {
+	return t.ttl
+}
+
+// Issue firma un token para el principal con la clave activa (la primera)
+func (t *TokenIssuer) Issue(p *Principal) (string, time.Time, error) {
+	key := &t.keys[0]
+	now := time.Now()
+	expiresAt := now.Add(t.ttl)
+
+	header, err := json.Marshal(tokenHeader{Alg: key.alg, Typ: "JWT", Kid: key.id})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("marshal token header: %w", err)
+	}
+	claims, err := json.Marshal(tokenClaims{
+		Issuer:    t.issuer,
+		Subject:   p.KeyID.String(),
+		ProjectID: p.ProjectID.String(),
+		Scopes:    p.Scopes,
+		CIDRs:     p.AllowedCIDRs,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("marshal token claims: %w", err)
+	}
+
+	signingInput := b64(header) + "." + b64(claims)
+	return signingInput + "." + b64(key.sign([]byte(signingInput))), expiresAt, nil
+}
+
+// Verify comprueba firma, emisor y caducidad y devuelve el principal del token
+func (t *TokenIssuer) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	key := t.keyByID(header.Kid)
+	// el alg debe coincidir con el de la clave: evita confusión de algoritmos
+	if key == nil || key.alg != header.Alg {
+		return nil, errors.New("unknown signing key")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, errors.New("invalid token signature")
+	}
+
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	if claims.Issuer != t.issuer {
+		return nil, errors.New("invalid token issuer")
+	}
+	if time.Now().Add(-clockSkew).Unix() >= claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	projectID, err := uuid.Parse(claims.ProjectID)
+	if err != nil {
+		return nil, errors.New("invalid token project")
+	}
+	keyID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, errors.New("invalid token subject")
+	}
+	return &Principal{
+		ProjectID:    projectID,
+		KeyID:        keyID,
+		Scopes:       claims.Scopes,
+		Source:       SourceToken,
+		AllowedCIDRs: claims.CIDRs,
+	}, nil
+}
+
+func (t *TokenIssuer) keyByID(id string) *signingKey {
+	for i := range t.keys {
+		if t.keys[i].id == id {
+			return &t.keys[i]
+		}
+	}
+	return nil
+}
+
+func (k *signingKey) sign(input []byte) []byte {
+	if k.alg == AlgEdDSA {
+		return ed25519.Sign(k.privateKey, input)
+	}
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(input)
+	return mac.Sum(nil)
+}
+
+func (k *signingKey) verify(input, sig []byte) bool {
+	if k.alg == AlgEdDSA {
+		return ed25519.Verify(k.publicKey, input, sig)
+	}
+	return hmac.Equal(k.sign(input), sig)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// looksLikeToken distingue un JWT (3 segmentos) de una API key
+func looksLikeToken(credential string) bool {
+	return strings.Count(credential, ".") == 2 && strings.HasPrefix(credential, "eyJ")
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"plans-features/internal/config"
+
+	"github.com/google/uuid"
+)
+
+const testIssuer = "plans-features-test"
+
+func hsKey(id string, fill byte) config.SigningKey {
+	return config.SigningKey{
+		ID:        id,
+		Algorithm: AlgHS256,
+		Secret:    base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), 32))),
+	}
+}
+
+func edSeed(fill byte) []byte {
+	return []byte(strings.Repeat(string(fill), ed25519.SeedSize))
+}
+
+func edKey(id string, fill byte) config.SigningKey {
+	return config.SigningKey{
+		ID:        id,
+		Algorithm: AlgEdDSA,
+		Secret:    base64.StdEncoding.EncodeToString(edSeed(fill)),
+	}
+}
+
+func newIssuer(t *testing.T, keys ...config.SigningKey) *TokenIssuer {
+	t.Helper()
+	issuer, err := NewTokenIssuer(config.Tokens{TTL: 5 * time.Minute, Issuer: testIssuer, SigningKeys: keys})
+	if err != nil {
+		t.Fatalf("NewTokenIssuer: %v", err)
+	}
+	return issuer
+}
+
+func testPrincipal() *Principal {
+	return &Principal{
+		ProjectID:    uuid.New(),
+		KeyID:        uuid.New(),
+		Scopes:       []string{ScopeEntitlementsRead},
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+	}
+}
+
+// forge firma header y claims arbitrarios con sign
+func forge(t *testing.T, header tokenHeader, claims tokenClaims, sign func(input []byte) []byte) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := b64(h) + "." + b64(c)
+	return input + "." + b64(sign([]byte(input)))
+}
+
+func hmacSigner(secret []byte) func([]byte) []byte {
+	return func(input []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+}
+
+func claimsFor(p *Principal, issuedAt, expiresAt time.Time) tokenClaims {
+	return tokenClaims{
+		Issuer:    testIssuer,
+		Subject:   p.KeyID.String(),
+		ProjectID: p.ProjectID.String(),
+		Scopes:    p.Scopes,
+		IssuedAt:  issuedAt.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+}
+
+func TestTokenIssueVerifyRoundTrip(t *testing.T) {
+	for _, key := range []config.SigningKey{hsKey("hs-1", 'a'), edKey("ed-1", 'b')} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			issuer := newIssuer(t, key)
+			p := testPrincipal()
+			token, expiresAt, err := issuer.Issue(p)
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			if !looksLikeToken(token) {
+				t.Errorf("looksLikeToken(%q) = false", token)
+			}
+			if d := time.Until(expiresAt); d <= 0 || d > issuer.TTL() {
+				t.Errorf("expiresAt in %v, want within ttl %v", d, issuer.TTL())
+			}
+			got, err := issuer.Verify(token)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if got.ProjectID != p.ProjectID || got.KeyID != p.KeyID || got.Source != SourceToken {
+				t.Errorf("principal = %+v, want project %s key %s", got, p.ProjectID, p.KeyID)
+			}
+			if len(got.Scopes) != 1 || got.Scopes[0] != ScopeEntitlementsRead {
+				t.Errorf("scopes = %v", got.Scopes)
+			}
+			if len(got.AllowedCIDRs) != 1 || got.AllowedCIDRs[0] != "10.0.0.0/8" {
+				t.Errorf("cidrs = %v", got.AllowedCIDRs)
+			}
+		})
+	}
+}
+
+func TestTokenVerifyExpiry(t *testing.T) {
+	key := hsKey("hs-1", 'a')
+	issuer := newIssuer(t, key)
+	secret, _ := base64.StdEncoding.DecodeString(key.Secret)
+	header := tokenHeader{Alg: AlgHS256, Typ: "JWT", Kid: key.ID}
+	p := testPrincipal()
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		wantErr   bool
+	}{
+		{"valid", now.Add(time.Minute), false},
+		{"expired within clock skew", now.Add(-clockSkew / 2), false},
+		{"expired at clock skew", now.Add(-clockSkew), true},
+		{"expired beyond clock skew", now.Add(-clockSkew - time.Second), true},
+		{"expired long ago", now.Add(-time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := forge(t, header, claimsFor(p, now.Add(-time.Hour), tt.expiresAt), hmacSigner(secret))
+			_, err := issuer.Verify(token)
+			if tt.wantErr {
+				if err == nil || err.Error() != "token expired" {
+					t.Errorf("Verify err = %v, want token expired", err)
+				}
+			} else if err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenVerifyUnknownKid(t *testing.T) {
+	issuer := newIssuer(t, hsKey("hs-1", 'a'))
+	other := newIssuer(t, hsKey("hs-2", 'a'))
+
+	// mismo secreto, otro kid: no se prueba ninguna otra clave
+	token, _, err := other.Issue(testPrincipal())
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := issuer.Verify(token); err == nil || err.Error() != "unknown signing key" {
+		t.Errorf("Verify err = %v, want unknown signing key", err)
+	}
+}
+
+func TestTokenVerifyKidRollover(t *testing.T) {
+	oldKey := edKey("ed-old", 'a')
+	newKey := edKey("ed-new", 'b')
+
+	before := newIssuer(t, oldKey)
+	oldToken, _, err := before.Issue(testPrincipal())
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	// la clave nueva firma y la antigua (solo con su clave pública) sigue verificando
+	retired := config.SigningKey{
+		ID:        oldKey.ID,
+		Algorithm: AlgEdDSA,
+		PublicKey: base64.StdEncoding.EncodeToString(ed25519.NewKeyFromSeed(edSeed('a')).Public().(ed25519.PublicKey)),
+	}
+	after := newIssuer(t, newKey, retired)
+	if _, err := after.Verify(oldToken); err != nil {
+		t.Errorf("token signed with the previous key: %v", err)
+	}
+	newToken, _, err := after.Issue(testPrincipal())
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if !strings.Contains(decodeHeader(t, newToken), `"kid":"ed-new"`) {
+		t.Errorf("new tokens must be signed with the first key")
+	}
+	if _, err := after.Verify(newToken); err != nil {
+		t.Errorf("token signed with the new key: %v", err)
+	}
+
+	// una réplica que aún no conoce la clave nueva rechaza sus tokens
+	if _, err := before.Verify(newToken); err == nil {
+		t.Errorf("Verify accepted a token signed with an unknown key")
+	}
+
+	// quitar la clave antigua invalida sus tokens
+	pruned := newIssuer(t, newKey)
+	if _, err := pruned.Verify(oldToken); err == nil {
+		t.Errorf("Verify accepted a token signed with a removed key")
+	}
+}
+
+func TestNewTokenIssuerFirstKeyMustSign(t *testing.T) {
+	pubOnly := config.SigningKey{
+		ID:        "ed-pub",
+		Algorithm: AlgEdDSA,
+		PublicKey: base64.StdEncoding.EncodeToString(ed25519.NewKeyFromSeed(edSeed('a')).Public().(ed25519.PublicKey)),
+	}
+	if _, err := NewTokenIssuer(config.Tokens{SigningKeys: []config.SigningKey{pubOnly}}); err == nil {
+		t.Errorf("NewTokenIssuer accepted a verify-only first key")
+	}
+}
+
+func TestTokenVerifyAlgConfusion(t *testing.T) {
+	edCfg := edKey("ed-1", 'a')
+	hsCfg := hsKey("hs-1", 'b')
+	issuer := newIssuer(t, edCfg, hsCfg)
+	p := testPrincipal()
+	now := time.Now()
+	claims := claimsFor(p, now, now.Add(time.Minute))
+
+	// HS256 firmado con la clave pública EdDSA como secreto HMAC
+	pub := ed25519.NewKeyFromSeed(edSeed('a')).Public().(ed25519.PublicKey)
+	hsWithPub := forge(t, tokenHeader{Alg: AlgHS256, Typ: "JWT", Kid: edCfg.ID}, claims, hmacSigner(pub))
+
+	// EdDSA declarado sobre la clave HS256
+	edPriv := ed25519.NewKeyFromSeed(edSeed('c'))
+	edOnHS := forge(t, tokenHeader{Alg: AlgEdDSA, Typ: "JWT", Kid: hsCfg.ID}, claims, func(in []byte) []byte {
+		return ed25519.Sign(edPriv, in)
+	})
+
+	// alg none sin firma
+	noneToken := forge(t, tokenHeader{Alg: "none", Typ: "JWT", Kid: edCfg.ID}, claims, func([]byte) []byte { return nil })
+
+	for name, token := range map[string]string{
+		"HS256 with EdDSA public key": hsWithPub,
+		"EdDSA on HS256 key":          edOnHS,
+		"alg none":                    noneToken,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := issuer.Verify(token); err == nil || err.Error() != "unknown signing key" {
+				t.Errorf("Verify err = %v, want unknown signing key", err)
+			}
+		})
+	}
+}
+
+func TestTokenVerifyTampered(t *testing.T) {
+	for _, key := range []config.SigningKey{hsKey("hs-1", 'a'), edKey("ed-1", 'b')} {
+		t.Run(key.Algorithm, func(t *testing.T) {
+			issuer := newIssuer(t, key)
+			token, _, err := issuer.Issue(testPrincipal())
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			parts := strings.Split(token, ".")
+
+			// claims con más scopes, misma firma
+			var claims tokenClaims
+			if err := decodeSegment(parts[1], &claims); err != nil {
+				t.Fatal(err)
+			}
+			claims.Scopes = append(claims.Scopes, ScopeTenantsWrite)
+			raw, _ := json.Marshal(claims)
+			escalated := parts[0] + "." + b64(raw) + "." + parts[2]
+
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			sig[0] ^= 0xff
+			flipped := parts[0] + "." + parts[1] + "." + b64(sig)
+
+			tests := map[string]string{
+				"modified claims":   escalated,
+				"flipped signature": flipped,
+				"empty signature":   parts[0] + "." + parts[1] + ".",
+				"bad encoding":      parts[0] + "." + parts[1] + ".!!!",
+			}
+			for name, bad := range tests {
+				if _, err := issuer.Verify(bad); err == nil || err.Error() != "invalid token signature" {
+					t.Errorf("%s: Verify err = %v, want invalid token signature", name, err)
+				}
+			}
+
+			if _, err := issuer.Verify(parts[0] + "." + parts[1]); err == nil || err.Error() != "malformed token" {
+				t.Errorf("two segments: Verify err = %v, want malformed token", err)
+			}
+		})
+	}
+}
+
+func TestTokenVerifyIssuer(t *testing.T) {
+	issuer := newIssuer(t, hsKey("hs-1", 'a'))
+	other, err := NewTokenIssuer(config.Tokens{TTL: time.Minute, Issuer: "someone-else", SigningKeys: []config.SigningKey{hsKey("hs-1", 'a')}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := other.Issue(testPrincipal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Verify(token); err == nil || err.Error() != "invalid token issuer" {
+		t.Errorf("Verify err = %v, want invalid token issuer", err)
+	}
+}
+
+func decodeHeader(t *testing.T, token string) string {
+	t.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
@@ -3,18 +3,90 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.yaml.in/yaml/v3"
 )
 
 type Config struct {
-	Database Database `yaml:"database"`
+	Database   Database   `yaml:"database"`
+	Admin      Admin      `yaml:"admin"`
+	APIKeys    APIKeys    `yaml:"api_keys"`
+	Tokens     Tokens     `yaml:"tokens"`
+	RateLimits RateLimits `yaml:"rate_limits"`
+	Server     Server     `yaml:"server"`
+	Usage      Usage      `yaml:"usage"`
+	Scheduler  Scheduler  `yaml:"scheduler"`
+}
+
+// Scheduler: cada cuánto corren los jobs en segundo plano (p. ej. finalizar
+// trials vencidos). Todas las réplicas los ejecutan; el trabajo se reparte
+// en la BD.
+type Scheduler struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Usage: Timezone (nombre IANA, UTC por defecto) es la zona en la que empiezan
+// las ventanas de reset de la cuota (medianoche, lunes, día del mes...)
+type Usage struct {
+	Timezone string `yaml:"timezone"`
+}
+
+// Server: TrustedProxies son las redes (CIDR o IP) de los balanceadores;
+// solo desde ellas se acepta X-Forwarded-For para obtener la IP del cliente.
+type Server struct {
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
 type Database struct {
 	URL string `yaml:"url"`
 }
 
+// APIKeys: Environment ("live"/"test") va embebido en cada key;
+// tras rotar, la key anterior sigue válida durante RotationGracePeriod.
+// Pepper es el secreto del servidor para el HMAC de key_hash (nunca en la BD).
+type APIKeys struct {
+	Environment         string        `yaml:"environment"`
+	RotationGracePeriod time.Duration `yaml:"rotation_grace_period"`
+	Pepper              string        `yaml:"pepper"`
+}
+
+// Tokens: access tokens firmados que se obtienen con POST /api/token.
+// La primera clave de SigningKeys firma; todas verifican (rollover).
+type Tokens struct {
+	TTL         time.Duration `yaml:"ttl"`
+	Issuer      string        `yaml:"issuer"`
+	SigningKeys []SigningKey  `yaml:"signing_keys"`
+}
+
+// SigningKey: Algorithm es "HS256" (Secret = secreto HMAC en base64) o "EdDSA"
+// (Secret = semilla Ed25519 de 32 bytes en base64). Una clave EdDSA retirada
+// puede declararse solo con PublicKey para seguir verificando.
+type SigningKey struct {
+	ID        string `yaml:"id"`
+	Algorithm string `yaml:"algorithm"`
+	Secret    string `yaml:"secret"`
+	PublicKey string `yaml:"public_key"`
+}
+
+// RateLimits: límites por defecto (token bucket) cuando el proyecto no define
+// los suyos. Key se aplica por API key y Tenant por cada tenantId de la ruta.
+// PolicyCacheTTL acota cuánto tarda en verse un cambio de política.
+type RateLimits struct {
+	KeyRPS         float64       `yaml:"key_rps"`
+	KeyBurst       int           `yaml:"key_burst"`
+	TenantRPS      float64       `yaml:"tenant_rps"`
+	TenantBurst    int           `yaml:"tenant_burst"`
+	PolicyCacheTTL time.Duration `yaml:"policy_cache_ttl"`
+}
+
+// Admin: tokens bootstrap para el subárbol /admin (se guardan hasheados al arrancar)
+type Admin struct {
+	BootstrapTokens []string `yaml:"bootstrap_tokens"`
+}
+
 func Load() *Config {
 	// 1. Intentar cargar desde sql.yaml
 	cfg := &Config{}
@@ -29,6 +101,101 @@ func Load() *Config {
 	if url := os.Getenv("DATABASE_URL"); url != "" {
 		cfg.Database.URL = url
 	}
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.Server.TrustedProxies = strings.Split(proxies, ",")
+	}
+	if tokens := os.Getenv("ADMIN_BOOTSTRAP_TOKENS"); tokens != "" {
+		cfg.Admin.BootstrapTokens = strings.Split(tokens, ",")
+	}
+	if grace := os.Getenv("API_KEY_ROTATION_GRACE"); grace != "" {
+		if d, err := time.ParseDuration(grace); err == nil {
+			cfg.APIKeys.RotationGracePeriod = d
+		} else {
+			fmt.Printf("Warning: invalid API_KEY_ROTATION_GRACE: %v\n", err)
+		}
+	}
+	if pepper := os.Getenv("API_KEY_PEPPER"); pepper != "" {
+		cfg.APIKeys.Pepper = pepper
+	}
+	if env := os.Getenv("API_KEY_ENVIRONMENT"); env != "" {
+		cfg.APIKeys.Environment = env
+	}
+	if cfg.APIKeys.Environment != "test" {
+		cfg.APIKeys.Environment = "live"
+	}
+	if cfg.APIKeys.RotationGracePeriod <= 0 {
+		cfg.APIKeys.RotationGracePeriod = 24 * time.Hour
+	}
+
+	// TOKEN_SIGNING_KEYS: "id:alg:secret,id2:alg:secret" (la primera firma)
+	if keys := os.Getenv("TOKEN_SIGNING_KEYS"); keys != "" {
+		cfg.Tokens.SigningKeys = nil
+		for _, entry := range strings.Split(keys, ",") {
+			parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+			if len(parts) != 3 {
+				fmt.Printf("Warning: invalid TOKEN_SIGNING_KEYS entry %q\n", entry)
+				continue
+			}
+			cfg.Tokens.SigningKeys = append(cfg.Tokens.SigningKeys,
+				SigningKey{ID: parts[0], Algorithm: parts[1], Secret: parts[2]})
+		}
+	}
+	if ttl := os.Getenv("TOKEN_TTL"); ttl != "" {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			cfg.Tokens.TTL = d
+		} else {
+			fmt.Printf("Warning: invalid TOKEN_TTL: %v\n", err)
+		}
+	}
+	if cfg.Tokens.TTL <= 0 {
+		cfg.Tokens.TTL = 5 * time.Minute
+	}
+	if cfg.Tokens.Issuer == "" {
+		cfg.Tokens.Issuer = "plans-features"
+	}
+
+	// RATE_LIMIT_KEY / RATE_LIMIT_TENANT: "rps:burst"
+	if v := os.Getenv("RATE_LIMIT_KEY"); v != "" {
+		if rps, burst, ok := parseRateLimit(v); ok {
+			cfg.RateLimits.KeyRPS, cfg.RateLimits.KeyBurst = rps, burst
+		} else {
+			fmt.Printf("Warning: invalid RATE_LIMIT_KEY %q\n", v)
+		}
+	}
+	if v := os.Getenv("RATE_LIMIT_TENANT"); v != "" {
+		if rps, burst, ok := parseRateLimit(v); ok {
+			cfg.RateLimits.TenantRPS, cfg.RateLimits.TenantBurst = rps, burst
+		} else {
+			fmt.Printf("Warning: invalid RATE_LIMIT_TENANT %q\n", v)
+		}
+	}
+	if cfg.RateLimits.KeyRPS == 0 && cfg.RateLimits.KeyBurst == 0 {
+		cfg.RateLimits.KeyRPS, cfg.RateLimits.KeyBurst = 50, 100
+	}
+	if cfg.RateLimits.TenantRPS == 0 && cfg.RateLimits.TenantBurst == 0 {
+		cfg.RateLimits.TenantRPS, cfg.RateLimits.TenantBurst = 10, 20
+	}
+	if cfg.RateLimits.PolicyCacheTTL <= 0 {
+		cfg.RateLimits.PolicyCacheTTL = time.Minute
+	}
+
+	if tz := os.Getenv("USAGE_TIMEZONE"); tz != "" {
+		cfg.Usage.Timezone = tz
+	}
+	if cfg.Usage.Timezone == "" {
+		cfg.Usage.Timezone = "UTC"
+	}
+
+	if v := os.Getenv("SCHEDULER_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Scheduler.Interval = d
+		} else {
+			fmt.Printf("Warning: invalid SCHEDULER_INTERVAL: %v\n", err)
+		}
+	}
+	if cfg.Scheduler.Interval <= 0 {
+		cfg.Scheduler.Interval = time.Minute
+	}
 
 	// 3. DSN por defecto para desarrollo local
 	/*if cfg.Database.URL == "" {
@@ -37,3 +204,19 @@ func Load() *Config {
 
 	return cfg
 }
+
+func parseRateLimit(v string) (float64, int, bool) {
+	rpsStr, burstStr, found := strings.Cut(v, ":")
+	if !found {
+		return 0, 0, false
+	}
+	rps, err := strconv.ParseFloat(rpsStr, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	burst, err := strconv.Atoi(burstStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	return rps, burst, true
+}
@@ -0,0 +1,6 @@
+-- 007_add_apikey_scopes.down.sql
+BEGIN;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS scopes;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- 007_add_apikey_scopes.up.sql
+BEGIN;
+
+-- 1. Scopes por API key (JSONB array de strings)
+ALTER TABLE api_keys ADD COLUMN scopes JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- 2. Las keys existentes conservan acceso completo
+UPDATE api_keys
+SET scopes = '["entitlements:read", "catalog:read", "catalog:write", "tenants:write"]'::jsonb;
+
+COMMIT;
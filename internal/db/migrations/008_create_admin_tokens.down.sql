@@ -0,0 +1,8 @@
+-- 008_create_admin_tokens.down.sql
+BEGIN;
+
+DROP INDEX IF EXISTS idx_admin_tokens_revoked;
+DROP INDEX IF EXISTS idx_admin_tokens_hash_unique;
+DROP TABLE IF EXISTS admin_tokens;
+
+COMMIT;
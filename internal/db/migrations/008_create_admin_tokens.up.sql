@@ -0,0 +1,19 @@
+-- 008_create_admin_tokens.up.sql
+BEGIN;
+
+-- Tokens de administración (principal global, no ligado a un proyecto)
+CREATE TABLE admin_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    token_prefix TEXT NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT false,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- UNIQUE: permite sembrar los tokens bootstrap de forma idempotente
+CREATE UNIQUE INDEX idx_admin_tokens_hash_unique ON admin_tokens (token_hash);
+CREATE INDEX idx_admin_tokens_revoked ON admin_tokens (revoked);
+
+COMMIT;
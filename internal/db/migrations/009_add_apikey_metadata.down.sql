@@ -0,0 +1,9 @@
+-- 009_add_apikey_metadata.down.sql
+BEGIN;
+
+DROP INDEX IF EXISTS idx_api_keys_expires_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS label;
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- 009_add_apikey_metadata.up.sql
+BEGIN;
+
+-- Varias keys activas por proyecto: etiqueta, caducidad y último uso
+ALTER TABLE api_keys ADD COLUMN label TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_keys ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE api_keys ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_api_keys_expires_at ON api_keys (expires_at) WHERE expires_at IS NOT NULL;
+
+COMMIT;
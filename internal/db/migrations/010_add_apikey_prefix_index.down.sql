@@ -0,0 +1,6 @@
+-- 010_add_apikey_prefix_index.down.sql
+BEGIN;
+
+DROP INDEX IF EXISTS idx_api_keys_prefix_unique;
+
+COMMIT;
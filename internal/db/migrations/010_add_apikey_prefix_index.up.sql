@@ -0,0 +1,9 @@
+-- 010_add_apikey_prefix_index.up.sql
+BEGIN;
+
+-- Las keys con formato pf_<env>_<id>_... se buscan por key_prefix (único);
+-- las keys antiguas siguen buscándose por key_hash.
+CREATE UNIQUE INDEX idx_api_keys_prefix_unique ON api_keys (key_prefix)
+WHERE key_prefix LIKE 'pf\_%';
+
+COMMIT;
@@ -0,0 +1,6 @@
+-- 011_add_apikey_hash_version.down.sql
+BEGIN;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS hash_version;
+
+COMMIT;
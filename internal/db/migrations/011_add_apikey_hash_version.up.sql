@@ -0,0 +1,8 @@
+-- 011_add_apikey_hash_version.up.sql
+BEGIN;
+
+-- 1 = SHA-256 sin sal (legado), 2 = HMAC-SHA256 con pepper del servidor.
+-- Las keys existentes se actualizan a la versión 2 en su primer uso válido.
+ALTER TABLE api_keys ADD COLUMN hash_version SMALLINT NOT NULL DEFAULT 1;
+
+COMMIT;
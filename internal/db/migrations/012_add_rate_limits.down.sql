@@ -0,0 +1,7 @@
+-- 012_add_rate_limits.down.sql
+BEGIN;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS rate_limits;
+ALTER TABLE projects DROP COLUMN IF EXISTS rate_limits;
+
+COMMIT;
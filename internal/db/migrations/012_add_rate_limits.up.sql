@@ -0,0 +1,9 @@
+-- 012_add_rate_limits.up.sql
+BEGIN;
+
+-- Políticas de rate limiting (JSONB: {"key": {...}, "tenant": {...}}).
+-- La del proyecto aplica a todas sus keys; la de la key la sustituye.
+ALTER TABLE projects ADD COLUMN rate_limits JSONB;
+ALTER TABLE api_keys ADD COLUMN rate_limits JSONB;
+
+COMMIT;
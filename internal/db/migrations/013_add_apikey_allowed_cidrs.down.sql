@@ -0,0 +1,6 @@
+-- 013_add_apikey_allowed_cidrs.down.sql
+BEGIN;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS allowed_cidrs;
+
+COMMIT;
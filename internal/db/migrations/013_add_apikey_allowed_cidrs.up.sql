@@ -0,0 +1,7 @@
+-- 013_add_apikey_allowed_cidrs.up.sql
+BEGIN;
+
+-- Allowlist de redes por key (JSONB: ["10.0.0.0/8", ...]). Vacío = sin restricción.
+ALTER TABLE api_keys ADD COLUMN allowed_cidrs JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+COMMIT;
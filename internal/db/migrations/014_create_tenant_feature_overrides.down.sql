@@ -0,0 +1,6 @@
+-- 014_create_tenant_feature_overrides.down.sql
+BEGIN;
+
+DROP TABLE IF EXISTS tenant_feature_overrides;
+
+COMMIT;
@@ -0,0 +1,29 @@
+-- 014_create_tenant_feature_overrides.up.sql
+BEGIN;
+
+-- Valor de una feature para un tenant concreto, por encima del de su plan.
+-- Con expires_at deja de aplicarse automáticamente al caducar.
+CREATE TABLE tenant_feature_overrides (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    feature_id UUID NOT NULL REFERENCES features(id) ON DELETE CASCADE,
+    value_json JSONB NOT NULL,
+    reason TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- UNIQUE: 1 override por feature y tenant
+CREATE UNIQUE INDEX idx_tenant_feature_overrides_unique
+    ON tenant_feature_overrides (tenant_id, project_id, feature_id);
+
+CREATE INDEX idx_tenant_feature_overrides_feature_id ON tenant_feature_overrides (feature_id);
+
+CREATE TRIGGER update_tenant_feature_overrides_updated_at
+    BEFORE UPDATE ON tenant_feature_overrides
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
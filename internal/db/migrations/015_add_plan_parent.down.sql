@@ -0,0 +1,8 @@
+-- 015_add_plan_parent.down.sql
+BEGIN;
+
+DROP INDEX IF EXISTS idx_plans_parent_plan_id;
+ALTER TABLE plans DROP CONSTRAINT IF EXISTS plans_parent_not_self;
+ALTER TABLE plans DROP COLUMN IF EXISTS parent_plan_id;
+
+COMMIT;
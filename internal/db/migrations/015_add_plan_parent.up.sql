@@ -0,0 +1,12 @@
+-- 015_add_plan_parent.up.sql
+BEGIN;
+
+-- Herencia de planes: un plan extiende a otro del mismo proyecto y sus
+-- plan_features sustituyen a las del padre. Ciclos y profundidad se validan
+-- en el servicio.
+ALTER TABLE plans ADD COLUMN parent_plan_id UUID REFERENCES plans(id) ON DELETE SET NULL;
+ALTER TABLE plans ADD CONSTRAINT plans_parent_not_self CHECK (parent_plan_id <> id);
+
+CREATE INDEX idx_plans_parent_plan_id ON plans (parent_plan_id) WHERE parent_plan_id IS NOT NULL;
+
+COMMIT;
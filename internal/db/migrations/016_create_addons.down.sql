@@ -0,0 +1,11 @@
+-- 016_create_addons.down.sql
+BEGIN;
+
+DROP TABLE IF EXISTS tenant_addons;
+DROP TABLE IF EXISTS addon_features;
+DROP TABLE IF EXISTS addons;
+
+ALTER TABLE features DROP CONSTRAINT IF EXISTS features_merge_strategy_check;
+ALTER TABLE features DROP COLUMN IF EXISTS merge_strategy;
+
+COMMIT;
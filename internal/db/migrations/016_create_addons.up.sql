@@ -0,0 +1,60 @@
+-- 016_create_addons.up.sql
+BEGIN;
+
+-- Cómo se combina el valor de un add-on con el del plan:
+-- flag → or, numeric → sum | max, value → override
+ALTER TABLE features ADD COLUMN merge_strategy TEXT;
+UPDATE features SET merge_strategy = CASE type
+    WHEN 'flag' THEN 'or'
+    WHEN 'numeric' THEN 'sum'
+    ELSE 'override'
+END;
+ALTER TABLE features ALTER COLUMN merge_strategy SET NOT NULL;
+ALTER TABLE features ADD CONSTRAINT features_merge_strategy_check
+    CHECK (merge_strategy IN ('or', 'sum', 'max', 'override'));
+
+-- Add-on: paquete de valores de features que se compra aparte del plan
+CREATE TABLE addons (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_addons_project_code ON addons (project_id, code);
+
+CREATE TRIGGER update_addons_updated_at
+    BEFORE UPDATE ON addons
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Valores de features de cada add-on (igual que plan_features)
+CREATE TABLE addon_features (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    addon_id UUID NOT NULL REFERENCES addons(id) ON DELETE CASCADE,
+    feature_id UUID NOT NULL REFERENCES features(id) ON DELETE CASCADE,
+    value_json JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_addon_features_unique ON addon_features (addon_id, feature_id);
+CREATE INDEX idx_addon_features_feature_id ON addon_features (feature_id);
+
+-- Add-ons contratados por cada tenant, junto a su fila de tenant_plans
+CREATE TABLE tenant_addons (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    addon_id UUID NOT NULL REFERENCES addons(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_tenant_addons_unique ON tenant_addons (tenant_id, project_id, addon_id);
+CREATE INDEX idx_tenant_addons_addon_id ON tenant_addons (addon_id);
+
+COMMIT;
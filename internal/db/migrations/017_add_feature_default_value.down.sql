@@ -0,0 +1,6 @@
+-- 017_add_feature_default_value.down.sql
+BEGIN;
+
+ALTER TABLE features DROP COLUMN IF EXISTS default_value;
+
+COMMIT;
@@ -0,0 +1,8 @@
+-- 017_add_feature_default_value.up.sql
+BEGIN;
+
+-- Valor que recibe un tenant cuando su plan no asigna la feature.
+-- NULL = sin valor por defecto (la feature queda deshabilitada).
+ALTER TABLE features ADD COLUMN default_value JSONB;
+
+COMMIT;
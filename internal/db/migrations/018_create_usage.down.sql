@@ -0,0 +1,9 @@
+-- 018_create_usage.down.sql
+BEGIN;
+
+UPDATE api_keys SET scopes = scopes - 'usage:write';
+
+DROP TABLE IF EXISTS usage_aggregates;
+DROP TABLE IF EXISTS usage_events;
+
+COMMIT;
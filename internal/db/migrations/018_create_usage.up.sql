@@ -0,0 +1,43 @@
+-- 018_create_usage.up.sql
+BEGIN;
+
+-- Eventos de uso en bruto: increment suma amount, gauge fija el valor absoluto.
+-- idempotency_key evita contar dos veces un reintento del cliente.
+CREATE TABLE usage_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    feature_id UUID NOT NULL REFERENCES features(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('increment', 'gauge')),
+    amount DOUBLE PRECISION NOT NULL,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    idempotency_key TEXT,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_usage_events_tenant_feature
+    ON usage_events (tenant_id, project_id, feature_id, occurred_at);
+CREATE UNIQUE INDEX idx_usage_events_idempotency
+    ON usage_events (project_id, tenant_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+-- Agregado por tenant/feature/periodo; se actualiza en la misma transacción
+-- que el evento. gauge_at evita que un gauge atrasado pise a uno más reciente.
+CREATE TABLE usage_aggregates (
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    feature_id UUID NOT NULL REFERENCES features(id) ON DELETE CASCADE,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    used DOUBLE PRECISION NOT NULL DEFAULT 0,
+    gauge_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, project_id, feature_id, period_start)
+);
+
+-- Las keys con acceso completo reciben el nuevo scope usage:write
+UPDATE api_keys
+SET scopes = scopes || '["usage:write"]'::jsonb
+WHERE scopes @> '["entitlements:read", "catalog:read", "catalog:write", "tenants:write"]'::jsonb;
+
+COMMIT;
@@ -0,0 +1,6 @@
+-- 019_create_usage_reservations.down.sql
+BEGIN;
+
+DROP TABLE IF EXISTS usage_reservations;
+
+COMMIT;
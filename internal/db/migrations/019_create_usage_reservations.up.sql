@@ -0,0 +1,30 @@
+-- 019_create_usage_reservations.up.sql
+BEGIN;
+
+-- Reservas de cuota para operaciones largas: mientras están en pending y no
+-- han caducado cuentan contra el límite; commit las convierte en uso y
+-- release las libera.
+CREATE TABLE usage_reservations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    feature_id UUID NOT NULL REFERENCES features(id) ON DELETE CASCADE,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    amount DOUBLE PRECISION NOT NULL CHECK (amount >= 0),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'committed', 'released')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Suma de reservas vivas al comprobar la cuota
+CREATE INDEX idx_usage_reservations_pending
+    ON usage_reservations (tenant_id, project_id, feature_id, period_start, expires_at)
+    WHERE status = 'pending';
+
+CREATE TRIGGER update_usage_reservations_updated_at
+    BEFORE UPDATE ON usage_reservations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
@@ -0,0 +1,7 @@
+-- 020_add_usage_reset_periods.down.sql
+BEGIN;
+
+ALTER TABLE tenant_plans DROP COLUMN IF EXISTS cycle_anchor;
+ALTER TABLE features DROP COLUMN IF EXISTS reset_period;
+
+COMMIT;
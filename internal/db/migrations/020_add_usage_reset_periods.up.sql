@@ -0,0 +1,21 @@
+-- 020_add_usage_reset_periods.up.sql
+BEGIN;
+
+-- Ventana en la que se reinicia la cuota de una feature numeric
+ALTER TABLE features
+    ADD COLUMN reset_period TEXT NOT NULL DEFAULT 'none'
+        CHECK (reset_period IN ('none', 'daily', 'weekly', 'monthly', 'yearly'));
+
+-- Ancla del ciclo de facturación del tenant: los periodos semanales,
+-- mensuales y anuales empiezan en su día de la semana / del mes / del año.
+-- Las asignaciones existentes se anclan a su fecha de creación.
+ALTER TABLE tenant_plans
+    ADD COLUMN cycle_anchor TIMESTAMP WITH TIME ZONE;
+
+UPDATE tenant_plans SET cycle_anchor = created_at;
+
+ALTER TABLE tenant_plans
+    ALTER COLUMN cycle_anchor SET DEFAULT NOW(),
+    ALTER COLUMN cycle_anchor SET NOT NULL;
+
+COMMIT;
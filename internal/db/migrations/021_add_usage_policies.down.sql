@@ -0,0 +1,12 @@
+-- 021_add_usage_policies.down.sql
+BEGIN;
+
+DROP TABLE IF EXISTS usage_alerts;
+
+ALTER TABLE usage_events DROP COLUMN IF EXISTS overage;
+
+ALTER TABLE plan_features
+    DROP COLUMN IF EXISTS alert_thresholds,
+    DROP COLUMN IF EXISTS enforcement;
+
+COMMIT;
@@ -0,0 +1,35 @@
+-- 021_add_usage_policies.up.sql
+BEGIN;
+
+-- Política de la cuota en cada asignación plan-feature (solo features numeric):
+-- hard rechaza lo que no cabe, soft lo deja pasar y registra el exceso,
+-- unlimited no aplica límite. alert_thresholds: porcentajes del límite.
+ALTER TABLE plan_features
+    ADD COLUMN enforcement TEXT NOT NULL DEFAULT 'hard'
+        CHECK (enforcement IN ('hard', 'soft', 'unlimited')),
+    ADD COLUMN alert_thresholds JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- Parte de cada evento que quedó por encima del límite
+ALTER TABLE usage_events
+    ADD COLUMN overage DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Umbrales cruzados: uno por tenant/feature/periodo/umbral, así la alerta
+-- se emite una sola vez por periodo
+CREATE TABLE usage_alerts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    feature_id UUID NOT NULL REFERENCES features(id) ON DELETE CASCADE,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    used DOUBLE PRECISION NOT NULL,
+    limit_value DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_usage_alerts_unique
+    ON usage_alerts (tenant_id, project_id, feature_id, period_start, threshold);
+CREATE INDEX idx_usage_alerts_project_created
+    ON usage_alerts (project_id, created_at);
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- 022_create_credits.down.sql
+BEGIN;
+
+DROP TABLE IF EXISTS credit_balances;
+DROP TABLE IF EXISTS credit_grants;
+DROP TRIGGER IF EXISTS credit_ledger_no_truncate ON credit_ledger;
+DROP TRIGGER IF EXISTS credit_ledger_append_only ON credit_ledger;
+DROP TABLE IF EXISTS credit_ledger;
+DROP FUNCTION IF EXISTS reject_credit_ledger_change();
+
+ALTER TABLE plans DROP COLUMN IF EXISTS credit_overdraft;
+ALTER TABLE features DROP COLUMN IF EXISTS credit_cost;
+
+COMMIT;
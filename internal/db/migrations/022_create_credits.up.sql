@@ -0,0 +1,84 @@
+-- 022_create_credits.up.sql
+BEGIN;
+
+-- Coste en créditos de cada uso de la feature (NULL = no se cobra en créditos)
+ALTER TABLE features
+    ADD COLUMN credit_cost DOUBLE PRECISION CHECK (credit_cost >= 0);
+
+-- Cuánto puede quedar en negativo el saldo de créditos de los tenants del plan
+-- (0 = nunca)
+ALTER TABLE plans
+    ADD COLUMN credit_overdraft DOUBLE PRECISION NOT NULL DEFAULT 0
+        CHECK (credit_overdraft >= 0);
+
+-- Ledger de créditos por tenant/proyecto, solo de inserción: grant suma,
+-- debit y expiration restan (amount lleva el signo). balance_after es el saldo
+-- tras el movimiento. RESTRICT: un proyecto con movimientos no se puede
+-- borrar, el borrado en cascada chocaría con el trigger append-only.
+CREATE TABLE credit_ledger (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE RESTRICT,
+    entry_type TEXT NOT NULL CHECK (entry_type IN ('grant', 'debit', 'expiration')),
+    amount DOUBLE PRECISION NOT NULL,
+    balance_after DOUBLE PRECISION NOT NULL,
+    feature_id UUID REFERENCES features(id),
+    quantity DOUBLE PRECISION,
+    grant_id UUID REFERENCES credit_ledger(id),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    idempotency_key TEXT,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_credit_ledger_tenant
+    ON credit_ledger (tenant_id, project_id, created_at);
+CREATE UNIQUE INDEX idx_credit_ledger_idempotency
+    ON credit_ledger (project_id, tenant_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+-- Ni UPDATE ni DELETE ni TRUNCATE: las filas no se tocan una vez escritas
+CREATE OR REPLACE FUNCTION reject_credit_ledger_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'credit_ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER credit_ledger_append_only
+    BEFORE UPDATE OR DELETE ON credit_ledger
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_credit_ledger_change();
+
+CREATE TRIGGER credit_ledger_no_truncate
+    BEFORE TRUNCATE ON credit_ledger
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION reject_credit_ledger_change();
+
+-- Saldo pendiente de cada grant: los débitos consumen primero el que caduca
+-- antes. Al caducar, lo que quede se descuenta con un movimiento expiration.
+CREATE TABLE credit_grants (
+    grant_id UUID PRIMARY KEY REFERENCES credit_ledger(id) ON DELETE CASCADE,
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    amount DOUBLE PRECISION NOT NULL,
+    remaining DOUBLE PRECISION NOT NULL CHECK (remaining >= 0),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_credit_grants_open
+    ON credit_grants (tenant_id, project_id, expires_at)
+    WHERE remaining > 0;
+
+-- Saldo actual por tenant/proyecto. Su fila se bloquea (FOR UPDATE) en cada
+-- movimiento para serializar grants y débitos concurrentes.
+CREATE TABLE credit_balances (
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    balance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, project_id)
+);
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- 023_add_tenant_plan_trials.down.sql
+BEGIN;
+
+DROP TABLE IF EXISTS tenant_plan_transitions;
+
+DROP INDEX IF EXISTS idx_tenant_plans_trial_ends_at;
+
+ALTER TABLE tenant_plans
+    DROP CONSTRAINT IF EXISTS tenant_plans_fallback_needs_trial,
+    DROP CONSTRAINT IF EXISTS tenant_plans_trial_complete,
+    DROP COLUMN IF EXISTS fallback_plan_id,
+    DROP COLUMN IF EXISTS trial_ends_at,
+    DROP COLUMN IF EXISTS trial_plan_id;
+
+COMMIT;
@@ -0,0 +1,35 @@
+-- 023_add_tenant_plan_trials.up.sql
+BEGIN;
+
+-- Trial en curso: mientras trial_ends_at no llega el tenant tiene plan_id
+-- (= trial_plan_id); después pasa a fallback_plan_id o, si es NULL, al plan
+-- por defecto del proyecto. El finalizador deja las tres columnas a NULL.
+ALTER TABLE tenant_plans
+    ADD COLUMN trial_plan_id UUID REFERENCES plans(id),
+    ADD COLUMN trial_ends_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN fallback_plan_id UUID REFERENCES plans(id),
+    ADD CONSTRAINT tenant_plans_trial_complete
+        CHECK ((trial_plan_id IS NULL) = (trial_ends_at IS NULL)),
+    ADD CONSTRAINT tenant_plans_fallback_needs_trial
+        CHECK (fallback_plan_id IS NULL OR trial_ends_at IS NOT NULL);
+
+CREATE INDEX idx_tenant_plans_trial_ends_at
+    ON tenant_plans (trial_ends_at)
+    WHERE trial_ends_at IS NOT NULL;
+
+-- Cambios de plan hechos por el sistema (p. ej. fin de un trial).
+-- to_plan_id NULL = el tenant se queda sin plan (no había plan por defecto).
+CREATE TABLE tenant_plan_transitions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    from_plan_id UUID REFERENCES plans(id),
+    to_plan_id UUID REFERENCES plans(id),
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_tenant_plan_transitions_tenant
+    ON tenant_plan_transitions (tenant_id, project_id, created_at);
+
+COMMIT;
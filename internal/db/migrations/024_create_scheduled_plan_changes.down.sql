@@ -0,0 +1,7 @@
+-- 024_create_scheduled_plan_changes.down.sql
+BEGIN;
+
+DROP TRIGGER IF EXISTS update_scheduled_plan_changes_updated_at ON scheduled_plan_changes;
+DROP TABLE IF EXISTS scheduled_plan_changes;
+
+COMMIT;
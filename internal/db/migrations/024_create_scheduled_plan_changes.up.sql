@@ -0,0 +1,33 @@
+-- 024_create_scheduled_plan_changes.up.sql
+BEGIN;
+
+-- Cambios de plan programados: el scheduler aplica cada cambio pending cuando
+-- llega effective_at (una sola vez, aunque corran varias réplicas).
+-- Como mucho un cambio pending por tenant/proyecto.
+CREATE TABLE scheduled_plan_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    plan_id UUID NOT NULL REFERENCES plans(id),
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'applied', 'canceled')),
+    applied_at TIMESTAMP WITH TIME ZONE,
+    canceled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_scheduled_plan_changes_pending
+    ON scheduled_plan_changes (tenant_id, project_id)
+    WHERE status = 'pending';
+CREATE INDEX idx_scheduled_plan_changes_due
+    ON scheduled_plan_changes (effective_at)
+    WHERE status = 'pending';
+
+CREATE TRIGGER update_scheduled_plan_changes_updated_at
+    BEFORE UPDATE ON scheduled_plan_changes
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMIT;
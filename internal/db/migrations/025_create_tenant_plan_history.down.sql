@@ -0,0 +1,11 @@
+-- 025_create_tenant_plan_history.down.sql
+BEGIN;
+
+ALTER TABLE scheduled_plan_changes DROP COLUMN IF EXISTS requested_by;
+
+DROP TRIGGER IF EXISTS tenant_plan_history_no_truncate ON tenant_plan_history;
+DROP TRIGGER IF EXISTS tenant_plan_history_append_only ON tenant_plan_history;
+DROP TABLE IF EXISTS tenant_plan_history;
+DROP FUNCTION IF EXISTS reject_tenant_plan_history_change();
+
+COMMIT;
@@ -0,0 +1,53 @@
+-- 025_create_tenant_plan_history.up.sql
+BEGIN;
+
+-- Historial de asignaciones (solo inserción): cada fila es el plan del tenant
+-- desde valid_from hasta el valid_from de la siguiente fila del mismo
+-- tenant/proyecto. plan_id NULL = el tenant se quedó sin asignación.
+-- trial_ends_at y fallback_plan_id replican el trial de tenant_plans para que
+-- la resolución a una fecha pasada siga las mismas reglas que la actual.
+-- RESTRICT: un proyecto con historial no se puede borrar, el borrado en
+-- cascada chocaría con el trigger append-only.
+CREATE TABLE tenant_plan_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id TEXT NOT NULL,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE RESTRICT,
+    plan_id UUID REFERENCES plans(id),
+    trial_ends_at TIMESTAMP WITH TIME ZONE,
+    fallback_plan_id UUID REFERENCES plans(id),
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    actor TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_tenant_plan_history_tenant
+    ON tenant_plan_history (tenant_id, project_id, valid_from);
+
+-- Ni UPDATE ni DELETE ni TRUNCATE: las filas no se tocan una vez escritas
+CREATE OR REPLACE FUNCTION reject_tenant_plan_history_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'tenant_plan_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tenant_plan_history_append_only
+    BEFORE UPDATE OR DELETE ON tenant_plan_history
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_tenant_plan_history_change();
+
+CREATE TRIGGER tenant_plan_history_no_truncate
+    BEFORE TRUNCATE ON tenant_plan_history
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION reject_tenant_plan_history_change();
+
+-- Quién programó cada cambio: al aplicarse es el actor en el historial
+ALTER TABLE scheduled_plan_changes ADD COLUMN requested_by TEXT;
+
+-- Las asignaciones existentes empiezan el historial desde su creación
+INSERT INTO tenant_plan_history (tenant_id, project_id, plan_id, trial_ends_at, fallback_plan_id, valid_from, actor, reason)
+SELECT tenant_id, project_id, plan_id, trial_ends_at, fallback_plan_id, created_at, 'system', 'backfill'
+FROM tenant_plans;
+
+COMMIT;
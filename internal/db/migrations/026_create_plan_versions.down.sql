@@ -0,0 +1,16 @@
+-- 026_create_plan_versions.down.sql
+BEGIN;
+
+DROP INDEX IF EXISTS idx_tenant_plans_plan_version;
+ALTER TABLE tenant_plan_history DROP COLUMN IF EXISTS plan_version_id;
+ALTER TABLE tenant_plans DROP COLUMN IF EXISTS plan_version_id;
+
+DROP FUNCTION IF EXISTS plan_version_at(UUID, TIMESTAMP WITH TIME ZONE);
+DROP TRIGGER IF EXISTS plan_version_features_immutable ON plan_version_features;
+DROP TRIGGER IF EXISTS plan_versions_immutable ON plan_versions;
+DROP TABLE IF EXISTS plan_version_features;
+DROP TABLE IF EXISTS plan_versions;
+DROP FUNCTION IF EXISTS reject_plan_version_features_change();
+DROP FUNCTION IF EXISTS reject_published_plan_version_update();
+
+COMMIT;
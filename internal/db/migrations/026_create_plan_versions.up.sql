@@ -0,0 +1,124 @@
+-- 026_create_plan_versions.up.sql
+BEGIN;
+
+-- Versiones de un plan. plan_features es el borrador de trabajo: editarlo abre
+-- una versión draft y publicarla congela en plan_version_features la vista
+-- aplanada (herencia incluida) de ese momento. Una versión publicada ya no
+-- cambia; cada asignación de tenant_plans fija la suya.
+CREATE TABLE plan_versions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    plan_id UUID NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL CHECK (version > 0),
+    status TEXT NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'published')),
+    published_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT plan_versions_published_at
+        CHECK ((status = 'published') = (published_at IS NOT NULL))
+);
+
+CREATE UNIQUE INDEX idx_plan_versions_plan_version ON plan_versions (plan_id, version);
+-- Como mucho un borrador por plan
+CREATE UNIQUE INDEX idx_plan_versions_draft ON plan_versions (plan_id) WHERE status = 'draft';
+
+-- Features de una versión: una fila por feature con el valor que resolvía
+-- la cadena de herencia al publicar (source_plan_id = plan que lo aportaba)
+CREATE TABLE plan_version_features (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    version_id UUID NOT NULL REFERENCES plan_versions(id) ON DELETE CASCADE,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    feature_id UUID NOT NULL REFERENCES features(id) ON DELETE CASCADE,
+    source_plan_id UUID NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+    value_json JSONB NOT NULL,
+    enforcement TEXT NOT NULL,
+    alert_thresholds JSONB NOT NULL DEFAULT '[]'::jsonb
+);
+
+CREATE UNIQUE INDEX idx_plan_version_features_unique
+    ON plan_version_features (version_id, feature_id);
+
+-- Una versión publicada no se modifica
+CREATE OR REPLACE FUNCTION reject_published_plan_version_update()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status = 'published' THEN
+        RAISE EXCEPTION 'plan version % is published', OLD.id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER plan_versions_immutable
+    BEFORE UPDATE ON plan_versions
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_published_plan_version_update();
+
+-- Las features solo se escriben al publicar (mientras la versión es draft)
+CREATE OR REPLACE FUNCTION reject_plan_version_features_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' OR NOT EXISTS (
+        SELECT 1 FROM plan_versions WHERE id = NEW.version_id AND status = 'draft'
+    ) THEN
+        RAISE EXCEPTION 'plan_version_features is immutable';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER plan_version_features_immutable
+    BEFORE INSERT OR UPDATE ON plan_version_features
+    FOR EACH ROW
+    EXECUTE FUNCTION reject_plan_version_features_change();
+
+-- Última versión publicada de un plan en una fecha (NULL si no había)
+CREATE OR REPLACE FUNCTION plan_version_at(p_plan_id UUID, p_at TIMESTAMP WITH TIME ZONE)
+RETURNS UUID AS $$
+    SELECT id FROM plan_versions
+    WHERE plan_id = p_plan_id AND status = 'published' AND published_at <= p_at
+    ORDER BY version DESC
+    LIMIT 1
+$$ LANGUAGE sql STABLE;
+
+-- Versión fijada por cada asignación (NULL = el plan aún no tenía ninguna
+-- publicada y se resuelve con plan_features)
+ALTER TABLE tenant_plans ADD COLUMN plan_version_id UUID REFERENCES plan_versions(id);
+ALTER TABLE tenant_plan_history ADD COLUMN plan_version_id UUID REFERENCES plan_versions(id);
+
+CREATE INDEX idx_tenant_plans_plan_version ON tenant_plans (plan_version_id);
+
+-- Los planes existentes publican su contenido actual como versión 1 (con la
+-- fecha de creación del plan, la mejor aproximación para consultas as_of) y
+-- todas las asignaciones quedan fijadas a ella
+INSERT INTO plan_versions (project_id, plan_id, version)
+SELECT project_id, id, 1 FROM plans;
+
+WITH RECURSIVE chain AS (
+    SELECT p.id AS root_id, p.id, p.parent_plan_id, 0 AS depth
+    FROM plans p
+    UNION ALL
+    SELECT c.root_id, p.id, p.parent_plan_id, c.depth + 1
+    FROM chain c
+    JOIN plans p ON p.id = c.parent_plan_id
+    WHERE c.depth < 32
+)
+INSERT INTO plan_version_features (version_id, project_id, feature_id, source_plan_id, value_json, enforcement, alert_thresholds)
+SELECT DISTINCT ON (c.root_id, pf.feature_id)
+       v.id, pf.project_id, pf.feature_id, c.id, pf.value_json, pf.enforcement, pf.alert_thresholds
+FROM chain c
+JOIN plan_features pf ON pf.plan_id = c.id
+JOIN plan_versions v ON v.plan_id = c.root_id
+ORDER BY c.root_id, pf.feature_id, c.depth;
+
+UPDATE plan_versions v
+SET status = 'published', published_at = p.created_at
+FROM plans p
+WHERE p.id = v.plan_id;
+
+UPDATE tenant_plans tp
+SET plan_version_id = v.id
+FROM plan_versions v
+WHERE v.plan_id = tp.plan_id;
+
+COMMIT;
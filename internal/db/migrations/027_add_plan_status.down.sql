@@ -0,0 +1,15 @@
+-- 027_add_plan_status.down.sql
+BEGIN;
+
+ALTER TABLE plans ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT true;
+
+UPDATE plans SET is_active = (status = 'active');
+
+DROP INDEX IF EXISTS idx_plans_project_status;
+DROP INDEX IF EXISTS idx_plans_project_code_unique;
+CREATE UNIQUE INDEX idx_plans_project_code_unique ON plans (project_id, code)
+WHERE is_active = true;
+
+ALTER TABLE plans DROP COLUMN status;
+
+COMMIT;
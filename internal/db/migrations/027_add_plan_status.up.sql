@@ -0,0 +1,37 @@
+-- 027_add_plan_status.up.sql
+BEGIN;
+
+-- Ciclo de vida del plan (sustituye a is_active):
+--   draft    en preparación, invisible para /api y no asignable
+--   active   visible y asignable
+--   legacy   sigue valiendo para sus tenants pero no admite asignaciones nuevas
+--   retired  sin tenants; solo se llega cuando se han migrado todos
+ALTER TABLE plans
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('draft', 'active', 'legacy', 'retired'));
+
+-- Los planes inactivos que aún tienen tenants (o un cambio programado hacia
+-- ellos) pasan a legacy; el resto, a retired
+UPDATE plans p
+SET status = CASE
+    WHEN EXISTS (
+        SELECT 1 FROM tenant_plans tp
+        WHERE p.id IN (tp.plan_id, tp.fallback_plan_id)
+    ) OR EXISTS (
+        SELECT 1 FROM scheduled_plan_changes c
+        WHERE c.plan_id = p.id AND c.status = 'pending'
+    ) THEN 'legacy'
+    ELSE 'retired'
+END
+WHERE p.is_active = false;
+
+-- El código sigue siendo único entre los planes en uso para altas nuevas
+DROP INDEX IF EXISTS idx_plans_project_code_unique;
+CREATE UNIQUE INDEX idx_plans_project_code_unique ON plans (project_id, code)
+WHERE status IN ('draft', 'active');
+
+CREATE INDEX idx_plans_project_status ON plans (project_id, status);
+
+ALTER TABLE plans DROP COLUMN is_active;
+
+COMMIT;
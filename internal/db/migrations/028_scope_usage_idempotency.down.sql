@@ -0,0 +1,14 @@
+-- 028_scope_usage_idempotency.down.sql
+BEGIN;
+
+DROP INDEX IF EXISTS idx_usage_reservations_idempotency;
+ALTER TABLE usage_reservations
+    DROP COLUMN IF EXISTS requested_amount,
+    DROP COLUMN IF EXISTS idempotency_key;
+
+DROP INDEX IF EXISTS idx_usage_events_idempotency;
+CREATE UNIQUE INDEX idx_usage_events_idempotency
+    ON usage_events (project_id, tenant_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+COMMIT;
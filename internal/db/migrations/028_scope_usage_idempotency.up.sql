@@ -0,0 +1,23 @@
+-- 028_scope_usage_idempotency.up.sql
+BEGIN;
+
+-- El idempotency_key de uso es por feature: la misma clave en otra feature
+-- es otro evento, y un reintento solo se reconoce dentro de su feature
+DROP INDEX IF EXISTS idx_usage_events_idempotency;
+CREATE UNIQUE INDEX idx_usage_events_idempotency
+    ON usage_events (project_id, tenant_id, feature_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+-- Reservas con idempotency_key: un reintento devuelve la reserva ya creada.
+-- requested_amount conserva lo pedido (amount cambia al confirmar menos) para
+-- reconocer un reintento con otra cantidad.
+ALTER TABLE usage_reservations
+    ADD COLUMN idempotency_key TEXT,
+    ADD COLUMN requested_amount DOUBLE PRECISION;
+UPDATE usage_reservations SET requested_amount = amount;
+ALTER TABLE usage_reservations ALTER COLUMN requested_amount SET NOT NULL;
+CREATE UNIQUE INDEX idx_usage_reservations_idempotency
+    ON usage_reservations (project_id, tenant_id, feature_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+COMMIT;
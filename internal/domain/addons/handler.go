@@ -0,0 +1,433 @@
+package addons
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"plans-features/internal/auth"
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type AddonHandler struct {
+	service AddonService
+}
+
+func NewAddonHandler(s AddonService) *AddonHandler {
+	return &AddonHandler{service: s}
+}
+
+// errorStatus traduce los errores del servicio a códigos HTTP
+func errorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"), msg == "addon not attached":
+		return http.StatusNotFound
+	case msg == "addon code already exists",
+		msg == "addon already attached",
+		msg == "feature already assigned to addon":
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "value must be"),
+		strings.HasSuffix(msg, "is required"),
+		msg == "addon is not active":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func parseUUIDParam(w http.ResponseWriter, r *http.Request, param string, label string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, param))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid "+label+" ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func projectFromContext(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+	}
+	return projectID, ok
+}
+
+// ListAddons godoc
+// @Summary List add-ons
+// @Description List active add-ons of the project identified by the API key
+// @Tags addons
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Success 200 {array} addons.AddonResponse
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/addons [get]
+func (h *AddonHandler) ListAddons(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.service.ListAddons(r.Context(), projectID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// CreateAddon godoc
+// @Summary Create an add-on
+// @Description Create a purchasable add-on (a named bundle of feature values) for the project identified by the API key
+// @Tags addons
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param body body addons.CreateAddonRequest true "Create add-on"
+// @Success 201 {object} addons.AddonResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/addons [post]
+func (h *AddonHandler) CreateAddon(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	var req CreateAddonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	a, err := h.service.CreateAddon(r.Context(), projectID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusCreated, a)
+}
+
+// GetAddon godoc
+// @Summary Get an add-on by ID
+// @Description Retrieve an add-on of the project identified by the API key
+// @Tags addons
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param addonId path string true "Add-on ID"
+// @Success 200 {object} addons.AddonResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/addons/{addonId} [get]
+func (h *AddonHandler) GetAddon(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	addonID, ok := parseUUIDParam(w, r, "addonId", "addon")
+	if !ok {
+		return
+	}
+	a, err := h.service.GetAddon(r.Context(), projectID, addonID)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, a)
+}
+
+// UpdateAddon godoc
+// @Summary Update an add-on
+// @Description Update name, description or active flag of an add-on. Inactive add-ons cannot be attached but keep applying to tenants that already have them.
+// @Tags addons
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param addonId path string true "Add-on ID"
+// @Param body body addons.UpdateAddonRequest true "Update add-on"
+// @Success 200 {object} addons.AddonResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/addons/{addonId} [put]
+func (h *AddonHandler) UpdateAddon(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	addonID, ok := parseUUIDParam(w, r, "addonId", "addon")
+	if !ok {
+		return
+	}
+	var req UpdateAddonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	a, err := h.service.UpdateAddon(r.Context(), projectID, addonID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, a)
+}
+
+// ListFeatures godoc
+// @Summary List feature values of an add-on
+// @Description List the feature values bundled in the add-on
+// @Tags addons
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param addonId path string true "Add-on ID"
+// @Success 200 {array} addons.AddonFeatureResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/addons/{addonId}/features [get]
+func (h *AddonHandler) ListFeatures(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	addonID, ok := parseUUIDParam(w, r, "addonId", "addon")
+	if !ok {
+		return
+	}
+	list, err := h.service.ListAddonFeatures(r.Context(), projectID, addonID)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// AssignFeature godoc
+// @Summary Add a feature value to an add-on
+// @Description Add a feature value to the add-on. The value is validated against the feature type like plan values.
+// @Tags addons
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param addonId path string true "Add-on ID"
+// @Param body body addons.AssignAddonFeatureRequest true "Assign feature"
+// @Success 201 {object} addons.AddonFeatureResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/addons/{addonId}/features [post]
+func (h *AddonHandler) AssignFeature(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	addonID, ok := parseUUIDParam(w, r, "addonId", "addon")
+	if !ok {
+		return
+	}
+	var req AssignAddonFeatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	af, err := h.service.AssignFeature(r.Context(), projectID, addonID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusCreated, af)
+}
+
+// ListTenantAddons godoc
+// @Summary List add-ons attached to a tenant
+// @Description List the add-ons attached to the tenant in the project identified by the API key
+// @Tags addons
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Success 200 {array} addons.TenantAddonResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/addons [get]
+func (h *AddonHandler) ListTenantAddons(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	tenantID, ok := parseUUIDParam(w, r, "tenantId", "tenant")
+	if !ok {
+		return
+	}
+	list, err := h.service.ListTenantAddons(r.Context(), tenantID, &projectID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// AttachAddon godoc
+// @Summary Attach an add-on to a tenant
+// @Description Attach an active add-on (by addon_id or addon_code) to the tenant, on top of its plan
+// @Tags addons
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param body body addons.AttachAddonRequest true "Attach add-on"
+// @Success 201 {object} addons.TenantAddonResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/addons [post]
+func (h *AddonHandler) AttachAddon(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	h.attach(w, r, &projectID)
+}
+
+// DetachAddon godoc
+// @Summary Detach an add-on from a tenant
+// @Description Remove the add-on from the tenant; its values stop applying immediately
+// @Tags addons
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param addonId path string true "Add-on ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/addons/{addonId} [delete]
+func (h *AddonHandler) DetachAddon(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := projectFromContext(w, r)
+	if !ok {
+		return
+	}
+	h.detach(w, r, &projectID)
+}
+
+// AdminListTenantAddons godoc
+// @Summary List add-ons attached to a tenant
+// @Description Admin: list the tenant's add-ons across projects
+// @Tags addons
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param project_id query string false "Filter by project ID"
+// @Success 200 {array} addons.TenantAddonResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/addons [get]
+func (h *AddonHandler) AdminListTenantAddons(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := parseUUIDParam(w, r, "tenantId", "tenant")
+	if !ok {
+		return
+	}
+	var projectID *uuid.UUID
+	if s := r.URL.Query().Get("project_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid project ID format")
+			return
+		}
+		projectID = &id
+	}
+	list, err := h.service.ListTenantAddons(r.Context(), tenantID, projectID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// AdminAttachAddon godoc
+// @Summary Attach an add-on to a tenant
+// @Description Admin: attach an active add-on of project_id to the tenant
+// @Tags addons
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param body body addons.AttachAddonRequest true "Attach add-on"
+// @Success 201 {object} addons.TenantAddonResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/addons [post]
+func (h *AddonHandler) AdminAttachAddon(w http.ResponseWriter, r *http.Request) {
+	h.attach(w, r, nil)
+}
+
+// AdminDetachAddon godoc
+// @Summary Detach an add-on from a tenant
+// @Description Admin: remove the add-on from the tenant
+// @Tags addons
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param addonId path string true "Add-on ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/addons/{addonId} [delete]
+func (h *AddonHandler) AdminDetachAddon(w http.ResponseWriter, r *http.Request) {
+	h.detach(w, r, nil)
+}
+
+// attach: projectID de la API key o, en admin (nil), el project_id del body
+func (h *AddonHandler) attach(w http.ResponseWriter, r *http.Request, projectID *uuid.UUID) {
+	tenantID, ok := parseUUIDParam(w, r, "tenantId", "tenant")
+	if !ok {
+		return
+	}
+	var req AttachAddonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if projectID == nil {
+		projectID = &req.ProjectID
+	}
+	ta, err := h.service.AttachAddon(r.Context(), tenantID, *projectID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusCreated, ta)
+}
+
+func (h *AddonHandler) detach(w http.ResponseWriter, r *http.Request, projectID *uuid.UUID) {
+	tenantID, ok := parseUUIDParam(w, r, "tenantId", "tenant")
+	if !ok {
+		return
+	}
+	addonID, ok := parseUUIDParam(w, r, "addonId", "addon")
+	if !ok {
+		return
+	}
+	if err := h.service.DetachAddon(r.Context(), tenantID, addonID, projectID); err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
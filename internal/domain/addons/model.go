@@ -0,0 +1,88 @@
+package addons
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Para DB (Scan)
+type Addon struct {
+	ID          uuid.UUID `db:"id"`
+	ProjectID   uuid.UUID `db:"project_id"`
+	Code        string    `db:"code"`
+	Name        string    `db:"name"`
+	Description *string   `db:"description"`
+	IsActive    bool      `db:"is_active"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+type CreateAddonRequest struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	IsActive    *bool  `json:"is_active,omitempty"`
+}
+
+type UpdateAddonRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	IsActive    *bool   `json:"is_active,omitempty"`
+}
+
+type AddonResponse struct {
+	ID          uuid.UUID `json:"id"`
+	ProjectID   uuid.UUID `json:"project_id"`
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsActive    bool      `json:"is_active"`
+}
+
+// AssignAddonFeatureRequest: mismo formato que planfeatures.AssignFeatureRequest
+type AssignAddonFeatureRequest struct {
+	FeatureID uuid.UUID   `json:"feature_id"`
+	Value     interface{} `json:"value"`
+}
+
+type AddonFeatureResponse struct {
+	ID          uuid.UUID   `json:"id"`
+	AddonID     uuid.UUID   `json:"addon_id"`
+	ProjectID   uuid.UUID   `json:"project_id"`
+	FeatureID   uuid.UUID   `json:"feature_id"`
+	FeatureCode string      `json:"feature_code"`
+	Value       interface{} `json:"value"`
+}
+
+// AttachAddonRequest: el add-on se indica por addon_id o addon_code.
+// project_id solo se usa en la ruta de admin; en /api sale de la API key.
+type AttachAddonRequest struct {
+	ProjectID uuid.UUID  `json:"project_id,omitempty"`
+	AddonID   *uuid.UUID `json:"addon_id,omitempty"`
+	AddonCode *string    `json:"addon_code,omitempty"`
+}
+
+type TenantAddonResponse struct {
+	ID        uuid.UUID `json:"id"`
+	TenantID  uuid.UUID `json:"tenant_id"`
+	ProjectID uuid.UUID `json:"project_id"`
+	AddonID   uuid.UUID `json:"addon_id"`
+	AddonCode string    `json:"addon_code"`
+	AddonName string    `json:"addon_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ToResponse(a *Addon) *AddonResponse {
+	resp := &AddonResponse{
+		ID:        a.ID,
+		ProjectID: a.ProjectID,
+		Code:      a.Code,
+		Name:      a.Name,
+		IsActive:  a.IsActive,
+	}
+	if a.Description != nil {
+		resp.Description = *a.Description
+	}
+	return resp
+}
@@ -0,0 +1,326 @@
+package addons
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AddonRepository interface {
+	List(ctx context.Context, projectID uuid.UUID) ([]AddonResponse, error)
+	Create(ctx context.Context, projectID uuid.UUID, req CreateAddonRequest) (*AddonResponse, error)
+	GetByID(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID) (*AddonResponse, error)
+	GetByCode(ctx context.Context, projectID uuid.UUID, code string) (*AddonResponse, error)
+	Update(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID, req UpdateAddonRequest) (*AddonResponse, error)
+
+	ListFeatures(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID) ([]AddonFeatureResponse, error)
+	AssignFeature(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID, req AssignAddonFeatureRequest) (*AddonFeatureResponse, error)
+
+	ListByTenant(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]TenantAddonResponse, error)
+	Attach(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, addonID uuid.UUID) (*TenantAddonResponse, error)
+	Detach(ctx context.Context, tenantID uuid.UUID, addonID uuid.UUID, projectID *uuid.UUID) error
+	TenantFeatureValues(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, asOf *time.Time) ([]interface{}, error)
+}
+
+type addonRepository struct {
+	db *sql.DB
+}
+
+func NewAddonRepository(db *sql.DB) AddonRepository {
+	return &addonRepository{db: db}
+}
+
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+const addonColumns = `id, project_id, code, name, description, is_active, created_at, updated_at`
+
+// tenantAddonColumns: ta = tenant_addons, a = addons
+const tenantAddonColumns = `ta.id, ta.tenant_id, ta.project_id, ta.addon_id, a.code, a.name, ta.created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAddon(row rowScanner) (*Addon, error) {
+	a := &Addon{}
+	var desc sql.NullString
+	if err := row.Scan(&a.ID, &a.ProjectID, &a.Code, &a.Name, &desc, &a.IsActive, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if desc.Valid && desc.String != "" {
+		a.Description = &desc.String
+	}
+	return a, nil
+}
+
+func scanTenantAddon(row rowScanner) (*TenantAddonResponse, error) {
+	ta := &TenantAddonResponse{}
+	var tenantID string
+	if err := row.Scan(&ta.ID, &tenantID, &ta.ProjectID, &ta.AddonID, &ta.AddonCode, &ta.AddonName, &ta.CreatedAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if ta.TenantID, err = uuid.Parse(tenantID); err != nil {
+		return nil, fmt.Errorf("parse tenant id: %w", err)
+	}
+	return ta, nil
+}
+
+func (r *addonRepository) List(ctx context.Context, projectID uuid.UUID) ([]AddonResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+addonColumns+`
+         FROM addons
+         WHERE project_id = $1 AND is_active = true
+         ORDER BY created_at DESC`,
+		projectID)
+	if err != nil {
+		return nil, fmt.Errorf("list addons: %w", err)
+	}
+	defer rows.Close()
+
+	var addons []AddonResponse
+	for rows.Next() {
+		a, err := scanAddon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan addon: %w", err)
+		}
+		addons = append(addons, *ToResponse(a))
+	}
+	return addons, rows.Err()
+}
+
+func (r *addonRepository) Create(ctx context.Context, projectID uuid.UUID, req CreateAddonRequest) (*AddonResponse, error) {
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	var description *string
+	if req.Description != "" {
+		description = &req.Description
+	}
+
+	a, err := scanAddon(r.db.QueryRowContext(ctx,
+		`INSERT INTO addons (id, project_id, code, name, description, is_active)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         RETURNING `+addonColumns,
+		uuid.New(), projectID, normalizeCode(req.Code), req.Name, description, isActive))
+	if err != nil {
+		return nil, fmt.Errorf("create addon: %w", err)
+	}
+	return ToResponse(a), nil
+}
+
+func (r *addonRepository) GetByID(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID) (*AddonResponse, error) {
+	a, err := scanAddon(r.db.QueryRowContext(ctx,
+		`SELECT `+addonColumns+`
+         FROM addons
+         WHERE project_id = $1 AND id = $2`,
+		projectID, addonID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("addon not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get addon: %w", err)
+	}
+	return ToResponse(a), nil
+}
+
+func (r *addonRepository) GetByCode(ctx context.Context, projectID uuid.UUID, code string) (*AddonResponse, error) {
+	a, err := scanAddon(r.db.QueryRowContext(ctx,
+		`SELECT `+addonColumns+`
+         FROM addons
+         WHERE project_id = $1 AND code = $2`,
+		projectID, normalizeCode(code)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("addon not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get addon by code: %w", err)
+	}
+	return ToResponse(a), nil
+}
+
+func (r *addonRepository) Update(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID, req UpdateAddonRequest) (*AddonResponse, error) {
+	a, err := scanAddon(r.db.QueryRowContext(ctx,
+		`UPDATE addons
+         SET name = COALESCE($1, name),
+             description = COALESCE($2, description),
+             is_active = COALESCE($3, is_active)
+         WHERE project_id = $4 AND id = $5
+         RETURNING `+addonColumns,
+		req.Name, req.Description, req.IsActive, projectID, addonID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("addon not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update addon: %w", err)
+	}
+	return ToResponse(a), nil
+}
+
+func (r *addonRepository) ListFeatures(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID) ([]AddonFeatureResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT af.id, af.addon_id, af.project_id, af.feature_id, f.code, af.value_json
+         FROM addon_features af
+         JOIN features f ON f.id = af.feature_id
+         WHERE af.project_id = $1 AND af.addon_id = $2
+         ORDER BY f.code`,
+		projectID, addonID)
+	if err != nil {
+		return nil, fmt.Errorf("list addon features: %w", err)
+	}
+	defer rows.Close()
+
+	var results []AddonFeatureResponse
+	for rows.Next() {
+		var af AddonFeatureResponse
+		var valueJSON []byte
+		if err := rows.Scan(&af.ID, &af.AddonID, &af.ProjectID, &af.FeatureID, &af.FeatureCode, &valueJSON); err != nil {
+			return nil, fmt.Errorf("scan addon feature: %w", err)
+		}
+		// JSONB → interface{}
+		if err := json.Unmarshal(valueJSON, &af.Value); err != nil {
+			return nil, fmt.Errorf("unmarshal value_json: %w", err)
+		}
+		results = append(results, af)
+	}
+	return results, rows.Err()
+}
+
+func (r *addonRepository) AssignFeature(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID, req AssignAddonFeatureRequest) (*AddonFeatureResponse, error) {
+	// interface{} → JSONB
+	valueJSON, err := json.Marshal(req.Value)
+	if err != nil {
+		return nil, fmt.Errorf("marshal value: %w", err)
+	}
+
+	af := &AddonFeatureResponse{}
+	err = r.db.QueryRowContext(ctx,
+		`WITH af AS (
+             INSERT INTO addon_features (id, project_id, addon_id, feature_id, value_json)
+             VALUES ($1, $2, $3, $4, $5)
+             ON CONFLICT (addon_id, feature_id) DO NOTHING
+             RETURNING *
+         )
+         SELECT af.id, af.addon_id, af.project_id, af.feature_id, f.code, af.value_json
+         FROM af
+         JOIN features f ON f.id = af.feature_id`,
+		uuid.New(), projectID, addonID, req.FeatureID, valueJSON).
+		Scan(&af.ID, &af.AddonID, &af.ProjectID, &af.FeatureID, &af.FeatureCode, &valueJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("feature already assigned to addon")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("assign addon feature: %w", err)
+	}
+	if err := json.Unmarshal(valueJSON, &af.Value); err != nil {
+		return nil, fmt.Errorf("unmarshal value_json: %w", err)
+	}
+	return af, nil
+}
+
+func (r *addonRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]TenantAddonResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+tenantAddonColumns+`
+         FROM tenant_addons ta
+         JOIN addons a ON a.id = ta.addon_id
+         WHERE ta.tenant_id = $1 AND ($2::uuid IS NULL OR ta.project_id = $2)
+         ORDER BY ta.created_at`,
+		tenantID.String(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("list tenant addons: %w", err)
+	}
+	defer rows.Close()
+
+	var results []TenantAddonResponse
+	for rows.Next() {
+		ta, err := scanTenantAddon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan tenant addon: %w", err)
+		}
+		results = append(results, *ta)
+	}
+	return results, rows.Err()
+}
+
+func (r *addonRepository) Attach(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, addonID uuid.UUID) (*TenantAddonResponse, error) {
+	ta, err := scanTenantAddon(r.db.QueryRowContext(ctx,
+		`WITH ta AS (
+             INSERT INTO tenant_addons (id, tenant_id, project_id, addon_id)
+             VALUES ($1, $2, $3, $4)
+             ON CONFLICT (tenant_id, project_id, addon_id) DO NOTHING
+             RETURNING *
+         )
+         SELECT `+tenantAddonColumns+`
+         FROM ta
+         JOIN addons a ON a.id = ta.addon_id`,
+		uuid.New(), tenantID.String(), projectID, addonID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("addon already attached")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("attach addon: %w", err)
+	}
+	return ta, nil
+}
+
+// Detach quita el add-on del tenant; projectID nil = cualquier proyecto
+// (el id del add-on ya identifica su proyecto)
+func (r *addonRepository) Detach(ctx context.Context, tenantID uuid.UUID, addonID uuid.UUID, projectID *uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM tenant_addons
+         WHERE tenant_id = $1 AND addon_id = $2 AND ($3::uuid IS NULL OR project_id = $3)`,
+		tenantID.String(), addonID, projectID)
+	if err != nil {
+		return fmt.Errorf("detach addon: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("addon not attached")
+	}
+	return nil
+}
+
+// TenantFeatureValues devuelve los valores de una feature en los add-ons
+// activos del tenant, en el orden en que se contrataron (el orden de
+// combinación). Con asOf solo cuentan los contratados antes de esa fecha.
+func (r *addonRepository) TenantFeatureValues(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, asOf *time.Time) ([]interface{}, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT af.value_json
+         FROM tenant_addons ta
+         JOIN addons a ON a.id = ta.addon_id AND a.is_active
+         JOIN addon_features af ON af.addon_id = ta.addon_id
+         WHERE ta.tenant_id = $1 AND ta.project_id = $2 AND af.feature_id = $3
+           AND ta.created_at <= COALESCE($4::timestamptz, NOW())
+         ORDER BY ta.created_at, ta.id`,
+		tenantID.String(), projectID, featureID, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("list tenant addon values: %w", err)
+	}
+	defer rows.Close()
+
+	var values []interface{}
+	for rows.Next() {
+		var valueJSON []byte
+		if err := rows.Scan(&valueJSON); err != nil {
+			return nil, fmt.Errorf("scan addon value: %w", err)
+		}
+		var v interface{}
+		if err := json.Unmarshal(valueJSON, &v); err != nil {
+			return nil, fmt.Errorf("unmarshal value_json: %w", err)
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
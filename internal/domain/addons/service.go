@@ -0,0 +1,130 @@
+package addons
+
+import (
+	"context"
+	"errors"
+
+	"plans-features/internal/domain/features"
+	"plans-features/internal/domain/projects"
+
+	"github.com/google/uuid"
+)
+
+type AddonService interface {
+	ListAddons(ctx context.Context, projectID uuid.UUID) ([]AddonResponse, error)
+	CreateAddon(ctx context.Context, projectID uuid.UUID, req CreateAddonRequest) (*AddonResponse, error)
+	GetAddon(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID) (*AddonResponse, error)
+	UpdateAddon(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID, req UpdateAddonRequest) (*AddonResponse, error)
+
+	ListAddonFeatures(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID) ([]AddonFeatureResponse, error)
+	AssignFeature(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID, req AssignAddonFeatureRequest) (*AddonFeatureResponse, error)
+
+	ListTenantAddons(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]TenantAddonResponse, error)
+	AttachAddon(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req AttachAddonRequest) (*TenantAddonResponse, error)
+	DetachAddon(ctx context.Context, tenantID uuid.UUID, addonID uuid.UUID, projectID *uuid.UUID) error
+}
+
+type addonService struct {
+	repo        AddonRepository
+	featureRepo features.FeatureRepository
+	projectRepo projects.ProjectRepository
+}
+
+func NewAddonService(repo AddonRepository, featureRepo features.FeatureRepository, projectRepo projects.ProjectRepository) AddonService {
+	return &addonService{repo: repo, featureRepo: featureRepo, projectRepo: projectRepo}
+}
+
+func (s *addonService) ListAddons(ctx context.Context, projectID uuid.UUID) ([]AddonResponse, error) {
+	return s.repo.List(ctx, projectID)
+}
+
+func (s *addonService) CreateAddon(ctx context.Context, projectID uuid.UUID, req CreateAddonRequest) (*AddonResponse, error) {
+	// validate project exists
+	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
+		return nil, errors.New("project not found")
+	}
+	if normalizeCode(req.Code) == "" {
+		return nil, errors.New("code is required")
+	}
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	// code unique within project
+	if _, err := s.repo.GetByCode(ctx, projectID, req.Code); err == nil {
+		return nil, errors.New("addon code already exists")
+	} else if err.Error() != "addon not found" {
+		return nil, err
+	}
+	return s.repo.Create(ctx, projectID, req)
+}
+
+func (s *addonService) GetAddon(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID) (*AddonResponse, error) {
+	return s.repo.GetByID(ctx, projectID, addonID)
+}
+
+func (s *addonService) UpdateAddon(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID, req UpdateAddonRequest) (*AddonResponse, error) {
+	if req.Name != nil && *req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	return s.repo.Update(ctx, projectID, addonID, req)
+}
+
+func (s *addonService) ListAddonFeatures(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID) ([]AddonFeatureResponse, error) {
+	if _, err := s.repo.GetByID(ctx, projectID, addonID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListFeatures(ctx, projectID, addonID)
+}
+
+// AssignFeature valida el valor con las mismas reglas que los valores de plan
+func (s *addonService) AssignFeature(ctx context.Context, projectID uuid.UUID, addonID uuid.UUID, req AssignAddonFeatureRequest) (*AddonFeatureResponse, error) {
+	if _, err := s.repo.GetByID(ctx, projectID, addonID); err != nil {
+		return nil, err
+	}
+	feature, err := s.featureRepo.GetByID(ctx, projectID, req.FeatureID)
+	if err != nil {
+		return nil, errors.New("feature not found")
+	}
+	if err := features.ValidateValue(feature.Type, req.Value); err != nil {
+		return nil, err
+	}
+	return s.repo.AssignFeature(ctx, projectID, addonID, req)
+}
+
+func (s *addonService) ListTenantAddons(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]TenantAddonResponse, error) {
+	return s.repo.ListByTenant(ctx, tenantID, projectID)
+}
+
+func (s *addonService) AttachAddon(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req AttachAddonRequest) (*TenantAddonResponse, error) {
+	if projectID == uuid.Nil {
+		return nil, errors.New("project_id is required")
+	}
+	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
+		return nil, errors.New("project not found")
+	}
+
+	// add-on by id or code, always within the project
+	var (
+		addon *AddonResponse
+		err   error
+	)
+	switch {
+	case req.AddonID != nil:
+		addon, err = s.repo.GetByID(ctx, projectID, *req.AddonID)
+	case req.AddonCode != nil:
+		addon, err = s.repo.GetByCode(ctx, projectID, *req.AddonCode)
+	default:
+		return nil, errors.New("addon_id or addon_code is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !addon.IsActive {
+		return nil, errors.New("addon is not active")
+	}
+	return s.repo.Attach(ctx, tenantID, projectID, addon.ID)
+}
+
+func (s *addonService) DetachAddon(ctx context.Context, tenantID uuid.UUID, addonID uuid.UUID, projectID *uuid.UUID) error {
+	return s.repo.Detach(ctx, tenantID, addonID, projectID)
+}
@@ -0,0 +1,100 @@
+package admintokens
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type AdminTokenHandler struct {
+	service AdminTokenService
+}
+
+func NewAdminTokenHandler(s AdminTokenService) *AdminTokenHandler {
+	return &AdminTokenHandler{service: s}
+}
+
+// ListTokens godoc
+// @Summary List admin tokens
+// @Description List admin token metadata (hashes and raw tokens are never returned)
+// @Tags admintokens
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Success 200 {array} admintokens.AdminTokenResponse
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tokens [get]
+func (h *AdminTokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	ts, err := h.service.ListTokens(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	utils.JSON(w, http.StatusOK, ts)
+}
+
+// CreateToken godoc
+// @Summary Create an admin token
+// @Description Create a new admin token. The raw token is returned only once.
+// @Tags admintokens
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param body body admintokens.CreateAdminTokenRequest true "Create admin token"
+// @Success 201 {object} admintokens.CreateAdminTokenResult
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tokens [post]
+func (h *AdminTokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateAdminTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	if req.Name == "" {
+		utils.Error(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	res, err := h.service.CreateToken(r.Context(), req)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusCreated, res)
+}
+
+// RevokeToken godoc
+// @Summary Revoke an admin token
+// @Description Revoke an admin token by ID
+// @Tags admintokens
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tokenId path string true "Admin token ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tokens/{tokenId}/revoke [post]
+func (h *AdminTokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "tokenId")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid token ID format")
+		return
+	}
+	if err := h.service.RevokeToken(r.Context(), id); err != nil {
+		if err.Error() == "admin token not found" {
+			utils.Error(w, http.StatusNotFound, "admin token not found")
+			return
+		}
+		utils.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,47 @@
+package admintokens
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Para DB (Scan)
+type AdminToken struct {
+	ID          uuid.UUID  `db:"id"`
+	Name        string     `db:"name"`
+	TokenHash   string     `db:"token_hash"`
+	TokenPrefix string     `db:"token_prefix"`
+	Revoked     bool       `db:"revoked"`
+	LastUsedAt  *time.Time `db:"last_used_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+}
+
+type CreateAdminTokenRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateAdminTokenResult struct {
+	RawToken string             `json:"raw_token"`
+	Token    AdminTokenResponse `json:"token"`
+}
+
+type AdminTokenResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Revoked     bool       `json:"revoked"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func ToResponse(t *AdminToken) *AdminTokenResponse {
+	return &AdminTokenResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		TokenPrefix: t.TokenPrefix,
+		Revoked:     t.Revoked,
+		LastUsedAt:  t.LastUsedAt,
+		CreatedAt:   t.CreatedAt,
+	}
+}
@@ -0,0 +1,128 @@
+package admintokens
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type AdminTokenRepository interface {
+	List(ctx context.Context) ([]AdminTokenResponse, error)
+	Create(ctx context.Context, name string, rawToken string) (*AdminTokenResponse, error)
+	CreateIfMissing(ctx context.Context, name string, rawToken string) error
+	Revoke(ctx context.Context, id uuid.UUID) error
+	Validate(ctx context.Context, rawToken string) (*AdminTokenResponse, error)
+}
+
+type adminTokenRepository struct {
+	db *sql.DB
+}
+
+func NewAdminTokenRepository(db *sql.DB) AdminTokenRepository {
+	return &adminTokenRepository{db: db}
+}
+
+// mismo esquema que api_keys.key_hash: solo se guarda el hash
+func hashToken(raw string) string {
+	h := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(h[:])
+}
+
+func prefixOf(raw string) string {
+	if len(raw) <= 12 {
+		return raw
+	}
+	return raw[:12]
+}
+
+func (r *adminTokenRepository) List(ctx context.Context) ([]AdminTokenResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, token_hash, token_prefix, revoked, last_used_at, created_at
+         FROM admin_tokens
+         ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("list admin tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []AdminTokenResponse
+	for rows.Next() {
+		t := &AdminToken{}
+		if err := rows.Scan(&t.ID, &t.Name, &t.TokenHash, &t.TokenPrefix,
+			&t.Revoked, &t.LastUsedAt, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan admin token: %w", err)
+		}
+		tokens = append(tokens, *ToResponse(t))
+	}
+	return tokens, rows.Err()
+}
+
+func (r *adminTokenRepository) Create(ctx context.Context, name string, rawToken string) (*AdminTokenResponse, error) {
+	t := &AdminToken{}
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO admin_tokens (id, name, token_hash, token_prefix)
+         VALUES ($1, $2, $3, $4)
+         RETURNING id, name, token_hash, token_prefix, revoked, last_used_at, created_at`,
+		uuid.New(), name, hashToken(rawToken), prefixOf(rawToken)).
+		Scan(&t.ID, &t.Name, &t.TokenHash, &t.TokenPrefix,
+			&t.Revoked, &t.LastUsedAt, &t.CreatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("create admin token: %w", err)
+	}
+	return ToResponse(t), nil
+}
+
+// CreateIfMissing siembra un token bootstrap; si el hash ya existe (incluso revocado) no hace nada
+func (r *adminTokenRepository) CreateIfMissing(ctx context.Context, name string, rawToken string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO admin_tokens (id, name, token_hash, token_prefix)
+         VALUES ($1, $2, $3, $4)
+         ON CONFLICT (token_hash) DO NOTHING`,
+		uuid.New(), name, hashToken(rawToken), prefixOf(rawToken))
+	if err != nil {
+		return fmt.Errorf("seed admin token: %w", err)
+	}
+	return nil
+}
+
+func (r *adminTokenRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE admin_tokens SET revoked = true WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("revoke admin token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("admin token not found")
+	}
+	return nil
+}
+
+func (r *adminTokenRepository) Validate(ctx context.Context, rawToken string) (*AdminTokenResponse, error) {
+	t := &AdminToken{}
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE admin_tokens SET last_used_at = NOW()
+         WHERE token_hash = $1 AND revoked = false
+         RETURNING id, name, token_hash, token_prefix, revoked, last_used_at, created_at`,
+		hashToken(rawToken)).
+		Scan(&t.ID, &t.Name, &t.TokenHash, &t.TokenPrefix,
+			&t.Revoked, &t.LastUsedAt, &t.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("invalid admin token")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("validate admin token: %w", err)
+	}
+	return ToResponse(t), nil
+}
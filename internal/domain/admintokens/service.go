@@ -0,0 +1,82 @@
+package admintokens
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type AdminTokenService interface {
+	ListTokens(ctx context.Context) ([]AdminTokenResponse, error)
+	CreateToken(ctx context.Context, req CreateAdminTokenRequest) (*CreateAdminTokenResult, error)
+	RevokeToken(ctx context.Context, id uuid.UUID) error
+	ValidateAdminToken(ctx context.Context, rawToken string) (uuid.UUID, error) // returns admin token ID
+	Bootstrap(ctx context.Context, rawTokens []string) error
+}
+
+type adminTokenService struct {
+	repo AdminTokenRepository
+}
+
+func NewAdminTokenService(repo AdminTokenRepository) AdminTokenService {
+	return &adminTokenService{repo: repo}
+}
+
+func genRawToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate admin token: %w", err)
+	}
+	return "pfadm_" + hex.EncodeToString(b), nil
+}
+
+func (s *adminTokenService) ListTokens(ctx context.Context) ([]AdminTokenResponse, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *adminTokenService) CreateToken(ctx context.Context, req CreateAdminTokenRequest) (*CreateAdminTokenResult, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	raw, err := genRawToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.repo.Create(ctx, name, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &CreateAdminTokenResult{RawToken: raw, Token: *res}, nil
+}
+
+func (s *adminTokenService) RevokeToken(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Revoke(ctx, id)
+}
+
+func (s *adminTokenService) ValidateAdminToken(ctx context.Context, rawToken string) (uuid.UUID, error) {
+	res, err := s.repo.Validate(ctx, rawToken)
+	if err != nil {
+		return uuid.Nil, errors.New("invalid admin token")
+	}
+	return res.ID, nil
+}
+
+// Bootstrap siembra los tokens definidos en config (idempotente)
+func (s *adminTokenService) Bootstrap(ctx context.Context, rawTokens []string) error {
+	for i, raw := range rawTokens {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if err := s.repo.CreateIfMissing(ctx, fmt.Sprintf("bootstrap-%d", i+1), raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
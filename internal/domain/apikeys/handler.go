@@ -2,7 +2,9 @@ package apikeys
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"strings"
 
 	"plans-features/internal/utils"
 
@@ -18,14 +20,60 @@ func NewAPIKeyHandler(s APIKeyService) *APIKeyHandler {
 	return &APIKeyHandler{service: s}
 }
 
+// errorStatus traduce los errores de validación del servicio a códigos HTTP
+func errorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "project not found" || msg == "api key not found" || msg == "no api keys found":
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "invalid scope"),
+		strings.HasPrefix(msg, "invalid cidr"),
+		strings.HasSuffix(msg, "is required"),
+		strings.HasSuffix(msg, "must be in the future"),
+		strings.HasSuffix(msg, "must be >= 0"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListKeys godoc
+// @Summary List API keys for a project
+// @Description List metadata of every API key of the project (raw keys and hashes are never returned)
+// @Tags apikeys
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param projectId path string true "Project ID"
+// @Success 200 {array} apikeys.APIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/projects/{projectId}/apikeys [get]
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	projectIDstr := chi.URLParam(r, "projectId")
+	id, err := uuid.Parse(projectIDstr)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
+		return
+	}
+	keys, err := h.service.ListKeys(r.Context(), id)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, keys)
+}
+
 // CreateKey godoc
 // @Summary Create API key for a project
-// @Description Create a new API key for the specified project (admin)
+// @Description Create a new API key for the specified project (admin). Existing keys stay active.
 // @Tags apikeys
 // @Accept json
 // @Produce json
-// @Param X-API-Key header string true "Admin API Key"
+// @Param X-Admin-Token header string true "Admin token"
 // @Param projectId path string true "Project ID"
+// @Param body body apikeys.CreateAPIKeyRequest false "Label, scopes and expiry"
 // @Success 201 {object} apikeys.CreateAPIKeyResult
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
@@ -38,9 +86,14 @@ func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
 		return
 	}
-	res, err := h.service.CreateKey(r.Context(), id)
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.CreateKey(r.Context(), id, req)
 	if err != nil {
-		utils.Error(w, http.StatusInternalServerError, err.Error())
+		utils.Error(w, errorStatus(err), err.Error())
 		return
 	}
 	// raw key returned only once
@@ -49,15 +102,17 @@ func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
 
 // RotateKey godoc
 // @Summary Rotate API key for a project
-// @Description Rotate the API key for the specified project (admin)
+// @Description Issue a replacement for one key (admin). The previous key stays valid for the grace period.
 // @Tags apikeys
 // @Accept json
 // @Produce json
-// @Param X-API-Key header string true "Admin API Key"
+// @Param X-Admin-Token header string true "Admin token"
 // @Param projectId path string true "Project ID"
+// @Param body body apikeys.RotateAPIKeyRequest true "Key to rotate"
 // @Success 200 {object} apikeys.CreateAPIKeyResult
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /admin/projects/{projectId}/apikeys/rotate [post]
 func (h *APIKeyHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
@@ -67,9 +122,55 @@ func (h *APIKeyHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
 		return
 	}
-	res, err := h.service.RotateKey(r.Context(), id)
+	var req RotateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.RotateKey(r.Context(), id, req)
 	if err != nil {
-		utils.Error(w, http.StatusInternalServerError, err.Error())
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// UpdateKey godoc
+// @Summary Update an API key
+// @Description Update the label and/or the IP allowlist (allowed_cidrs) of an API key. An empty allowed_cidrs list removes the restriction. Requests from outside the allowlist are rejected with 403.
+// @Tags apikeys
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param projectId path string true "Project ID"
+// @Param keyId path string true "API key ID"
+// @Param body body apikeys.UpdateAPIKeyRequest true "Fields to update"
+// @Success 200 {object} apikeys.APIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/projects/{projectId}/apikeys/{keyId} [patch]
+func (h *APIKeyHandler) UpdateKey(w http.ResponseWriter, r *http.Request) {
+	projectIDstr := chi.URLParam(r, "projectId")
+	id, err := uuid.Parse(projectIDstr)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
+		return
+	}
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid key ID format")
+		return
+	}
+	var req UpdateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.UpdateKey(r.Context(), id, keyID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
 		return
 	}
 	utils.JSON(w, http.StatusOK, res)
@@ -77,16 +178,17 @@ func (h *APIKeyHandler) RotateKey(w http.ResponseWriter, r *http.Request) {
 
 // RevokeKey godoc
 // @Summary Revoke API key(s) for a project
-// @Description Revoke API key(s) for a project (admin). Optionally provide key_prefix in body.
+// @Description Revoke API key(s) for a project (admin). Provide key_id or key_prefix in body; an empty body revokes every key.
 // @Tags apikeys
 // @Accept json
 // @Produce json
-// @Param X-API-Key header string true "Admin API Key"
+// @Param X-Admin-Token header string true "Admin token"
 // @Param projectId path string true "Project ID"
 // @Param body body apikeys.RevokeAPIKeyRequest false "Revoke options"
 // @Success 204
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /admin/projects/{projectId}/apikeys/revoke [post]
 func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
@@ -100,12 +202,8 @@ func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		// allow empty body
 	}
-	var prefix *string
-	if req.KeyPrefix != nil {
-		prefix = req.KeyPrefix
-	}
-	if err := h.service.RevokeKey(r.Context(), id, prefix); err != nil {
-		utils.Error(w, http.StatusInternalServerError, err.Error())
+	if err := h.service.RevokeKey(r.Context(), id, req); err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
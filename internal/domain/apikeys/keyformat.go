@@ -0,0 +1,102 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Formato de key: pf_<env>_<id>_<secret>_<checksum>
+//   - env: "live" o "test"
+//   - id: 16 hex (8 bytes aleatorios), identifica la key sin consultar la BD
+//   - secret: 52 chars base32 (32 bytes de crypto/rand)
+//   - checksum: crc32 en hex de "pf_<env>_<id>_<secret>", permite descartar
+//     keys mal formadas (y a los secret scanners detectarlas) sin ir a la BD
+//
+// El key_prefix guardado es "pf_<env>_<id>".
+const (
+	keyTag       = "pf"
+	idBytes      = 8
+	secretBytes  = 32
+	idLen        = idBytes * 2
+	secretLen    = 52
+	checksumLen  = 8
+	keyPartCount = 5
+)
+
+var secretEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// IsValidEnvironment indica si env es un entorno de key soportado
+func IsValidEnvironment(env string) bool {
+	return env == "live" || env == "test"
+}
+
+// genRawKey genera una key nueva con crypto/rand
+func genRawKey(env string) (string, error) {
+	id := make([]byte, idBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generate key id: %w", err)
+	}
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generate key secret: %w", err)
+	}
+	body := fmt.Sprintf("%s_%s_%s_%s", keyTag, env, hex.EncodeToString(id), secretEncoding.EncodeToString(secret))
+	return body + "_" + checksumOf(body), nil
+}
+
+func checksumOf(body string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(body)))
+}
+
+// parseKey valida la estructura y el checksum; devuelve el key_prefix
+func parseKey(raw string) (string, bool) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != keyPartCount || parts[0] != keyTag || !IsValidEnvironment(parts[1]) {
+		return "", false
+	}
+	id, secret, sum := parts[2], parts[3], parts[4]
+	if len(id) != idLen || len(secret) != secretLen || len(sum) != checksumLen {
+		return "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", false
+	}
+	if _, err := secretEncoding.DecodeString(secret); err != nil {
+		return "", false
+	}
+	if checksumOf(raw[:len(raw)-checksumLen-1]) != sum {
+		return "", false
+	}
+	return strings.Join(parts[:3], "_"), true
+}
+
+// isLegacyKey reconoce las keys antiguas (uuid.timestamp), que siguen siendo válidas
+func isLegacyKey(raw string) bool {
+	id, ts, ok := strings.Cut(raw, ".")
+	if !ok || ts == "" {
+		return false
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return false
+	}
+	for _, c := range ts {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// wellFormed descarta sin consultar la BD cualquier key que no tenga un formato conocido
+func wellFormed(raw string) bool {
+	if _, ok := parseKey(raw); ok {
+		return true
+	}
+	return isLegacyKey(raw)
+}
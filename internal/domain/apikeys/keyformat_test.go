@@ -0,0 +1,119 @@
+package apikeys
+
+import (
+	"strings"
+	"testing"
+)
+
+// withChecksum recalcula el checksum de body para que el caso falle por otro motivo
+func withChecksum(body string) string {
+	return body + "_" + checksumOf(body)
+}
+
+func TestGenRawKeyRoundTrip(t *testing.T) {
+	for _, env := range []string{"live", "test"} {
+		t.Run(env, func(t *testing.T) {
+			raw, err := genRawKey(env)
+			if err != nil {
+				t.Fatalf("genRawKey: %v", err)
+			}
+			parts := strings.Split(raw, "_")
+			if len(parts) != keyPartCount {
+				t.Fatalf("key %q has %d parts, want %d", raw, len(parts), keyPartCount)
+			}
+			prefix, ok := parseKey(raw)
+			if !ok {
+				t.Fatalf("parseKey(%q) rejected a generated key", raw)
+			}
+			if want := keyTag + "_" + env + "_" + parts[2]; prefix != want {
+				t.Errorf("prefix = %q, want %q", prefix, want)
+			}
+			if !wellFormed(raw) {
+				t.Errorf("wellFormed(%q) = false", raw)
+			}
+		})
+	}
+}
+
+func TestGenRawKeyUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		raw, err := genRawKey("live")
+		if err != nil {
+			t.Fatalf("genRawKey: %v", err)
+		}
+		prefix, _ := parseKey(raw)
+		if seen[prefix] {
+			t.Fatalf("duplicate key prefix %q", prefix)
+		}
+		seen[prefix] = true
+	}
+}
+
+func TestParseKeyRejects(t *testing.T) {
+	valid, err := genRawKey("live")
+	if err != nil {
+		t.Fatalf("genRawKey: %v", err)
+	}
+	parts := strings.Split(valid, "_")
+	id, secret, sum := parts[2], parts[3], parts[4]
+
+	// cambiar un carácter del secret sin tocar el checksum
+	flipped := []byte(secret)
+	if flipped[0] == 'a' {
+		flipped[0] = 'b'
+	} else {
+		flipped[0] = 'a'
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"bad checksum", strings.Join([]string{"pf", "live", id, secret, "00000000"}, "_")},
+		{"tampered secret", strings.Join([]string{"pf", "live", id, string(flipped), sum}, "_")},
+		{"checksum of other env", strings.Join([]string{"pf", "test", id, secret, sum}, "_")},
+		{"wrong env", withChecksum(strings.Join([]string{"pf", "prod", id, secret}, "_"))},
+		{"wrong tag", withChecksum(strings.Join([]string{"sk", "live", id, secret}, "_"))},
+		{"truncated checksum", valid[:len(valid)-1]},
+		{"missing checksum", strings.Join([]string{"pf", "live", id, secret}, "_")},
+		{"truncated secret", withChecksum(strings.Join([]string{"pf", "live", id, secret[:secretLen-1]}, "_"))},
+		{"truncated id", withChecksum(strings.Join([]string{"pf", "live", id[:idLen-2], secret}, "_"))},
+		{"non-hex id", withChecksum(strings.Join([]string{"pf", "live", "zz" + id[2:], secret}, "_"))},
+		{"non-base32 secret", withChecksum(strings.Join([]string{"pf", "live", id, "1" + secret[1:]}, "_"))},
+		{"extra part", withChecksum(strings.Join([]string{"pf", "live", id, secret, sum}, "_"))},
+		{"prefix only", strings.Join([]string{"pf", "live", id}, "_")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if prefix, ok := parseKey(tt.raw); ok {
+				t.Errorf("parseKey(%q) = %q, true; want rejected", tt.raw, prefix)
+			}
+			if wellFormed(tt.raw) {
+				t.Errorf("wellFormed(%q) = true", tt.raw)
+			}
+		})
+	}
+}
+
+func TestIsLegacyKey(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"6f1c2a8e-3b4d-4c5e-9f60-718293a4b5c6.1700000000", true},
+		{"6f1c2a8e-3b4d-4c5e-9f60-718293a4b5c6.", false},
+		{"6f1c2a8e-3b4d-4c5e-9f60-718293a4b5c6", false},
+		{"6f1c2a8e-3b4d-4c5e-9f60-718293a4b5c6.17000x", false},
+		{"not-a-uuid.1700000000", false},
+	}
+	for _, tt := range tests {
+		if got := isLegacyKey(tt.raw); got != tt.want {
+			t.Errorf("isLegacyKey(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+		if got := wellFormed(tt.raw); got != tt.want {
+			t.Errorf("wellFormed(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
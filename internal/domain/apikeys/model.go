@@ -8,31 +8,67 @@ import (
 
 // Para DB (Scan)
 type APIKey struct {
-	ID        uuid.UUID `db:"id"`
-	ProjectID uuid.UUID `db:"project_id"`
-	KeyHash   string    `db:"key_hash"`
-	KeyPrefix string    `db:"key_prefix"`
-	Revoked   bool      `db:"revoked"`
-	CreatedAt time.Time `db:"created_at"`
+	ID           uuid.UUID  `db:"id"`
+	ProjectID    uuid.UUID  `db:"project_id"`
+	KeyHash      string     `db:"key_hash"`
+	HashVersion  int        `db:"hash_version"`
+	KeyPrefix    string     `db:"key_prefix"`
+	Label        string     `db:"label"`
+	Revoked      bool       `db:"revoked"`
+	Scopes       []string   `db:"scopes"`
+	AllowedCIDRs []string   `db:"allowed_cidrs"`
+	ExpiresAt    *time.Time `db:"expires_at"`
+	LastUsedAt   *time.Time `db:"last_used_at"`
+	CreatedAt    time.Time  `db:"created_at"`
 }
 
 type CreateAPIKeyResult struct {
 	RawKey string         `json:"raw_key"`
 	Key    APIKeyResponse `json:"key"`
+	// Previous: key sustituida en una rotación, válida hasta su expires_at
+	Previous *APIKeyResponse `json:"previous,omitempty"`
 }
 
-type CreateAPIKeyRequest struct{} // Vacío por ahora
+// CreateAPIKeyRequest: sin scopes la key recibe todos (auth.AllScopes);
+// sin allowed_cidrs la key se puede usar desde cualquier IP.
+type CreateAPIKeyRequest struct {
+	Label        string     `json:"label,omitempty"`
+	Scopes       []string   `json:"scopes,omitempty"`
+	AllowedCIDRs []string   `json:"allowed_cidrs,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// UpdateAPIKeyRequest: solo se modifican los campos presentes;
+// allowed_cidrs: [] elimina la restricción.
+type UpdateAPIKeyRequest struct {
+	Label        *string   `json:"label,omitempty"`
+	AllowedCIDRs *[]string `json:"allowed_cidrs,omitempty"`
+}
+
+// RotateAPIKeyRequest identifica la key a rotar (key_id o key_prefix).
+// GracePeriodSeconds sustituye el periodo de gracia configurado.
+type RotateAPIKeyRequest struct {
+	KeyID              *uuid.UUID `json:"key_id,omitempty"`
+	KeyPrefix          *string    `json:"key_prefix,omitempty"`
+	GracePeriodSeconds *int       `json:"grace_period_seconds,omitempty"`
+}
 
 type RevokeAPIKeyRequest struct {
-	KeyPrefix *string `json:"key_prefix,omitempty"`
+	KeyID     *uuid.UUID `json:"key_id,omitempty"`
+	KeyPrefix *string    `json:"key_prefix,omitempty"`
 }
 
 type APIKeyResponse struct {
-	ID        uuid.UUID `json:"id"`
-	ProjectID uuid.UUID `json:"project_id"`
-	KeyPrefix string    `json:"key_prefix"`
-	CreatedAt time.Time `json:"created_at"`
-	Revoked   bool      `json:"revoked"`
+	ID           uuid.UUID  `json:"id"`
+	ProjectID    uuid.UUID  `json:"project_id"`
+	KeyPrefix    string     `json:"key_prefix"`
+	Label        string     `json:"label"`
+	Scopes       []string   `json:"scopes"`
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	Revoked      bool       `json:"revoked"`
 }
 
 // Interno para DB (sin uuid.UUID para Scan simple)
@@ -47,10 +83,15 @@ type apiKeyEntity struct {
 
 func ToResponse(apiKey *APIKey) *APIKeyResponse {
 	return &APIKeyResponse{
-		ID:        apiKey.ID,
-		ProjectID: apiKey.ProjectID,
-		KeyPrefix: apiKey.KeyPrefix,
-		CreatedAt: apiKey.CreatedAt,
-		Revoked:   apiKey.Revoked,
+		ID:           apiKey.ID,
+		ProjectID:    apiKey.ProjectID,
+		KeyPrefix:    apiKey.KeyPrefix,
+		Label:        apiKey.Label,
+		Scopes:       apiKey.Scopes,
+		AllowedCIDRs: apiKey.AllowedCIDRs,
+		CreatedAt:    apiKey.CreatedAt,
+		ExpiresAt:    apiKey.ExpiresAt,
+		LastUsedAt:   apiKey.LastUsedAt,
+		Revoked:      apiKey.Revoked,
 	}
 }
@@ -2,77 +2,285 @@ package apikeys
 
 import (
 	"context"
+	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type APIKeyRepository interface {
-	Create(ctx context.Context, projectID uuid.UUID, rawKey string) (*APIKeyResponse, error)
-	Rotate(ctx context.Context, projectID uuid.UUID, rawKey string) (*APIKeyResponse, error)
-	Revoke(ctx context.Context, projectID uuid.UUID, keyPrefix *string) error
+	List(ctx context.Context, projectID uuid.UUID) ([]APIKeyResponse, error)
+	Create(ctx context.Context, projectID uuid.UUID, rawKey string, req CreateAPIKeyRequest) (*APIKeyResponse, error)
+	Rotate(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID, rawKey string, graceUntil time.Time) (*APIKeyResponse, *APIKeyResponse, error)
+	GetByPrefix(ctx context.Context, projectID uuid.UUID, keyPrefix string) (*APIKeyResponse, error)
+	Update(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID, req UpdateAPIKeyRequest) (*APIKeyResponse, error)
+	Revoke(ctx context.Context, projectID uuid.UUID, req RevokeAPIKeyRequest) error
 	Validate(ctx context.Context, rawKey string) (*APIKeyResponse, error)
 }
 
 type apiKeyRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	pepper []byte
 }
 
-func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
-	return &apiKeyRepository{db: db}
+// NewAPIKeyRepository: con pepper las keys se guardan como HMAC-SHA256 (hash_version 2);
+// sin pepper se mantiene el SHA-256 legado (hash_version 1).
+func NewAPIKeyRepository(db *sql.DB, pepper []byte) APIKeyRepository {
+	return &apiKeyRepository{db: db, pepper: pepper}
 }
 
-func hashKey(raw string) string {
+// Versiones de key_hash
+const (
+	hashVersionSHA256 = 1
+	hashVersionHMAC   = 2
+)
+
+const apiKeyColumns = `id, project_id, key_hash, key_prefix, label, scopes, revoked, expires_at, last_used_at, created_at, hash_version, allowed_cidrs`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	apiKey := &APIKey{}
+	var scopesJSON, cidrsJSON []byte
+	if err := row.Scan(&apiKey.ID, &apiKey.ProjectID, &apiKey.KeyHash, &apiKey.KeyPrefix,
+		&apiKey.Label, &scopesJSON, &apiKey.Revoked, &apiKey.ExpiresAt,
+		&apiKey.LastUsedAt, &apiKey.CreatedAt, &apiKey.HashVersion, &cidrsJSON); err != nil {
+		return nil, err
+	}
+	// JSONB → []string
+	if err := json.Unmarshal(scopesJSON, &apiKey.Scopes); err != nil {
+		return nil, fmt.Errorf("unmarshal scopes: %w", err)
+	}
+	if err := json.Unmarshal(cidrsJSON, &apiKey.AllowedCIDRs); err != nil {
+		return nil, fmt.Errorf("unmarshal allowed cidrs: %w", err)
+	}
+	return apiKey, nil
+}
+
+func (r *apiKeyRepository) currentHashVersion() int {
+	if len(r.pepper) == 0 {
+		return hashVersionSHA256
+	}
+	return hashVersionHMAC
+}
+
+// hashKey calcula key_hash según la versión del esquema
+func (r *apiKeyRepository) hashKey(raw string, version int) string {
+	if version == hashVersionHMAC {
+		mac := hmac.New(sha256.New, r.pepper)
+		mac.Write([]byte(raw))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
 	h := sha256.Sum256([]byte(raw))
 	return hex.EncodeToString(h[:])
 }
 
+// prefixOf: "pf_<env>_<id>" para el formato actual, 8 primeros chars para keys antiguas
 func prefixOf(raw string) string {
+	if prefix, ok := parseKey(raw); ok {
+		return prefix
+	}
 	if len(raw) <= 8 {
 		return raw
 	}
 	return raw[:8]
 }
 
-func (r *apiKeyRepository) Create(ctx context.Context, projectID uuid.UUID, rawKey string) (*APIKeyResponse, error) {
-	id := uuid.New()
-	keyHash := hashKey(rawKey)
-	keyPrefix := prefixOf(rawKey)
+func (r *apiKeyRepository) List(ctx context.Context, projectID uuid.UUID) ([]APIKeyResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+apiKeyColumns+`
+         FROM api_keys
+         WHERE project_id = $1
+         ORDER BY created_at DESC`,
+		projectID)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
 
-	apiKey := &APIKey{}
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO api_keys (id, project_id, key_hash, key_prefix, revoked) 
-         VALUES ($1, $2, $3, $4, $5) 
-         RETURNING id, project_id, key_hash, key_prefix, revoked, created_at`,
-		id, projectID, keyHash, keyPrefix, false).
-		Scan(&apiKey.ID, &apiKey.ProjectID, &apiKey.KeyHash, &apiKey.KeyPrefix,
-			&apiKey.Revoked, &apiKey.CreatedAt)
+	var keys []APIKeyResponse
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		keys = append(keys, *ToResponse(apiKey))
+	}
+	return keys, rows.Err()
+}
 
+func (r *apiKeyRepository) Create(ctx context.Context, projectID uuid.UUID, rawKey string, req CreateAPIKeyRequest) (*APIKeyResponse, error) {
+	apiKey, err := r.insertKey(ctx, r.db, projectID, rawKey, req)
 	if err != nil {
 		return nil, fmt.Errorf("create api key: %w", err)
 	}
+	return ToResponse(apiKey), nil
+}
+
+// execer permite insertar dentro o fuera de una transacción
+type execer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (r *apiKeyRepository) insertKey(ctx context.Context, q execer, projectID uuid.UUID, rawKey string, req CreateAPIKeyRequest) (*APIKey, error) {
+	// []string → JSONB
+	scopesJSON, err := json.Marshal(req.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("marshal scopes: %w", err)
+	}
+	cidrsJSON, err := marshalCIDRs(req.AllowedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	version := r.currentHashVersion()
+	return scanAPIKey(q.QueryRowContext(ctx,
+		`INSERT INTO api_keys (id, project_id, key_hash, hash_version, key_prefix, label, scopes, allowed_cidrs, revoked, expires_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, false, $9)
+         RETURNING `+apiKeyColumns,
+		uuid.New(), projectID, r.hashKey(rawKey, version), version, prefixOf(rawKey),
+		req.Label, scopesJSON, cidrsJSON, req.ExpiresAt))
+}
+
+// marshalCIDRs guarda siempre un array (nunca null) en allowed_cidrs
+func marshalCIDRs(cidrs []string) ([]byte, error) {
+	if cidrs == nil {
+		cidrs = []string{}
+	}
+	cidrsJSON, err := json.Marshal(cidrs)
+	if err != nil {
+		return nil, fmt.Errorf("marshal allowed cidrs: %w", err)
+	}
+	return cidrsJSON, nil
+}
+
+// Rotate crea una key nueva con la misma etiqueta, scopes, allowlist y rate_limits y deja
+// la anterior válida hasta graceUntil (o su expires_at si es anterior). Devuelve (nueva, anterior).
+func (r *apiKeyRepository) Rotate(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID, rawKey string, graceUntil time.Time) (*APIKeyResponse, *APIKeyResponse, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("begin rotate: %w", err)
+	}
+	defer tx.Rollback()
 
+	old, err := scanAPIKey(tx.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+`
+         FROM api_keys
+         WHERE id = $1 AND project_id = $2 AND revoked = false
+           AND (expires_at IS NULL OR expires_at > NOW())
+         FOR UPDATE`,
+		keyID, projectID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, errors.New("api key not found")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("get api key: %w", err)
+	}
+
+	created, err := r.insertKey(ctx, tx, projectID, rawKey, CreateAPIKeyRequest{
+		Label:        old.Label,
+		Scopes:       old.Scopes,
+		AllowedCIDRs: old.AllowedCIDRs,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("create rotated api key: %w", err)
+	}
+	// el override de rate limiting (columna de internal/ratelimit) pasa a la key nueva
+	_, err = tx.ExecContext(ctx,
+		`UPDATE api_keys SET rate_limits = (SELECT rate_limits FROM api_keys WHERE id = $2)
+         WHERE id = $1`,
+		created.ID, keyID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("copy api key rate limits: %w", err)
+	}
+
+	old, err = scanAPIKey(tx.QueryRowContext(ctx,
+		`UPDATE api_keys
+         SET expires_at = LEAST(COALESCE(expires_at, $1), $1)
+         WHERE id = $2
+         RETURNING `+apiKeyColumns,
+		graceUntil, keyID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("expire previous api key: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("commit rotate: %w", err)
+	}
+	return ToResponse(created), ToResponse(old), nil
+}
+
+func (r *apiKeyRepository) GetByPrefix(ctx context.Context, projectID uuid.UUID, keyPrefix string) (*APIKeyResponse, error) {
+	apiKey, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+`
+         FROM api_keys
+         WHERE project_id = $1 AND key_prefix = $2 AND revoked = false
+         ORDER BY created_at DESC
+         LIMIT 1`,
+		projectID, keyPrefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("api key not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api key by prefix: %w", err)
+	}
 	return ToResponse(apiKey), nil
 }
 
-func (r *apiKeyRepository) Rotate(ctx context.Context, projectID uuid.UUID, rawKey string) (*APIKeyResponse, error) {
-	return r.Create(ctx, projectID, rawKey)
+// Update modifica label y/o allowed_cidrs; los campos nil se conservan
+func (r *apiKeyRepository) Update(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID, req UpdateAPIKeyRequest) (*APIKeyResponse, error) {
+	var cidrsJSON []byte
+	if req.AllowedCIDRs != nil {
+		var err error
+		if cidrsJSON, err = marshalCIDRs(*req.AllowedCIDRs); err != nil {
+			return nil, err
+		}
+	}
+
+	apiKey, err := scanAPIKey(r.db.QueryRowContext(ctx,
+		`UPDATE api_keys
+         SET label = COALESCE($1, label),
+             allowed_cidrs = COALESCE($2, allowed_cidrs)
+         WHERE id = $3 AND project_id = $4
+         RETURNING `+apiKeyColumns,
+		req.Label, cidrsJSON, keyID, projectID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("api key not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update api key: %w", err)
+	}
+	return ToResponse(apiKey), nil
 }
 
-func (r *apiKeyRepository) Revoke(ctx context.Context, projectID uuid.UUID, keyPrefix *string) error {
-	if keyPrefix == nil {
-		_, err := r.db.ExecContext(ctx,
+func (r *apiKeyRepository) Revoke(ctx context.Context, projectID uuid.UUID, req RevokeAPIKeyRequest) error {
+	var (
+		result sql.Result
+		err    error
+	)
+	switch {
+	case req.KeyID != nil:
+		result, err = r.db.ExecContext(ctx,
+			`UPDATE api_keys SET revoked = true WHERE project_id = $1 AND id = $2`,
+			projectID, *req.KeyID)
+	case req.KeyPrefix != nil:
+		result, err = r.db.ExecContext(ctx,
+			`UPDATE api_keys SET revoked = true WHERE project_id = $1 AND key_prefix = $2`,
+			projectID, *req.KeyPrefix)
+	default:
+		_, err = r.db.ExecContext(ctx,
 			`UPDATE api_keys SET revoked = true WHERE project_id = $1`, projectID)
 		return err
 	}
-
-	result, err := r.db.ExecContext(ctx,
-		`UPDATE api_keys SET revoked = true WHERE project_id = $1 AND key_prefix = $2`,
-		projectID, *keyPrefix)
 	if err != nil {
 		return fmt.Errorf("revoke api key: %w", err)
 	}
@@ -82,30 +290,61 @@ func (r *apiKeyRepository) Revoke(ctx context.Context, projectID uuid.UUID, keyP
 		return fmt.Errorf("check affected rows: %w", err)
 	}
 	if rowsAffected == 0 {
-		return errors.New("no api keys found with that prefix")
+		return errors.New("no api keys found")
 	}
 	return nil
 }
 
-func (r apiKeyRepository) Validate(ctx context.Context, rawKey string) (*APIKeyResponse, error) {
-	keyHash := hashKey(rawKey)
-
-	apiKey := &APIKey{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, project_id, key_hash, key_prefix, revoked, created_at 
-         FROM api_keys 
-         WHERE key_hash = $1 AND revoked = false 
-         LIMIT 1`,
-		keyHash).
-		Scan(&apiKey.ID, &apiKey.ProjectID, &apiKey.KeyHash,
-			&apiKey.KeyPrefix, &apiKey.Revoked, &apiKey.CreatedAt)
+// Validate busca una key activa y no caducada: por id (key_prefix) en el formato
+// actual o por hash (cualquier versión) en las keys antiguas, y compara el hash
+// en tiempo constante. Los hashes de versiones anteriores se actualizan al esquema
+// actual tras una validación correcta.
+func (r *apiKeyRepository) Validate(ctx context.Context, rawKey string) (*APIKeyResponse, error) {
+	const query = `SELECT ` + apiKeyColumns + `
+         FROM api_keys
+         WHERE %s AND revoked = false
+           AND (expires_at IS NULL OR expires_at > NOW())
+         LIMIT 1`
+	var row *sql.Row
+	if prefix, ok := parseKey(rawKey); ok {
+		row = r.db.QueryRowContext(ctx, fmt.Sprintf(query, "key_prefix = $1"), prefix)
+	} else {
+		candidates := []string{r.hashKey(rawKey, hashVersionSHA256)}
+		if len(r.pepper) > 0 {
+			candidates = append(candidates, r.hashKey(rawKey, hashVersionHMAC))
+		}
+		row = r.db.QueryRowContext(ctx, fmt.Sprintf(query, "key_hash = ANY($1)"), candidates)
+	}
 
+	apiKey, err := scanAPIKey(row)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.New("invalid api key")
 	}
 	if err != nil {
 		return nil, fmt.Errorf("validate api key: %w", err)
 	}
+	if apiKey.HashVersion == hashVersionHMAC && len(r.pepper) == 0 {
+		return nil, errors.New("api key pepper not configured")
+	}
+	keyHash := r.hashKey(rawKey, apiKey.HashVersion)
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(keyHash)) != 1 {
+		return nil, errors.New("invalid api key")
+	}
+
+	// rehash transparente al esquema actual (best effort, la key ya es válida)
+	if current := r.currentHashVersion(); apiKey.HashVersion < current {
+		_, _ = r.db.ExecContext(ctx,
+			`UPDATE api_keys SET key_hash = $1, hash_version = $2
+             WHERE id = $3 AND hash_version = $4`,
+			r.hashKey(rawKey, current), current, apiKey.ID, apiKey.HashVersion)
+	}
+
+	// last_used_at como mucho una vez por minuto para no escribir en cada petición
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > time.Minute {
+		// best effort: un fallo aquí no invalida la key
+		_, _ = r.db.ExecContext(ctx,
+			`UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, apiKey.ID)
+	}
 
 	return ToResponse(apiKey), nil
 }
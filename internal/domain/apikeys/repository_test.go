@@ -0,0 +1,51 @@
+package apikeys
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"plans-features/internal/db/dbtest"
+)
+
+func TestRotateKeepsRateLimits(t *testing.T) {
+	f := dbtest.New(t)
+	repo := NewAPIKeyRepository(f.DB, []byte("pepper"))
+	ctx := context.Background()
+
+	raw, err := genRawKey("live")
+	if err != nil {
+		t.Fatalf("genRawKey: %v", err)
+	}
+	old, err := repo.Create(ctx, f.ProjectID, raw, CreateAPIKeyRequest{Label: "backend", Scopes: []string{"usage:write"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	_, err = f.DB.Exec(`UPDATE api_keys SET rate_limits = $2 WHERE id = $1`,
+		old.ID, `{"key": {"requests_per_second": 5, "burst": 10}}`)
+	if err != nil {
+		t.Fatalf("set rate limits: %v", err)
+	}
+
+	rotatedRaw, err := genRawKey("live")
+	if err != nil {
+		t.Fatalf("genRawKey: %v", err)
+	}
+	created, _, err := repo.Rotate(ctx, f.ProjectID, old.ID, rotatedRaw, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	var same bool
+	err = f.DB.QueryRow(
+		`SELECT n.rate_limits IS NOT NULL AND n.rate_limits = o.rate_limits
+         FROM api_keys n, api_keys o
+         WHERE n.id = $1 AND o.id = $2`,
+		created.ID, old.ID).Scan(&same)
+	if err != nil {
+		t.Fatalf("read rate limits: %v", err)
+	}
+	if !same {
+		t.Errorf("rotated key did not keep the rate_limits override")
+	}
+}
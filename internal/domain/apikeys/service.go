@@ -4,78 +4,189 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"plans-features/internal/auth"
+	"plans-features/internal/config"
 	"plans-features/internal/domain/projects"
 
 	"github.com/google/uuid"
 )
 
 type APIKeyService interface {
-	CreateKey(ctx context.Context, projectID uuid.UUID) (*CreateAPIKeyResult, error)
-	RotateKey(ctx context.Context, projectID uuid.UUID) (*CreateAPIKeyResult, error)
-	RevokeKey(ctx context.Context, projectID uuid.UUID, keyPrefix *string) error
-	ValidateKey(ctx context.Context, rawKey string) (uuid.UUID, error) // returns projectID
+	ListKeys(ctx context.Context, projectID uuid.UUID) ([]APIKeyResponse, error)
+	CreateKey(ctx context.Context, projectID uuid.UUID, req CreateAPIKeyRequest) (*CreateAPIKeyResult, error)
+	RotateKey(ctx context.Context, projectID uuid.UUID, req RotateAPIKeyRequest) (*CreateAPIKeyResult, error)
+	UpdateKey(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID, req UpdateAPIKeyRequest) (*APIKeyResponse, error)
+	RevokeKey(ctx context.Context, projectID uuid.UUID, req RevokeAPIKeyRequest) error
+	ValidateKey(ctx context.Context, rawKey string) (*auth.Principal, error) // project + scopes
 }
 
 type apiKeyService struct {
 	repo        APIKeyRepository
 	projectRepo projects.ProjectRepository
+	cfg         config.APIKeys
 }
 
-// NewAPIKeyService ahora requiere projectRepo para validar existencia de proyectos
-func NewAPIKeyService(repo APIKeyRepository, projectRepo projects.ProjectRepository) APIKeyService {
-	return &apiKeyService{repo: repo, projectRepo: projectRepo}
+// NewAPIKeyService requiere projectRepo para validar existencia de proyectos;
+// cfg aporta el entorno de las keys y el periodo de gracia de rotación.
+func NewAPIKeyService(repo APIKeyRepository, projectRepo projects.ProjectRepository, cfg config.APIKeys) APIKeyService {
+	return &apiKeyService{repo: repo, projectRepo: projectRepo, cfg: cfg}
 }
 
-func genRawKey() string {
-	// simple generator: uuid + timestamp
-	return fmt.Sprintf("%s.%d", uuid.New().String(), time.Now().Unix())
+// normalizeScopes valida los scopes pedidos; vacío = acceso completo
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return auth.AllScopes, nil
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		if !auth.IsValidScope(sc) {
+			return nil, fmt.Errorf("invalid scope: %s", sc)
+		}
+		if !seen[sc] {
+			seen[sc] = true
+			out = append(out, sc)
+		}
+	}
+	return out, nil
+}
+
+// normalizeCIDRs valida la allowlist y la guarda en forma canónica ("10.0.0.0/8")
+func normalizeCIDRs(cidrs []string) ([]string, error) {
+	prefixes, err := auth.ParseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		if !seen[p.String()] {
+			seen[p.String()] = true
+			out = append(out, p.String())
+		}
+	}
+	return out, nil
 }
 
-func (s *apiKeyService) CreateKey(ctx context.Context, projectID uuid.UUID) (*CreateAPIKeyResult, error) {
+func (s *apiKeyService) ListKeys(ctx context.Context, projectID uuid.UUID) ([]APIKeyResponse, error) {
+	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
+		return nil, errors.New("project not found")
+	}
+	return s.repo.List(ctx, projectID)
+}
+
+func (s *apiKeyService) CreateKey(ctx context.Context, projectID uuid.UUID, req CreateAPIKeyRequest) (*CreateAPIKeyResult, error) {
 	// validate project exists
 	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
 		return nil, errors.New("project not found")
 	}
-	// revoke existing active keys for project (only 1 active allowed)
-	_ = s.repo.Revoke(ctx, projectID, nil)
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	req.Scopes = scopes
+	if req.AllowedCIDRs, err = normalizeCIDRs(req.AllowedCIDRs); err != nil {
+		return nil, err
+	}
+	req.Label = strings.TrimSpace(req.Label)
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
 
-	raw := genRawKey()
-	res, err := s.repo.Create(ctx, projectID, raw)
+	raw, err := genRawKey(s.cfg.Environment)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.repo.Create(ctx, projectID, raw, req)
 	if err != nil {
 		return nil, err
 	}
 	return &CreateAPIKeyResult{RawKey: raw, Key: *res}, nil
 }
 
-func (s *apiKeyService) RotateKey(ctx context.Context, projectID uuid.UUID) (*CreateAPIKeyResult, error) {
+// RotateKey emite una key nueva con la misma etiqueta, scopes, allowlist y rate_limits;
+// la anterior sigue siendo válida durante el periodo de gracia.
+func (s *apiKeyService) RotateKey(ctx context.Context, projectID uuid.UUID, req RotateAPIKeyRequest) (*CreateAPIKeyResult, error) {
 	// validate project exists
 	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
 		return nil, errors.New("project not found")
 	}
-	// revoke previous
-	_ = s.repo.Revoke(ctx, projectID, nil)
 
-	raw := genRawKey()
-	res, err := s.repo.Rotate(ctx, projectID, raw)
+	var keyID uuid.UUID
+	switch {
+	case req.KeyID != nil:
+		keyID = *req.KeyID
+	case req.KeyPrefix != nil:
+		k, err := s.repo.GetByPrefix(ctx, projectID, *req.KeyPrefix)
+		if err != nil {
+			return nil, err
+		}
+		keyID = k.ID
+	default:
+		return nil, errors.New("key_id or key_prefix is required")
+	}
+
+	grace := s.cfg.RotationGracePeriod
+	if req.GracePeriodSeconds != nil {
+		if *req.GracePeriodSeconds < 0 {
+			return nil, errors.New("grace_period_seconds must be >= 0")
+		}
+		grace = time.Duration(*req.GracePeriodSeconds) * time.Second
+	}
+
+	raw, err := genRawKey(s.cfg.Environment)
 	if err != nil {
 		return nil, err
 	}
-	return &CreateAPIKeyResult{RawKey: raw, Key: *res}, nil
+	res, previous, err := s.repo.Rotate(ctx, projectID, keyID, raw, time.Now().Add(grace))
+	if err != nil {
+		return nil, err
+	}
+	return &CreateAPIKeyResult{RawKey: raw, Key: *res, Previous: previous}, nil
 }
 
-func (s *apiKeyService) RevokeKey(ctx context.Context, projectID uuid.UUID, keyPrefix *string) error {
+func (s *apiKeyService) UpdateKey(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID, req UpdateAPIKeyRequest) (*APIKeyResponse, error) {
+	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
+		return nil, errors.New("project not found")
+	}
+	if req.Label != nil {
+		label := strings.TrimSpace(*req.Label)
+		req.Label = &label
+	}
+	if req.AllowedCIDRs != nil {
+		cidrs, err := normalizeCIDRs(*req.AllowedCIDRs)
+		if err != nil {
+			return nil, err
+		}
+		req.AllowedCIDRs = &cidrs
+	}
+	return s.repo.Update(ctx, projectID, keyID, req)
+}
+
+func (s *apiKeyService) RevokeKey(ctx context.Context, projectID uuid.UUID, req RevokeAPIKeyRequest) error {
 	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
 		return errors.New("project not found")
 	}
-	return s.repo.Revoke(ctx, projectID, keyPrefix)
+	return s.repo.Revoke(ctx, projectID, req)
 }
 
-func (s *apiKeyService) ValidateKey(ctx context.Context, rawKey string) (uuid.UUID, error) {
+func (s *apiKeyService) ValidateKey(ctx context.Context, rawKey string) (*auth.Principal, error) {
+	// formato o checksum inválido: se rechaza sin consultar la BD
+	if !wellFormed(rawKey) {
+		return nil, errors.New("invalid api key")
+	}
 	res, err := s.repo.Validate(ctx, rawKey)
 	if err != nil {
-		return uuid.Nil, errors.New("invalid api key")
+		return nil, errors.New("invalid api key")
 	}
-	return res.ProjectID, nil
+	return &auth.Principal{
+		ProjectID:    res.ProjectID,
+		KeyID:        res.ID,
+		Scopes:       res.Scopes,
+		Source:       auth.SourceAPIKey,
+		AllowedCIDRs: res.AllowedCIDRs,
+	}, nil
 }
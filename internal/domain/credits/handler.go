@@ -0,0 +1,199 @@
+package credits
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"plans-features/internal/auth"
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type CreditHandler struct {
+	service CreditService
+}
+
+func NewCreditHandler(s CreditService) *CreditHandler {
+	return &CreditHandler{service: s}
+}
+
+// errorStatus traduce los errores del servicio a códigos HTTP
+func errorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "feature not found":
+		return http.StatusNotFound
+	case msg == "idempotency_key already used":
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "amount must"),
+		strings.HasPrefix(msg, "quantity must"),
+		strings.HasPrefix(msg, "expires_at must"),
+		strings.HasPrefix(msg, "limit must"),
+		strings.HasSuffix(msg, "is required"),
+		msg == "feature has no credit_cost":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// tenantAndProject lee el tenant de la ruta y el proyecto de la API key
+// (o de la ruta admin, vía auth.AdminProject)
+func tenantAndProject(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return uuid.Nil, uuid.Nil, false
+	}
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, projectID, true
+}
+
+// GetBalance godoc
+// @Summary Get the tenant's credit balance
+// @Description Current credit balance, the overdraft allowed by the tenant's plan, what can still be debited (available = balance + overdraft) and the unexpired grants with credits left, in the order they are consumed (soonest expiry first).
+// @Tags credits
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Success 200 {object} credits.BalanceResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/credits [get]
+func (h *CreditHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.service.GetBalance(r.Context(), tenantID, projectID)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// ListLedger godoc
+// @Summary List the tenant's credit ledger
+// @Description Append-only credit movements of the tenant, newest first: grants (positive), debits and expirations (negative), each with the balance after it.
+// @Tags credits
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param limit query int false "Max items (default 100, max 1000)"
+// @Success 200 {array} credits.EntryResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/credits/ledger [get]
+func (h *CreditHandler) ListLedger(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	var limit int
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			utils.Error(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+	list, err := h.service.ListLedger(r.Context(), tenantID, projectID, limit)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// GrantCredits godoc
+// @Summary Grant credits to a tenant
+// @Description Adds amount credits to the tenant's balance (e.g. a purchased pack). With expires_at, whatever is left of the grant at that time is removed with an expiration entry. If the balance is negative the grant pays off the overdraft first. A repeated idempotency_key returns the original grant.
+// @Tags credits
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param body body credits.GrantRequest true "Grant"
+// @Success 201 {object} credits.EntryResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/credits/grants [post]
+func (h *CreditHandler) GrantCredits(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	var req GrantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.GrantCredits(r.Context(), tenantID, projectID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusCreated, res)
+}
+
+// DebitCredits godoc
+// @Summary Debit credits for a feature
+// @Description Atomically debits quantity (default 1) times the feature's credit_cost from the tenant's balance, consuming the grants that expire soonest first. Concurrent debits from any replica are serialized per tenant. The balance never goes below minus the overdraft allowed by the tenant's plan (0 by default); otherwise returns 409 with the cost and the current balance. A repeated idempotency_key is not debited twice.
+// @Tags credits
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param body body credits.DebitRequest true "Debit"
+// @Success 200 {object} credits.DebitResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/credits/debits [post]
+func (h *CreditHandler) DebitCredits(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	var req DebitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.DebitCredits(r.Context(), tenantID, projectID, req)
+	if errors.Is(err, ErrInsufficientCredits) {
+		utils.JSON(w, http.StatusConflict, map[string]interface{}{
+			"error":     err.Error(),
+			"cost":      res.Cost,
+			"balance":   res.Balance,
+			"available": res.Available,
+		})
+		return
+	}
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
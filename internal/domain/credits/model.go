@@ -0,0 +1,120 @@
+package credits
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tipos de movimiento del ledger
+const (
+	EntryGrant      = "grant"      // suma créditos (compra de un pack, regalo...)
+	EntryDebit      = "debit"      // resta el coste de usar una feature
+	EntryExpiration = "expiration" // resta lo que quedaba de un grant caducado
+)
+
+// Entry es un movimiento del ledger (tabla credit_ledger, solo inserción).
+// Amount lleva el signo: positivo en grants, negativo en debits y expirations.
+type Entry struct {
+	ID           uuid.UUID `db:"id"`
+	TenantID     uuid.UUID `db:"tenant_id"`
+	ProjectID    uuid.UUID `db:"project_id"`
+	Type         string    `db:"entry_type"`
+	Amount       float64   `db:"amount"`
+	BalanceAfter float64   `db:"balance_after"`
+	// FeatureID y Quantity: solo en debits
+	FeatureID   *uuid.UUID `db:"feature_id"`
+	FeatureCode *string    `db:"feature_code"`
+	Quantity    *float64   `db:"quantity"`
+	// GrantID: grant que caduca (solo en expirations)
+	GrantID        *uuid.UUID `db:"grant_id"`
+	ExpiresAt      *time.Time `db:"expires_at"`
+	IdempotencyKey *string    `db:"idempotency_key"`
+	Description    *string    `db:"description"`
+	CreatedAt      time.Time  `db:"created_at"`
+}
+
+// Grant es un grant con saldo pendiente (tabla credit_grants)
+type Grant struct {
+	ID        uuid.UUID  `db:"grant_id"`
+	Amount    float64    `db:"amount"`
+	Remaining float64    `db:"remaining"`
+	ExpiresAt *time.Time `db:"expires_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// GrantRequest: sin expires_at los créditos no caducan. Con idempotency_key un
+// reintento no vuelve a sumar.
+type GrantRequest struct {
+	Amount         float64    `json:"amount"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Description    string     `json:"description,omitempty"`
+	IdempotencyKey *string    `json:"idempotency_key,omitempty"`
+}
+
+// DebitRequest: se debita quantity (1 por defecto) por el credit_cost de la feature
+type DebitRequest struct {
+	FeatureCode    string   `json:"feature_code"`
+	Quantity       *float64 `json:"quantity,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	IdempotencyKey *string  `json:"idempotency_key,omitempty"`
+}
+
+type EntryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	TenantID       uuid.UUID  `json:"tenant_id"`
+	Type           string     `json:"type"`
+	Amount         float64    `json:"amount"`
+	BalanceAfter   float64    `json:"balance_after"`
+	FeatureCode    *string    `json:"feature_code,omitempty"`
+	Quantity       *float64   `json:"quantity,omitempty"`
+	GrantID        *uuid.UUID `json:"grant_id,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	IdempotencyKey *string    `json:"idempotency_key,omitempty"`
+	Description    *string    `json:"description,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func ToResponse(e *Entry) *EntryResponse {
+	return &EntryResponse{
+		ID:             e.ID,
+		TenantID:       e.TenantID,
+		Type:           e.Type,
+		Amount:         e.Amount,
+		BalanceAfter:   e.BalanceAfter,
+		FeatureCode:    e.FeatureCode,
+		Quantity:       e.Quantity,
+		GrantID:        e.GrantID,
+		ExpiresAt:      e.ExpiresAt,
+		IdempotencyKey: e.IdempotencyKey,
+		Description:    e.Description,
+		CreatedAt:      e.CreatedAt,
+	}
+}
+
+type GrantResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Amount    float64    `json:"amount"`
+	Remaining float64    `json:"remaining"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// BalanceResponse: available = balance + overdraft, lo que aún se puede
+// debitar. grants son los grants vigentes con saldo, en el orden en que se
+// consumen (primero el que caduca antes).
+type BalanceResponse struct {
+	TenantID  uuid.UUID       `json:"tenant_id"`
+	Balance   float64         `json:"balance"`
+	Overdraft float64         `json:"overdraft"`
+	Available float64         `json:"available"`
+	Grants    []GrantResponse `json:"grants"`
+}
+
+// DebitResponse: entry es nil si el débito no cabía en el saldo
+type DebitResponse struct {
+	Entry     *EntryResponse `json:"entry,omitempty"`
+	Cost      float64        `json:"cost"`
+	Balance   float64        `json:"balance"`
+	Available float64        `json:"available"`
+}
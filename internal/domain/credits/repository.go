@@ -0,0 +1,360 @@
+package credits
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+type CreditRepository interface {
+	Grant(ctx context.Context, e Entry) (*Entry, error)
+	Debit(ctx context.Context, e Entry, overdraft float64) (*Entry, float64, error)
+	Balance(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (float64, []Grant, error)
+	ListEntries(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, limit int) ([]Entry, error)
+}
+
+type creditRepository struct {
+	db *sql.DB
+}
+
+func NewCreditRepository(db *sql.DB) CreditRepository {
+	return &creditRepository{db: db}
+}
+
+const entryColumns = `e.id, e.tenant_id, e.project_id, e.entry_type, e.amount, e.balance_after,
+                e.feature_id, f.code, e.quantity, e.grant_id, e.expires_at,
+                e.idempotency_key, e.description, e.created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEntry(row rowScanner) (*Entry, error) {
+	e := &Entry{}
+	var tenantID string
+	if err := row.Scan(&e.ID, &tenantID, &e.ProjectID, &e.Type, &e.Amount, &e.BalanceAfter,
+		&e.FeatureID, &e.FeatureCode, &e.Quantity, &e.GrantID, &e.ExpiresAt,
+		&e.IdempotencyKey, &e.Description, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("parse credit entry tenant_id: %w", err)
+	}
+	e.TenantID = id
+	return e, nil
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Grant suma e.Amount al saldo. Si el saldo era negativo (descubierto) el
+// grant salda primero la deuda y solo el resto queda disponible en el grant.
+// Un idempotency_key repetido devuelve el grant ya registrado.
+func (r *creditRepository) Grant(ctx context.Context, e Entry) (*Entry, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin grant credits: %w", err)
+	}
+	defer tx.Rollback()
+
+	balance, err := lockBalance(ctx, tx, e.TenantID, e.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	prev, err := findByIdempotencyKey(ctx, tx, e)
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil {
+		if prev.Type != EntryGrant {
+			return nil, errors.New("idempotency_key already used")
+		}
+		return prev, nil
+	}
+	if balance, err = expireGrants(ctx, tx, e.TenantID, e.ProjectID, balance); err != nil {
+		return nil, err
+	}
+
+	remaining := e.Amount
+	if balance < 0 {
+		remaining = math.Max(e.Amount+balance, 0)
+	}
+	e.Type = EntryGrant
+	e.BalanceAfter = balance + e.Amount
+	if err := insertEntry(ctx, tx, &e); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO credit_grants (grant_id, tenant_id, project_id, amount, remaining, expires_at, created_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		e.ID, e.TenantID.String(), e.ProjectID, e.Amount, remaining, e.ExpiresAt, e.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert credit grant: %w", err)
+	}
+	if err := setBalance(ctx, tx, e.TenantID, e.ProjectID, e.BalanceAfter); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit grant credits: %w", err)
+	}
+	return &e, nil
+}
+
+// Debit resta -e.Amount del saldo solo si el resultado no baja de -overdraft.
+// Consume primero los grants que caducan antes; lo que no cubren queda en
+// descubierto. Devuelve nil (sin tocar nada) y el saldo actual si no cabe.
+// Un idempotency_key repetido devuelve el débito ya registrado.
+func (r *creditRepository) Debit(ctx context.Context, e Entry, overdraft float64) (*Entry, float64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("begin debit credits: %w", err)
+	}
+	defer tx.Rollback()
+
+	balance, err := lockBalance(ctx, tx, e.TenantID, e.ProjectID)
+	if err != nil {
+		return nil, 0, err
+	}
+	prev, err := findByIdempotencyKey(ctx, tx, e)
+	if err != nil {
+		return nil, 0, err
+	}
+	if prev != nil {
+		if prev.Type != EntryDebit {
+			return nil, 0, errors.New("idempotency_key already used")
+		}
+		return prev, balance, nil
+	}
+	if balance, err = expireGrants(ctx, tx, e.TenantID, e.ProjectID, balance); err != nil {
+		return nil, 0, err
+	}
+
+	cost := -e.Amount
+	if balance-cost < -overdraft {
+		// las caducidades sí se guardan aunque el débito no quepa
+		if err := setBalance(ctx, tx, e.TenantID, e.ProjectID, balance); err != nil {
+			return nil, 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, 0, fmt.Errorf("commit debit credits: %w", err)
+		}
+		return nil, balance, nil
+	}
+
+	open, err := listGrants(ctx, tx, e.TenantID, e.ProjectID, openGrantsFilter+` FOR UPDATE`)
+	if err != nil {
+		return nil, 0, err
+	}
+	left := cost
+	for _, g := range open {
+		if left <= 0 {
+			break
+		}
+		take := math.Min(g.Remaining, left)
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE credit_grants SET remaining = remaining - $2 WHERE grant_id = $1`,
+			g.ID, take); err != nil {
+			return nil, 0, fmt.Errorf("debit credit grant: %w", err)
+		}
+		left -= take
+	}
+
+	e.Type = EntryDebit
+	e.BalanceAfter = balance - cost
+	if err := insertEntry(ctx, tx, &e); err != nil {
+		return nil, 0, err
+	}
+	if err := setBalance(ctx, tx, e.TenantID, e.ProjectID, e.BalanceAfter); err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("commit debit credits: %w", err)
+	}
+	return &e, e.BalanceAfter, nil
+}
+
+// Balance devuelve el saldo (descontando los grants ya caducados aunque su
+// movimiento expiration aún no se haya escrito) y los grants vigentes con saldo
+func (r *creditRepository) Balance(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (float64, []Grant, error) {
+	var balance float64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT
+             COALESCE((SELECT balance FROM credit_balances
+                       WHERE tenant_id = $1 AND project_id = $2), 0)
+           - COALESCE((SELECT SUM(remaining) FROM credit_grants
+                       WHERE tenant_id = $1 AND project_id = $2
+                         AND remaining > 0 AND expires_at <= NOW()), 0)`,
+		tenantID.String(), projectID).Scan(&balance)
+	if err != nil {
+		return 0, nil, fmt.Errorf("get credit balance: %w", err)
+	}
+	grants, err := listGrants(ctx, r.db, tenantID, projectID, openGrantsFilter)
+	if err != nil {
+		return 0, nil, err
+	}
+	return balance, grants, nil
+}
+
+// ListEntries devuelve los últimos movimientos del tenant, del más reciente al más antiguo
+func (r *creditRepository) ListEntries(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, limit int) ([]Entry, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+entryColumns+`
+         FROM credit_ledger e
+         LEFT JOIN features f ON f.id = e.feature_id
+         WHERE e.tenant_id = $1 AND e.project_id = $2
+         ORDER BY e.created_at DESC, e.id
+         LIMIT $3`,
+		tenantID.String(), projectID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list credit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan credit entry: %w", err)
+		}
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
+
+// lockBalance crea (si falta) y bloquea la fila del saldo del tenant. Todos
+// los movimientos pasan por aquí, así que quedan serializados por
+// tenant/proyecto entre réplicas.
+func lockBalance(ctx context.Context, tx *sql.Tx, tenantID uuid.UUID, projectID uuid.UUID) (float64, error) {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO credit_balances (tenant_id, project_id)
+         VALUES ($1, $2)
+         ON CONFLICT (tenant_id, project_id) DO NOTHING`,
+		tenantID.String(), projectID)
+	if err != nil {
+		return 0, fmt.Errorf("ensure credit balance: %w", err)
+	}
+	var balance float64
+	err = tx.QueryRowContext(ctx,
+		`SELECT balance FROM credit_balances
+         WHERE tenant_id = $1 AND project_id = $2
+         FOR UPDATE`,
+		tenantID.String(), projectID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("lock credit balance: %w", err)
+	}
+	return balance, nil
+}
+
+func setBalance(ctx context.Context, tx *sql.Tx, tenantID uuid.UUID, projectID uuid.UUID, balance float64) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE credit_balances SET balance = $3, updated_at = NOW()
+         WHERE tenant_id = $1 AND project_id = $2`,
+		tenantID.String(), projectID, balance)
+	if err != nil {
+		return fmt.Errorf("update credit balance: %w", err)
+	}
+	return nil
+}
+
+// findByIdempotencyKey devuelve el movimiento con el idempotency_key de e (nil si no hay)
+func findByIdempotencyKey(ctx context.Context, tx *sql.Tx, e Entry) (*Entry, error) {
+	if e.IdempotencyKey == nil {
+		return nil, nil
+	}
+	prev, err := scanEntry(tx.QueryRowContext(ctx,
+		`SELECT `+entryColumns+`
+         FROM credit_ledger e
+         LEFT JOIN features f ON f.id = e.feature_id
+         WHERE e.project_id = $1 AND e.tenant_id = $2 AND e.idempotency_key = $3`,
+		e.ProjectID, e.TenantID.String(), *e.IdempotencyKey))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get credit entry by idempotency_key: %w", err)
+	}
+	return prev, nil
+}
+
+func insertEntry(ctx context.Context, tx *sql.Tx, e *Entry) error {
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO credit_ledger (id, tenant_id, project_id, entry_type, amount, balance_after,
+                                    feature_id, quantity, grant_id, expires_at, idempotency_key, description)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+         RETURNING created_at`,
+		e.ID, e.TenantID.String(), e.ProjectID, e.Type, e.Amount, e.BalanceAfter,
+		e.FeatureID, e.Quantity, e.GrantID, e.ExpiresAt, e.IdempotencyKey, e.Description).Scan(&e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert credit entry: %w", err)
+	}
+	return nil
+}
+
+// Filtros de listGrants. Los vigentes salen en el orden en que se consumen.
+const (
+	openGrantsFilter = `AND (expires_at IS NULL OR expires_at > NOW())
+         ORDER BY expires_at NULLS LAST, created_at, grant_id`
+	dueGrantsFilter = `AND expires_at <= NOW()
+         ORDER BY expires_at, grant_id`
+)
+
+func listGrants(ctx context.Context, q querier, tenantID uuid.UUID, projectID uuid.UUID, filter string) ([]Grant, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT grant_id, amount, remaining, expires_at, created_at
+         FROM credit_grants
+         WHERE tenant_id = $1 AND project_id = $2 AND remaining > 0 `+filter,
+		tenantID.String(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("list credit grants: %w", err)
+	}
+	defer rows.Close()
+
+	var grants []Grant
+	for rows.Next() {
+		var g Grant
+		if err := rows.Scan(&g.ID, &g.Amount, &g.Remaining, &g.ExpiresAt, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan credit grant: %w", err)
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// expireGrants descuenta con un movimiento expiration lo que quedaba de cada
+// grant caducado y devuelve el saldo resultante
+func expireGrants(ctx context.Context, tx *sql.Tx, tenantID uuid.UUID, projectID uuid.UUID, balance float64) (float64, error) {
+	due, err := listGrants(ctx, tx, tenantID, projectID, dueGrantsFilter+` FOR UPDATE`)
+	if err != nil {
+		return 0, err
+	}
+	for _, g := range due {
+		grantID := g.ID
+		balance -= g.Remaining
+		e := Entry{
+			ID:           uuid.New(),
+			TenantID:     tenantID,
+			ProjectID:    projectID,
+			Type:         EntryExpiration,
+			Amount:       -g.Remaining,
+			BalanceAfter: balance,
+			GrantID:      &grantID,
+			ExpiresAt:    g.ExpiresAt,
+		}
+		if err := insertEntry(ctx, tx, &e); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE credit_grants SET remaining = 0 WHERE grant_id = $1`, g.ID); err != nil {
+			return 0, fmt.Errorf("expire credit grant: %w", err)
+		}
+	}
+	return balance, nil
+}
@@ -0,0 +1,273 @@
+package credits
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"plans-features/internal/db/dbtest"
+)
+
+// ledgerFixture añade al fixture el repositorio de créditos
+type ledgerFixture struct {
+	dbtest.Fixture
+	repo CreditRepository
+}
+
+func newLedgerFixture(t *testing.T) ledgerFixture {
+	t.Helper()
+	f := dbtest.New(t)
+	return ledgerFixture{Fixture: f, repo: NewCreditRepository(f.DB)}
+}
+
+func (f ledgerFixture) entry(amount float64, key string) Entry {
+	e := Entry{ID: uuid.New(), TenantID: f.TenantID, ProjectID: f.ProjectID, Amount: amount}
+	if key != "" {
+		e.IdempotencyKey = &key
+	}
+	return e
+}
+
+func (f ledgerFixture) grant(t *testing.T, amount float64, expiresIn time.Duration) *Entry {
+	t.Helper()
+	e := f.entry(amount, "")
+	if expiresIn != 0 {
+		at := time.Now().Add(expiresIn)
+		e.ExpiresAt = &at
+	}
+	g, err := f.repo.Grant(context.Background(), e)
+	if err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+	return g
+}
+
+func (f ledgerFixture) debit(t *testing.T, cost float64, overdraft float64) (*Entry, float64) {
+	t.Helper()
+	e, balance, err := f.repo.Debit(context.Background(), f.entry(-cost, ""), overdraft)
+	if err != nil {
+		t.Fatalf("Debit: %v", err)
+	}
+	return e, balance
+}
+
+// remaining devuelve el saldo y lo que queda de cada grant vigente
+func (f ledgerFixture) remaining(t *testing.T) (float64, map[uuid.UUID]float64) {
+	t.Helper()
+	balance, grants, err := f.repo.Balance(context.Background(), f.TenantID, f.ProjectID)
+	if err != nil {
+		t.Fatalf("Balance: %v", err)
+	}
+	left := map[uuid.UUID]float64{}
+	for _, g := range grants {
+		left[g.ID] = g.Remaining
+	}
+	return balance, left
+}
+
+func TestDebitEarliestExpiringFirst(t *testing.T) {
+	f := newLedgerFixture(t)
+	forever := f.grant(t, 10, 0)
+	late := f.grant(t, 10, 2*time.Hour)
+	early := f.grant(t, 10, time.Hour)
+
+	if e, balance := f.debit(t, 15, 0); e == nil || balance != 15 || e.BalanceAfter != 15 {
+		t.Fatalf("Debit = %v, %v; want balance 15", e, balance)
+	}
+	balance, left := f.remaining(t)
+	if balance != 15 {
+		t.Errorf("balance = %v, want 15", balance)
+	}
+	if _, ok := left[early.ID]; ok {
+		t.Errorf("earliest grant still open: %v", left[early.ID])
+	}
+	if left[late.ID] != 5 || left[forever.ID] != 10 {
+		t.Errorf("remaining = late %v, forever %v; want 5, 10", left[late.ID], left[forever.ID])
+	}
+
+	// los grants sin caducidad se consumen los últimos
+	f.debit(t, 10, 0)
+	_, left = f.remaining(t)
+	if len(left) != 1 || left[forever.ID] != 5 {
+		t.Errorf("remaining = %v, want only the non-expiring grant with 5", left)
+	}
+}
+
+func TestDebitExpiresDueGrants(t *testing.T) {
+	f := newLedgerFixture(t)
+	expired := f.grant(t, 10, -time.Second)
+	f.grant(t, 5, 0)
+
+	// Balance ya descuenta el grant caducado aunque falte su movimiento
+	if balance, _ := f.remaining(t); balance != 5 {
+		t.Errorf("balance = %v, want 5", balance)
+	}
+	if e, balance := f.debit(t, 3, 0); e == nil || balance != 2 {
+		t.Fatalf("Debit = %v, %v; want balance 2", e, balance)
+	}
+
+	entries, err := f.repo.ListEntries(context.Background(), f.TenantID, f.ProjectID, 10)
+	if err != nil {
+		t.Fatalf("ListEntries: %v", err)
+	}
+	var expirations int
+	for _, e := range entries {
+		if e.Type == EntryExpiration {
+			expirations++
+			if e.Amount != -10 || e.GrantID == nil || *e.GrantID != expired.ID {
+				t.Errorf("expiration = %+v, want -10 of grant %s", e, expired.ID)
+			}
+		}
+	}
+	if expirations != 1 {
+		t.Errorf("expirations = %d, want 1", expirations)
+	}
+}
+
+func TestDebitOverdraft(t *testing.T) {
+	f := newLedgerFixture(t)
+	f.grant(t, 10, 0)
+
+	if e, balance := f.debit(t, 14, 5); e == nil || balance != -4 {
+		t.Fatalf("Debit into overdraft = %v, %v; want balance -4", e, balance)
+	}
+	// no cabe: no se registra nada y se devuelve el saldo actual
+	if e, balance := f.debit(t, 2, 5); e != nil || balance != -4 {
+		t.Errorf("Debit past overdraft = %v, %v; want nil, -4", e, balance)
+	}
+	if e, balance := f.debit(t, 1, 5); e == nil || balance != -5 {
+		t.Errorf("Debit up to overdraft = %v, %v; want balance -5", e, balance)
+	}
+	if e, balance := f.debit(t, 1, 0); e != nil || balance != -5 {
+		t.Errorf("Debit without overdraft = %v, %v; want nil, -5", e, balance)
+	}
+
+	// el siguiente grant salda primero la deuda
+	g := f.grant(t, 8, 0)
+	if g.BalanceAfter != 3 {
+		t.Errorf("grant balance_after = %v, want 3", g.BalanceAfter)
+	}
+	balance, left := f.remaining(t)
+	if balance != 3 || len(left) != 1 || left[g.ID] != 3 {
+		t.Errorf("balance = %v, grants = %v; want 3 left on the new grant", balance, left)
+	}
+}
+
+func TestDebitIdempotentRetry(t *testing.T) {
+	f := newLedgerFixture(t)
+	ctx := context.Background()
+	if _, err := f.repo.Grant(ctx, f.entry(10, "grant-1")); err != nil {
+		t.Fatalf("Grant: %v", err)
+	}
+
+	first, _, err := f.repo.Debit(ctx, f.entry(-4, "debit-1"), 0)
+	if err != nil || first == nil {
+		t.Fatalf("Debit = %v, %v", first, err)
+	}
+	// el reintento trae otro id pero la misma clave
+	retry, balance, err := f.repo.Debit(ctx, f.entry(-4, "debit-1"), 0)
+	if err != nil || retry == nil {
+		t.Fatalf("retried Debit = %v, %v", retry, err)
+	}
+	if retry.ID != first.ID || balance != 6 {
+		t.Errorf("retried Debit = %s, %v; want %s, 6", retry.ID, balance, first.ID)
+	}
+
+	if _, _, err := f.repo.Debit(ctx, f.entry(-1, "grant-1"), 0); err == nil || err.Error() != "idempotency_key already used" {
+		t.Errorf("Debit with a grant key: %v", err)
+	}
+	if _, err := f.repo.Grant(ctx, f.entry(1, "debit-1")); err == nil || err.Error() != "idempotency_key already used" {
+		t.Errorf("Grant with a debit key: %v", err)
+	}
+
+	if balance, _ := f.remaining(t); balance != 6 {
+		t.Errorf("balance = %v, want 6", balance)
+	}
+}
+
+func TestConcurrentDebits(t *testing.T) {
+	for _, overdraft := range []float64{0, 4} {
+		t.Run(fmt.Sprintf("overdraft %v", overdraft), func(t *testing.T) {
+			f := newLedgerFixture(t)
+			f.grant(t, 20, 0)
+
+			const workers, cost = 30, 3.0
+			var (
+				wg       sync.WaitGroup
+				mu       sync.Mutex
+				accepted int
+			)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					e, _, err := f.repo.Debit(context.Background(), f.entry(-cost, ""), overdraft)
+					if err != nil {
+						t.Errorf("Debit: %v", err)
+						return
+					}
+					if e != nil {
+						mu.Lock()
+						accepted++
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if want := int((20 + overdraft) / cost); accepted != want {
+				t.Errorf("accepted = %d, want %d", accepted, want)
+			}
+			balance, _ := f.remaining(t)
+			if want := 20 - float64(accepted)*cost; balance != want {
+				t.Errorf("balance = %v, want %v", balance, want)
+			}
+
+			// ningún movimiento dejó el saldo por debajo del descubierto
+			var lowest, sum float64
+			err := f.DB.QueryRow(
+				`SELECT MIN(balance_after), SUM(amount) FROM credit_ledger WHERE tenant_id = $1 AND project_id = $2`,
+				f.TenantID.String(), f.ProjectID).Scan(&lowest, &sum)
+			if err != nil {
+				t.Fatalf("read ledger: %v", err)
+			}
+			if lowest < -overdraft {
+				t.Errorf("lowest balance_after = %v, below -%v", lowest, overdraft)
+			}
+			if sum != balance {
+				t.Errorf("ledger sum = %v, balance = %v", sum, balance)
+			}
+		})
+	}
+}
+
+func TestLedgerAppendOnly(t *testing.T) {
+	f := newLedgerFixture(t)
+	g := f.grant(t, 10, 0)
+
+	tests := []struct {
+		stmt string
+		args []interface{}
+	}{
+		{`UPDATE credit_ledger SET amount = 0 WHERE id = $1`, []interface{}{g.ID}},
+		{`DELETE FROM credit_ledger WHERE id = $1`, []interface{}{g.ID}},
+		{`TRUNCATE credit_ledger CASCADE`, nil},
+		// el proyecto tampoco se puede borrar mientras tenga movimientos
+		{`DELETE FROM projects WHERE id = $1`, []interface{}{f.ProjectID}},
+	}
+	for _, tt := range tests {
+		// en una transacción que se deshace por si el trigger faltara
+		tx, err := f.DB.Begin()
+		if err != nil {
+			t.Fatalf("begin: %v", err)
+		}
+		if _, err := tx.Exec(tt.stmt, tt.args...); err == nil {
+			t.Errorf("%q succeeded on credit_ledger", tt.stmt)
+		}
+		tx.Rollback()
+	}
+}
@@ -0,0 +1,200 @@
+package credits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"plans-features/internal/domain/entitlements"
+	"plans-features/internal/domain/features"
+	"plans-features/internal/domain/plans"
+
+	"github.com/google/uuid"
+)
+
+type CreditService interface {
+	GetBalance(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (*BalanceResponse, error)
+	ListLedger(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, limit int) ([]EntryResponse, error)
+	GrantCredits(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req GrantRequest) (*EntryResponse, error)
+	DebitCredits(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req DebitRequest) (*DebitResponse, error)
+}
+
+// ErrInsufficientCredits: el débito dejaría el saldo por debajo del descubierto del plan
+var ErrInsufficientCredits = errors.New("insufficient credits")
+
+// Tamaño de página del ledger
+const (
+	defaultLedgerLimit = 100
+	maxLedgerLimit     = 1000
+)
+
+type creditService struct {
+	repo            CreditRepository
+	featureRepo     features.FeatureRepository
+	planRepo        plans.PlanRepository
+	entitlementRepo entitlements.EntitlementRepository
+}
+
+func NewCreditService(
+	repo CreditRepository,
+	featureRepo features.FeatureRepository,
+	planRepo plans.PlanRepository,
+	entitlementRepo entitlements.EntitlementRepository,
+) CreditService {
+	return &creditService{
+		repo:            repo,
+		featureRepo:     featureRepo,
+		planRepo:        planRepo,
+		entitlementRepo: entitlementRepo,
+	}
+}
+
+func (s *creditService) GetBalance(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (*BalanceResponse, error) {
+	balance, grants, err := s.repo.Balance(ctx, tenantID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	overdraft, err := s.overdraft(ctx, tenantID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	res := &BalanceResponse{
+		TenantID:  tenantID,
+		Balance:   balance,
+		Overdraft: overdraft,
+		Available: balance + overdraft,
+		Grants:    make([]GrantResponse, 0, len(grants)),
+	}
+	for _, g := range grants {
+		res.Grants = append(res.Grants, GrantResponse{
+			ID:        g.ID,
+			Amount:    g.Amount,
+			Remaining: g.Remaining,
+			ExpiresAt: g.ExpiresAt,
+			CreatedAt: g.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (s *creditService) ListLedger(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, limit int) ([]EntryResponse, error) {
+	if limit <= 0 {
+		limit = defaultLedgerLimit
+	}
+	if limit > maxLedgerLimit {
+		return nil, fmt.Errorf("limit must be at most %d", maxLedgerLimit)
+	}
+	entries, err := s.repo.ListEntries(ctx, tenantID, projectID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]EntryResponse, 0, len(entries))
+	for i := range entries {
+		out = append(out, *ToResponse(&entries[i]))
+	}
+	return out, nil
+}
+
+func (s *creditService) GrantCredits(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req GrantRequest) (*EntryResponse, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be > 0")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	e := Entry{
+		ID:             uuid.New(),
+		TenantID:       tenantID,
+		ProjectID:      projectID,
+		Amount:         req.Amount,
+		ExpiresAt:      req.ExpiresAt,
+		IdempotencyKey: req.IdempotencyKey,
+		Description:    description(req.Description),
+	}
+	created, err := s.repo.Grant(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	return ToResponse(created), nil
+}
+
+// DebitCredits cobra quantity * credit_cost de la feature. Si el saldo no
+// alcanza (contando el descubierto del plan) devuelve ErrInsufficientCredits
+// junto con el saldo actual.
+func (s *creditService) DebitCredits(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req DebitRequest) (*DebitResponse, error) {
+	if strings.TrimSpace(req.FeatureCode) == "" {
+		return nil, errors.New("feature_code is required")
+	}
+	quantity := 1.0
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be > 0")
+	}
+	feat, err := s.featureRepo.GetByCode(ctx, projectID, req.FeatureCode)
+	if err != nil {
+		return nil, err
+	}
+	if !feat.IsActive {
+		return nil, errors.New("feature not found")
+	}
+	if feat.CreditCost == nil {
+		return nil, errors.New("feature has no credit_cost")
+	}
+	overdraft, err := s.overdraft(ctx, tenantID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := quantity * *feat.CreditCost
+	featureID, code := feat.ID, feat.Code
+	e := Entry{
+		ID:             uuid.New(),
+		TenantID:       tenantID,
+		ProjectID:      projectID,
+		Amount:         -cost,
+		FeatureID:      &featureID,
+		FeatureCode:    &code,
+		Quantity:       &quantity,
+		IdempotencyKey: req.IdempotencyKey,
+		Description:    description(req.Description),
+	}
+	debited, balance, err := s.repo.Debit(ctx, e, overdraft)
+	if err != nil {
+		return nil, err
+	}
+	res := &DebitResponse{Cost: cost, Balance: balance, Available: balance + overdraft}
+	if debited == nil {
+		return res, ErrInsufficientCredits
+	}
+	// un reintento devuelve el débito original, con su coste
+	res.Cost = -debited.Amount
+	res.Entry = ToResponse(debited)
+	return res, nil
+}
+
+// overdraft: descubierto que permite el plan efectivo del tenant (0 sin plan)
+func (s *creditService) overdraft(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (float64, error) {
+	ep, err := s.entitlementRepo.ResolvePlan(ctx, tenantID, projectID, nil)
+	if err != nil {
+		if err.Error() == "no plan available" {
+			return 0, nil
+		}
+		return 0, err
+	}
+	plan, err := s.planRepo.GetByID(ctx, projectID, ep.ID)
+	if err != nil {
+		return 0, err
+	}
+	return plan.CreditOverdraft, nil
+}
+
+func description(s string) *string {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,164 @@
+package entitlements
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"plans-features/internal/auth"
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type EntitlementHandler struct {
+	service EntitlementService
+}
+
+func NewEntitlementHandler(s EntitlementService) *EntitlementHandler {
+	return &EntitlementHandler{service: s}
+}
+
+// asOfParam lee el query param as_of (RFC3339); false si ya respondió 400
+func asOfParam(w http.ResponseWriter, r *http.Request) (*time.Time, bool) {
+	s := r.URL.Query().Get("as_of")
+	if s == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "as_of must be RFC3339")
+		return nil, false
+	}
+	return &t, true
+}
+
+// GetEntitlements godoc
+// @Summary Get effective entitlements for a tenant
+// @Description Resolves the tenant's effective plan (explicit assignment or the project's default plan) and returns every active feature by code with its type and value, plus the plan limits, in one call. A tenant without an applicable plan gets plan_id null, plan_source "none" and only feature defaults, add-ons and overrides. With as_of the plan is the one the tenant had at that time according to the assignment history; only add-ons attached and overrides created (and not expired) by then that still exist are applied, with the current feature values
+// @Tags entitlements
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param as_of query string false "Resolve at this past time (RFC3339)"
+// @Success 200 {object} entitlements.EntitlementsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/entitlements [get]
+func (h *EntitlementHandler) GetEntitlements(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
+		return
+	}
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+
+	asOf, ok := asOfParam(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.service.GetEntitlements(r.Context(), tenantID, projectID, asOf)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "as_of") {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// CheckFeature godoc
+// @Summary Check a single feature for a tenant
+// @Description Answers "can the tenant use this feature?" against its effective plan. A flag with no value from the plan, its default, add-ons or overrides returns enabled=false; without a plan the default, add-ons and overrides still apply; numeric and value features return the configured value. 404 only for unknown feature codes. With as_of the plan comes from the assignment history, as in GET /api/tenants/{tenantId}/entitlements.
+// @Tags entitlements
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param featureCode path string true "Feature code"
+// @Param as_of query string false "Resolve at this past time (RFC3339)"
+// @Success 200 {object} entitlements.FeatureCheckResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/features/{featureCode} [get]
+func (h *EntitlementHandler) CheckFeature(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
+		return
+	}
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+
+	asOf, ok := asOfParam(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.service.CheckFeature(r.Context(), tenantID, projectID, chi.URLParam(r, "featureCode"), asOf)
+	if err != nil {
+		if err.Error() == "feature not found" {
+			utils.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if strings.HasPrefix(err.Error(), "as_of") {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// BatchEntitlements godoc
+// @Summary Evaluate entitlements for many tenants
+// @Description Resolves the effective plan (explicit assignment or default plan) and features of up to 5000 tenants in a constant number of queries. feature_codes optionally restricts the returned features and as_of resolves against the assignment history as in GET /api/tenants/{tenantId}/entitlements. Tenants without an applicable plan are resolved from feature defaults, add-ons and overrides.
+// @Tags entitlements
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param body body entitlements.BatchEntitlementsRequest true "Tenants and optional feature codes"
+// @Success 200 {object} entitlements.BatchEntitlementsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/entitlements:batch [post]
+func (h *EntitlementHandler) BatchEntitlements(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+	var req BatchEntitlementsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	res, err := h.service.BatchEntitlements(r.Context(), projectID, req)
+	if err != nil {
+		msg := err.Error()
+		if strings.HasPrefix(msg, "tenant_ids") || strings.HasPrefix(msg, "as_of") {
+			utils.Error(w, http.StatusBadRequest, msg)
+			return
+		}
+		utils.Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,86 @@
+package entitlements
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Origen del plan efectivo
+const (
+	SourceAssignment = "assignment" // tenant_plans
+	SourceTrial      = "trial"      // tenant_plans con un trial en curso
+	SourceDefault    = "default"    // plan is_default del proyecto
+	SourceNone       = "none"       // sin plan: solo default_value, add-ons y overrides
+)
+
+// Origen del valor de una feature
+const (
+	ValueSourceDefault  = "default"  // default_value de la feature (el plan no la asigna)
+	ValueSourcePlan     = "plan"     // plan_features del plan efectivo
+	ValueSourceAddon    = "addon"    // combinado con los add-ons del tenant
+	ValueSourceOverride = "override" // tenant_feature_overrides vigente
+	ValueSourceNone     = "none"     // ninguna capa da valor a la feature
+)
+
+// EffectivePlan es el plan que aplica al tenant y de dónde sale. VersionID
+// es la versión publicada que resuelve sus features (nil = el plan no tiene
+// ninguna y se usan sus plan_features actuales).
+type EffectivePlan struct {
+	ID        uuid.UUID
+	Code      string
+	Source    string
+	VersionID *uuid.UUID
+}
+
+// FeatureCheckResponse responde "¿puede el tenant usar la feature?"
+type FeatureCheckResponse struct {
+	FeatureCode string      `json:"feature_code"`
+	Enabled     bool        `json:"enabled"`
+	Type        string      `json:"type"`
+	Value       interface{} `json:"value"`
+	PlanCode    string      `json:"plan_code,omitempty"`
+	Source      string      `json:"source"`
+	// política de cuota del plan (solo features numeric; hard sin asignación)
+	Enforcement     string    `json:"enforcement,omitempty"`
+	AlertThresholds []float64 `json:"alert_thresholds,omitempty"`
+}
+
+// FeatureEntitlement es el valor efectivo de una feature y de dónde sale
+type FeatureEntitlement struct {
+	Type   string      `json:"type"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// EntitlementsResponse: plan efectivo del tenant con sus features (por código)
+// y límites. Sin plan, plan_id es null y plan_source "none".
+type EntitlementsResponse struct {
+	TenantID   uuid.UUID  `json:"tenant_id"`
+	ProjectID  uuid.UUID  `json:"project_id"`
+	PlanID     *uuid.UUID `json:"plan_id"`
+	PlanCode   string     `json:"plan_code"`
+	PlanSource string     `json:"plan_source"`
+	// PlanVersionID: versión del plan con la que se resolvieron las features
+	PlanVersionID *uuid.UUID                    `json:"plan_version_id,omitempty"`
+	Features      map[string]FeatureEntitlement `json:"features"`
+	Limits        map[string]interface{}        `json:"limits"`
+}
+
+// BatchEntitlementsRequest: feature_codes opcional para limitar las features
+// devueltas; as_of opcional para resolver contra el historial de asignaciones
+type BatchEntitlementsRequest struct {
+	TenantIDs    []uuid.UUID `json:"tenant_ids"`
+	FeatureCodes []string    `json:"feature_codes,omitempty"`
+	AsOf         *time.Time  `json:"as_of,omitempty"`
+}
+
+// BatchTenantResult: entitlements resueltos de un tenant
+type BatchTenantResult struct {
+	TenantID     uuid.UUID             `json:"tenant_id"`
+	Entitlements *EntitlementsResponse `json:"entitlements"`
+}
+
+type BatchEntitlementsResponse struct {
+	Results []BatchTenantResult `json:"results"`
+}
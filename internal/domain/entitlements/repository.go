@@ -0,0 +1,580 @@
+package entitlements
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"plans-features/internal/domain/features"
+
+	"github.com/google/uuid"
+)
+
+type EntitlementRepository interface {
+	Resolve(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, asOf *time.Time) (*EntitlementsResponse, error)
+	ResolvePlan(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, asOf *time.Time) (*EffectivePlan, error)
+	ResolveBatch(ctx context.Context, tenantIDs []uuid.UUID, projectID uuid.UUID, featureCodes []string, asOf *time.Time) (map[uuid.UUID]*EntitlementsResponse, error)
+}
+
+type entitlementRepository struct {
+	db *sql.DB
+}
+
+func NewEntitlementRepository(db *sql.DB) EntitlementRepository {
+	return &entitlementRepository{db: db}
+}
+
+// effectivePlanCTE elige el plan de cada tenant de $1 (text[]) en el proyecto
+// $2: la asignación explícita o, si no hay, el plan por defecto más reciente.
+// Un trial vencido y aún sin finalizar resuelve ya a su fallback (o al plan
+// por defecto). version_id es la versión fijada en la asignación; el plan por
+// defecto y el fallback de un trial vencido usan su última versión publicada.
+// Los tenants sin plan no aparecen en effective_plan.
+const effectivePlanCTE = `tenants AS (
+             SELECT DISTINCT unnest($1::text[]) AS tenant_id
+         ),
+         default_plan AS (
+             SELECT p.id, p.code, p.limits_json
+             FROM plans p
+             WHERE p.project_id = $2 AND p.is_default = true
+             ORDER BY p.created_at DESC
+             LIMIT 1
+         ),
+         effective_plan AS (
+             SELECT t.tenant_id, p.id, p.code, p.limits_json,
+                    CASE WHEN tp.trial_ends_at > NOW() THEN 'trial' ELSE 'assignment' END AS source,
+                    CASE WHEN tp.trial_ends_at <= NOW() THEN plan_version_at(p.id, NOW())
+                         ELSE COALESCE(tp.plan_version_id, plan_version_at(p.id, NOW()))
+                    END AS version_id
+             FROM tenants t
+             JOIN tenant_plans tp ON tp.tenant_id = t.tenant_id AND tp.project_id = $2
+             JOIN plans p ON p.id = CASE
+                 WHEN tp.trial_ends_at <= NOW() THEN COALESCE(tp.fallback_plan_id, (SELECT id FROM default_plan))
+                 ELSE tp.plan_id
+             END
+             UNION ALL
+             SELECT t.tenant_id, dp.id, dp.code, dp.limits_json, 'default', plan_version_at(dp.id, NOW())
+             FROM tenants t
+             CROSS JOIN default_plan dp
+             WHERE NOT EXISTS (
+                 SELECT 1 FROM tenant_plans tp
+                 WHERE tp.tenant_id = t.tenant_id AND tp.project_id = $2
+             )
+         )`
+
+// historicalPlanCTE es effectivePlanCTE resuelto contra tenant_plan_history
+// en la fecha $3: la última entrada con valid_from <= $3 de cada tenant, con
+// las mismas reglas de trial y la versión fijada en esa entrada (o la última
+// publicada en $3). Sin entrada (o con plan_id NULL) el tenant cae en el plan
+// por defecto actual del proyecto.
+const historicalPlanCTE = `tenants AS (
+             SELECT DISTINCT unnest($1::text[]) AS tenant_id
+         ),
+         default_plan AS (
+             SELECT p.id, p.code, p.limits_json
+             FROM plans p
+             WHERE p.project_id = $2 AND p.is_default = true
+             ORDER BY p.created_at DESC
+             LIMIT 1
+         ),
+         history AS (
+             SELECT DISTINCT ON (h.tenant_id) h.tenant_id, h.plan_id, h.plan_version_id, h.trial_ends_at, h.fallback_plan_id
+             FROM tenant_plan_history h
+             WHERE h.tenant_id = ANY($1::text[]) AND h.project_id = $2 AND h.valid_from <= $3
+             ORDER BY h.tenant_id, h.valid_from DESC, h.created_at DESC, h.id DESC
+         ),
+         effective_plan AS (
+             SELECT t.tenant_id, p.id, p.code, p.limits_json,
+                    CASE WHEN h.trial_ends_at > $3 THEN 'trial' ELSE 'assignment' END AS source,
+                    CASE WHEN h.trial_ends_at <= $3 THEN plan_version_at(p.id, $3)
+                         ELSE COALESCE(h.plan_version_id, plan_version_at(p.id, $3))
+                    END AS version_id
+             FROM tenants t
+             JOIN history h ON h.tenant_id = t.tenant_id AND h.plan_id IS NOT NULL
+             JOIN plans p ON p.id = CASE
+                 WHEN h.trial_ends_at <= $3 THEN COALESCE(h.fallback_plan_id, (SELECT id FROM default_plan))
+                 ELSE h.plan_id
+             END
+             UNION ALL
+             SELECT t.tenant_id, dp.id, dp.code, dp.limits_json, 'default', plan_version_at(dp.id, $3)
+             FROM tenants t
+             CROSS JOIN default_plan dp
+             WHERE NOT EXISTS (
+                 SELECT 1 FROM history h
+                 WHERE h.tenant_id = t.tenant_id AND h.plan_id IS NOT NULL
+             )
+         )`
+
+// planQuery elige cómo se resuelve el plan efectivo: el estado actual de
+// tenant_plans o, con asOf, el historial de asignaciones. Con asOf el CTE usa
+// $3, que va siempre en args detrás de los tenants y el proyecto.
+func planQuery(asOf *time.Time) string {
+	if asOf != nil {
+		return historicalPlanCTE
+	}
+	return effectivePlanCTE
+}
+
+func tenantIDStrings(ids []uuid.UUID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+// ResolvePlan devuelve solo el plan efectivo, sin features (con asOf, el que
+// tenía el tenant en esa fecha)
+func (r *entitlementRepository) ResolvePlan(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, asOf *time.Time) (*EffectivePlan, error) {
+	args := []interface{}{[]string{tenantID.String()}, projectID}
+	if asOf != nil {
+		args = append(args, *asOf)
+	}
+	ep := &EffectivePlan{}
+	err := r.db.QueryRowContext(ctx,
+		`WITH `+planQuery(asOf)+`
+         SELECT id, code, source, version_id FROM effective_plan`,
+		args...).
+		Scan(&ep.ID, &ep.Code, &ep.Source, &ep.VersionID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("no plan available")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolve plan: %w", err)
+	}
+	return ep, nil
+}
+
+// planChainCTE recorre la herencia (parent_plan_id) de cada plan efectivo
+// sin versión publicada: depth 0 = el plan del tenant, 1 = su padre... El tope
+// de 32 evita bucles si la BD tuviera un ciclo. Una versión publicada ya lleva
+// la herencia aplanada en plan_version_features.
+const planChainCTE = `plan_chain AS (
+             SELECT ep.tenant_id, p.id, p.parent_plan_id, 0 AS depth
+             FROM effective_plan ep
+             JOIN plans p ON p.id = ep.id
+             WHERE ep.version_id IS NULL
+             UNION ALL
+             SELECT c.tenant_id, p.id, p.parent_plan_id, c.depth + 1
+             FROM plan_chain c
+             JOIN plans p ON p.id = c.parent_plan_id
+             WHERE c.depth < 32
+         )`
+
+// Resolve resuelve plan efectivo, features y límites en una sola consulta:
+// una fila por valor de feature activa (o una sola fila sin feature si no hay
+// ninguno). Los valores llegan ordenados por capa (default_value de la
+// feature, plan, add-ons activos, override): dentro del plan, del ancestro
+// más lejano al propio plan, así el hijo sustituye al padre; los add-ons se
+// combinan con la merge_strategy de la feature en el orden en que se
+// contrataron.
+// Con asOf el plan y su versión salen del historial y solo cuentan los
+// add-ons contratados y los overrides creados (y sin caducar) en esa fecha
+// que sigan existiendo; default_value y límites del plan son los actuales.
+// Un tenant sin plan se resuelve igual, sin la capa del plan (plan_source
+// "none").
+func (r *entitlementRepository) Resolve(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, asOf *time.Time) (*EntitlementsResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`WITH RECURSIVE `+planQuery(asOf)+`,
+         `+planChainCTE+`,
+         feature_values AS (
+             SELECT f.id AS feature_id, f.default_value AS value_json, 'default' AS source, 0 AS layer, 0 AS ord
+             FROM features f
+             WHERE f.project_id = $2 AND f.default_value IS NOT NULL
+             UNION ALL
+             SELECT pf.feature_id, pf.value_json, 'plan', 1, -c.depth
+             FROM plan_chain c
+             JOIN plan_features pf ON pf.plan_id = c.id
+             UNION ALL
+             SELECT pvf.feature_id, pvf.value_json, 'plan', 1, 0
+             FROM effective_plan ep
+             JOIN plan_version_features pvf ON pvf.version_id = ep.version_id
+             UNION ALL
+             SELECT af.feature_id, af.value_json, 'addon', 2,
+                    ROW_NUMBER() OVER (ORDER BY ta.created_at, ta.id)
+             FROM tenants t
+             JOIN tenant_addons ta ON ta.tenant_id = t.tenant_id AND ta.project_id = $2
+             JOIN addons a ON a.id = ta.addon_id AND a.is_active
+             JOIN addon_features af ON af.addon_id = ta.addon_id
+             WHERE ta.created_at <= COALESCE($3::timestamptz, NOW())
+             UNION ALL
+             SELECT o.feature_id, o.value_json, 'override', 3, 0
+             FROM tenants t
+             JOIN tenant_feature_overrides o ON o.tenant_id = t.tenant_id AND o.project_id = $2
+             WHERE o.created_at <= COALESCE($3::timestamptz, NOW())
+               AND (o.expires_at IS NULL OR o.expires_at > COALESCE($3::timestamptz, NOW()))
+         )
+         SELECT ep.id, ep.code, ep.limits_json, ep.source, ep.version_id, f.code, f.type, f.merge_strategy, v.value_json, v.source
+         FROM tenants t
+         LEFT JOIN effective_plan ep ON ep.tenant_id = t.tenant_id
+         LEFT JOIN (feature_values v
+                    JOIN features f ON f.id = v.feature_id AND f.is_active = true)
+                ON true
+         ORDER BY v.layer, v.ord, f.code`,
+		[]string{tenantID.String()}, projectID, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("resolve entitlements: %w", err)
+	}
+	defer rows.Close()
+
+	var res *EntitlementsResponse
+	for rows.Next() {
+		var (
+			planID      *uuid.UUID
+			planCode    sql.NullString
+			limitsJSON  []byte
+			source      sql.NullString
+			versionID   *uuid.UUID
+			featureCode sql.NullString
+			featureType sql.NullString
+			strategy    sql.NullString
+			valueJSON   []byte
+			valueSource sql.NullString
+		)
+		if err := rows.Scan(&planID, &planCode, &limitsJSON, &source, &versionID, &featureCode, &featureType, &strategy, &valueJSON, &valueSource); err != nil {
+			return nil, fmt.Errorf("scan entitlement: %w", err)
+		}
+
+		if res == nil {
+			if !source.Valid {
+				source.String = SourceNone
+			}
+			if res, err = newEntitlements(tenantID, projectID, planID, planCode.String, source.String, versionID, limitsJSON); err != nil {
+				return nil, err
+			}
+		}
+		if !featureCode.Valid {
+			continue
+		}
+		if valueSource.String == ValueSourceAddon {
+			err = res.mergeAddon(featureCode.String, featureType.String, strategy.String, valueJSON)
+		} else {
+			err = res.addFeature(featureCode.String, featureType.String, valueJSON, valueSource.String)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("resolve entitlements: %w", err)
+	}
+	return res, nil
+}
+
+// ResolveBatch resuelve muchos tenants con cinco consultas fijas: el plan
+// efectivo de todos ellos, los default_value de las features, las features de
+// las versiones y planes sin versión distintos que resulten (estos con su
+// cadena de herencia), los add-ons y los overrides vigentes de esos tenants.
+// featureCodes vacío = todas las features. Los tenants sin plan aplicable se
+// resuelven sin la capa del plan, como en Resolve. asOf resuelve como en
+// Resolve.
+func (r *entitlementRepository) ResolveBatch(ctx context.Context, tenantIDs []uuid.UUID, projectID uuid.UUID, featureCodes []string, asOf *time.Time) (map[uuid.UUID]*EntitlementsResponse, error) {
+	args := []interface{}{tenantIDStrings(tenantIDs), projectID}
+	if asOf != nil {
+		args = append(args, *asOf)
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`WITH `+planQuery(asOf)+`
+         SELECT tenant_id, id, code, limits_json, source, version_id FROM effective_plan`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("resolve batch plans: %w", err)
+	}
+	defer rows.Close()
+
+	// byPlan agrupa los tenants por versión (o por plan si no tiene versión);
+	// versionIDs y planIDs son las claves distintas de cada tipo
+	results := map[uuid.UUID]*EntitlementsResponse{}
+	byPlan := map[uuid.UUID][]*EntitlementsResponse{}
+	planIDs, versionIDs := []string{}, []string{}
+	for rows.Next() {
+		var (
+			tenantStr  string
+			planID     uuid.UUID
+			planCode   string
+			limitsJSON []byte
+			source     string
+			versionID  *uuid.UUID
+		)
+		if err := rows.Scan(&tenantStr, &planID, &planCode, &limitsJSON, &source, &versionID); err != nil {
+			return nil, fmt.Errorf("scan batch plan: %w", err)
+		}
+		tenantID, err := uuid.Parse(tenantStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse tenant id: %w", err)
+		}
+		res, err := newEntitlements(tenantID, projectID, &planID, planCode, source, versionID, limitsJSON)
+		if err != nil {
+			return nil, err
+		}
+		results[tenantID] = res
+		key := planID
+		if versionID != nil {
+			key = *versionID
+		}
+		if _, seen := byPlan[key]; !seen {
+			if versionID != nil {
+				versionIDs = append(versionIDs, key.String())
+			} else {
+				planIDs = append(planIDs, key.String())
+			}
+		}
+		byPlan[key] = append(byPlan[key], res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("resolve batch plans: %w", err)
+	}
+	for _, tenantID := range tenantIDs {
+		if _, ok := results[tenantID]; !ok {
+			res, err := newEntitlements(tenantID, projectID, nil, "", SourceNone, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			results[tenantID] = res
+		}
+	}
+
+	if featureCodes == nil {
+		featureCodes = []string{}
+	}
+
+	// default_value de las features, base para todos los tenants
+	drows, err := r.db.QueryContext(ctx,
+		`SELECT f.code, f.type, f.default_value
+         FROM features f
+         WHERE f.project_id = $1 AND f.is_active = true AND f.default_value IS NOT NULL
+           AND (cardinality($2::text[]) = 0 OR f.code = ANY($2::text[]))`,
+		projectID, featureCodes)
+	if err != nil {
+		return nil, fmt.Errorf("resolve batch defaults: %w", err)
+	}
+	defer drows.Close()
+
+	for drows.Next() {
+		var (
+			featureCode string
+			featureType string
+			valueJSON   []byte
+		)
+		if err := drows.Scan(&featureCode, &featureType, &valueJSON); err != nil {
+			return nil, fmt.Errorf("scan batch default: %w", err)
+		}
+		for _, res := range results {
+			if err := res.addFeature(featureCode, featureType, valueJSON, ValueSourceDefault); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := drows.Err(); err != nil {
+		return nil, fmt.Errorf("resolve batch defaults: %w", err)
+	}
+	// features de cada versión y de cada plan sin versión con sus ancestros,
+	// del más lejano al propio plan para que el hijo sustituya al padre
+	frows, err := r.db.QueryContext(ctx,
+		`WITH RECURSIVE plan_chain AS (
+             SELECT p.id AS root_id, p.id, p.parent_plan_id, 0 AS depth
+             FROM plans p
+             WHERE p.id = ANY($1::uuid[])
+             UNION ALL
+             SELECT c.root_id, p.id, p.parent_plan_id, c.depth + 1
+             FROM plan_chain c
+             JOIN plans p ON p.id = c.parent_plan_id
+             WHERE c.depth < 32
+         )
+         SELECT c.root_id, f.code, f.type, pf.value_json, c.depth
+         FROM plan_chain c
+         JOIN plan_features pf ON pf.plan_id = c.id
+         JOIN features f ON f.id = pf.feature_id AND f.is_active = true
+         WHERE cardinality($2::text[]) = 0 OR f.code = ANY($2::text[])
+         UNION ALL
+         SELECT pvf.version_id, f.code, f.type, pvf.value_json, 0
+         FROM plan_version_features pvf
+         JOIN features f ON f.id = pvf.feature_id AND f.is_active = true
+         WHERE pvf.version_id = ANY($3::uuid[])
+           AND (cardinality($2::text[]) = 0 OR f.code = ANY($2::text[]))
+         ORDER BY 5 DESC`,
+		planIDs, featureCodes, versionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("resolve batch features: %w", err)
+	}
+	defer frows.Close()
+
+	for frows.Next() {
+		var (
+			key         uuid.UUID
+			featureCode string
+			featureType string
+			valueJSON   []byte
+			depth       int
+		)
+		if err := frows.Scan(&key, &featureCode, &featureType, &valueJSON, &depth); err != nil {
+			return nil, fmt.Errorf("scan batch feature: %w", err)
+		}
+		for _, res := range byPlan[key] {
+			if err := res.addFeature(featureCode, featureType, valueJSON, ValueSourcePlan); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := frows.Err(); err != nil {
+		return nil, fmt.Errorf("resolve batch features: %w", err)
+	}
+
+	// add-ons activos combinados con el plan y los default_value
+	arows, err := r.db.QueryContext(ctx,
+		`SELECT ta.tenant_id, f.code, f.type, f.merge_strategy, af.value_json
+         FROM tenant_addons ta
+         JOIN addons a ON a.id = ta.addon_id AND a.is_active
+         JOIN addon_features af ON af.addon_id = ta.addon_id
+         JOIN features f ON f.id = af.feature_id AND f.is_active = true
+         WHERE ta.tenant_id = ANY($1::text[]) AND ta.project_id = $2
+           AND (cardinality($3::text[]) = 0 OR f.code = ANY($3::text[]))
+           AND ta.created_at <= COALESCE($4::timestamptz, NOW())
+         ORDER BY ta.created_at, ta.id`,
+		tenantIDStrings(tenantIDs), projectID, featureCodes, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("resolve batch addons: %w", err)
+	}
+	defer arows.Close()
+
+	for arows.Next() {
+		var (
+			tenantStr   string
+			featureCode string
+			featureType string
+			strategy    string
+			valueJSON   []byte
+		)
+		if err := arows.Scan(&tenantStr, &featureCode, &featureType, &strategy, &valueJSON); err != nil {
+			return nil, fmt.Errorf("scan batch addon: %w", err)
+		}
+		tenantID, err := uuid.Parse(tenantStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse tenant id: %w", err)
+		}
+		if res, ok := results[tenantID]; ok {
+			if err := res.mergeAddon(featureCode, featureType, strategy, valueJSON); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := arows.Err(); err != nil {
+		return nil, fmt.Errorf("resolve batch addons: %w", err)
+	}
+
+	// overrides encima de todo lo anterior
+	orows, err := r.db.QueryContext(ctx,
+		`SELECT o.tenant_id, f.code, f.type, o.value_json
+         FROM tenant_feature_overrides o
+         JOIN features f ON f.id = o.feature_id AND f.is_active = true
+         WHERE o.tenant_id = ANY($1::text[]) AND o.project_id = $2
+           AND o.created_at <= COALESCE($4::timestamptz, NOW())
+           AND (o.expires_at IS NULL OR o.expires_at > COALESCE($4::timestamptz, NOW()))
+           AND (cardinality($3::text[]) = 0 OR f.code = ANY($3::text[]))`,
+		tenantIDStrings(tenantIDs), projectID, featureCodes, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("resolve batch overrides: %w", err)
+	}
+	defer orows.Close()
+
+	for orows.Next() {
+		var (
+			tenantStr   string
+			featureCode string
+			featureType string
+			valueJSON   []byte
+		)
+		if err := orows.Scan(&tenantStr, &featureCode, &featureType, &valueJSON); err != nil {
+			return nil, fmt.Errorf("scan batch override: %w", err)
+		}
+		tenantID, err := uuid.Parse(tenantStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse tenant id: %w", err)
+		}
+		if res, ok := results[tenantID]; ok {
+			if err := res.addFeature(featureCode, featureType, valueJSON, ValueSourceOverride); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := orows.Err(); err != nil {
+		return nil, fmt.Errorf("resolve batch overrides: %w", err)
+	}
+	return results, nil
+}
+
+func newEntitlements(tenantID, projectID uuid.UUID, planID *uuid.UUID, planCode, source string, versionID *uuid.UUID, limitsJSON []byte) (*EntitlementsResponse, error) {
+	res := &EntitlementsResponse{
+		TenantID:      tenantID,
+		ProjectID:     projectID,
+		PlanID:        planID,
+		PlanCode:      planCode,
+		PlanSource:    source,
+		PlanVersionID: versionID,
+		Features:      map[string]FeatureEntitlement{},
+		Limits:        map[string]interface{}{},
+	}
+	// JSONB → map (NULL = sin límites)
+	if len(limitsJSON) > 0 {
+		if err := json.Unmarshal(limitsJSON, &res.Limits); err != nil {
+			return nil, fmt.Errorf("unmarshal limits: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// addFeature decodifica value_json y sustituye el valor de una capa anterior;
+// cada tenant recibe su propia copia del valor
+func (res *EntitlementsResponse) addFeature(code, featureType string, valueJSON []byte, source string) error {
+	fe := FeatureEntitlement{Type: featureType, Source: source}
+	if err := json.Unmarshal(valueJSON, &fe.Value); err != nil {
+		return fmt.Errorf("unmarshal value_json: %w", err)
+	}
+	res.Features[code] = fe
+	return nil
+}
+
+// mergeAddon combina el valor de un add-on con el acumulado (plan y add-ons
+// anteriores) según la merge_strategy de la feature
+func (res *EntitlementsResponse) mergeAddon(code, featureType, strategy string, valueJSON []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(valueJSON, &value); err != nil {
+		return fmt.Errorf("unmarshal value_json: %w", err)
+	}
+	if cur, ok := res.Features[code]; ok {
+		value = mergeValues(strategy, cur.Value, value)
+	}
+	res.Features[code] = FeatureEntitlement{Type: featureType, Value: value, Source: ValueSourceAddon}
+	return nil
+}
+
+// mergeValues aplica la estrategia de combinación; si los tipos no encajan
+// (datos antiguos) gana el valor del add-on
+func mergeValues(strategy string, current, addon interface{}) interface{} {
+	switch strategy {
+	case features.MergeOr:
+		a, aok := current.(bool)
+		b, bok := addon.(bool)
+		if !aok || !bok {
+			return addon
+		}
+		return a || b
+	case features.MergeSum, features.MergeMax:
+		a, aok := current.(float64)
+		b, bok := addon.(float64)
+		if !aok || !bok {
+			return addon
+		}
+		if strategy == features.MergeSum {
+			return a + b
+		}
+		return math.Max(a, b)
+	default:
+		return addon
+	}
+}
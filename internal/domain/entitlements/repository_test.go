@@ -0,0 +1,98 @@
+package entitlements
+
+import (
+	"reflect"
+	"testing"
+
+	"plans-features/internal/domain/features"
+)
+
+func TestMergeValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		current  interface{}
+		addon    interface{}
+		want     interface{}
+	}{
+		// or
+		{"or false false", features.MergeOr, false, false, false},
+		{"or false true", features.MergeOr, false, true, true},
+		{"or true false", features.MergeOr, true, false, true},
+		{"or true true", features.MergeOr, true, true, true},
+
+		// sum
+		{"sum", features.MergeSum, 10.0, 5.0, 15.0},
+		{"sum fractional", features.MergeSum, 0.5, 0.25, 0.75},
+		{"sum negative", features.MergeSum, 10.0, -3.0, 7.0},
+		{"sum zero", features.MergeSum, 0.0, 0.0, 0.0},
+
+		// max
+		{"max addon higher", features.MergeMax, 10.0, 50.0, 50.0},
+		{"max plan higher", features.MergeMax, 50.0, 10.0, 50.0},
+		{"max equal", features.MergeMax, 7.0, 7.0, 7.0},
+
+		// override
+		{"override string", features.MergeOverride, "basic", "premium", "premium"},
+		{"override number", features.MergeOverride, 10.0, 5.0, 5.0},
+		{"override object", features.MergeOverride, map[string]interface{}{"a": 1.0}, map[string]interface{}{"b": 2.0}, map[string]interface{}{"b": 2.0}},
+		{"unknown strategy overrides", "bogus", 10.0, 5.0, 5.0},
+		{"empty strategy overrides", "", true, false, false},
+
+		// tipos que no encajan (datos antiguos): gana el add-on
+		{"or plan not bool", features.MergeOr, "yes", true, true},
+		{"or addon not bool", features.MergeOr, true, "no", "no"},
+		{"or addon nil", features.MergeOr, true, nil, nil},
+		{"sum plan not number", features.MergeSum, "10", 5.0, 5.0},
+		{"sum addon not number", features.MergeSum, 10.0, "5", "5"},
+		{"sum bool values", features.MergeSum, true, true, true},
+		{"max plan not number", features.MergeMax, nil, 5.0, 5.0},
+		{"max addon not number", features.MergeMax, 10.0, false, false},
+		{"max int is not float64", features.MergeMax, 10.0, 50, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeValues(tt.strategy, tt.current, tt.addon)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeValues(%q, %#v, %#v) = %#v, want %#v", tt.strategy, tt.current, tt.addon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeAddon(t *testing.T) {
+	res := &EntitlementsResponse{Features: map[string]FeatureEntitlement{}}
+	if err := res.addFeature("seats", "numeric", []byte(`10`), ValueSourcePlan); err != nil {
+		t.Fatal(err)
+	}
+	if err := res.addFeature("sso", "flag", []byte(`false`), ValueSourcePlan); err != nil {
+		t.Fatal(err)
+	}
+
+	// dos add-ons de seats se suman sobre el plan
+	for _, v := range []string{`5`, `2.5`} {
+		if err := res.mergeAddon("seats", "numeric", features.MergeSum, []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := res.mergeAddon("sso", "flag", features.MergeOr, []byte(`true`)); err != nil {
+		t.Fatal(err)
+	}
+	// feature que el plan no tiene: el add-on la aporta tal cual
+	if err := res.mergeAddon("storage", "numeric", features.MergeMax, []byte(`100`)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]FeatureEntitlement{
+		"seats":   {Type: "numeric", Value: 17.5, Source: ValueSourceAddon},
+		"sso":     {Type: "flag", Value: true, Source: ValueSourceAddon},
+		"storage": {Type: "numeric", Value: 100.0, Source: ValueSourceAddon},
+	}
+	if !reflect.DeepEqual(res.Features, want) {
+		t.Errorf("features = %#v, want %#v", res.Features, want)
+	}
+
+	if err := res.mergeAddon("seats", "numeric", features.MergeSum, []byte(`{`)); err == nil {
+		t.Errorf("mergeAddon accepted invalid value_json")
+	}
+}
@@ -0,0 +1,214 @@
+package entitlements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"plans-features/internal/domain/addons"
+	"plans-features/internal/domain/features"
+	"plans-features/internal/domain/overrides"
+	"plans-features/internal/domain/planfeatures"
+
+	"github.com/google/uuid"
+)
+
+type EntitlementService interface {
+	GetEntitlements(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, asOf *time.Time) (*EntitlementsResponse, error)
+	CheckFeature(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string, asOf *time.Time) (*FeatureCheckResponse, error)
+	BatchEntitlements(ctx context.Context, projectID uuid.UUID, req BatchEntitlementsRequest) (*BatchEntitlementsResponse, error)
+}
+
+// MaxBatchTenants acota el tamaño de POST /api/entitlements:batch
+const MaxBatchTenants = 5000
+
+type entitlementService struct {
+	repo            EntitlementRepository
+	featureRepo     features.FeatureRepository
+	planFeatureRepo planfeatures.PlanFeatureRepository
+	overrideRepo    overrides.OverrideRepository
+	addonRepo       addons.AddonRepository
+}
+
+func NewEntitlementService(
+	repo EntitlementRepository,
+	featureRepo features.FeatureRepository,
+	planFeatureRepo planfeatures.PlanFeatureRepository,
+	overrideRepo overrides.OverrideRepository,
+	addonRepo addons.AddonRepository,
+) EntitlementService {
+	return &entitlementService{
+		repo:            repo,
+		featureRepo:     featureRepo,
+		planFeatureRepo: planFeatureRepo,
+		overrideRepo:    overrideRepo,
+		addonRepo:       addonRepo,
+	}
+}
+
+// GetEntitlements devuelve el plan efectivo del tenant con todas sus
+// features; con asOf, resuelto contra el historial de asignaciones
+func (s *entitlementService) GetEntitlements(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, asOf *time.Time) (*EntitlementsResponse, error) {
+	if err := validateAsOf(asOf); err != nil {
+		return nil, err
+	}
+	return s.repo.Resolve(ctx, tenantID, projectID, asOf)
+}
+
+// validateAsOf: el historial solo responde por el pasado
+func validateAsOf(asOf *time.Time) error {
+	if asOf != nil && asOf.After(time.Now()) {
+		return errors.New("as_of must not be in the future")
+	}
+	return nil
+}
+
+// CheckFeature resuelve una sola feature. Solo falla con "feature not found"
+// si el código no existe; sin ningún valor la feature queda deshabilitada (un
+// flag ausente vale false). Si el plan no asigna la feature, o el tenant no
+// tiene plan, se usa su default_value; los add-ons del tenant se combinan con
+// ese valor y un override vigente sustituye a todo. Las features numeric
+// llevan además la política de cuota de la asignación en el plan (hard si el
+// plan no la asigna o no hay plan). Con asOf el plan sale del historial de
+// asignaciones.
+func (s *entitlementService) CheckFeature(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string, asOf *time.Time) (*FeatureCheckResponse, error) {
+	if err := validateAsOf(asOf); err != nil {
+		return nil, err
+	}
+	feat, err := s.featureRepo.GetByCode(ctx, projectID, featureCode)
+	if err != nil {
+		return nil, err
+	}
+	res := &FeatureCheckResponse{
+		FeatureCode: feat.Code,
+		Type:        feat.Type,
+		Source:      ValueSourceNone,
+	}
+	if feat.Type == "flag" {
+		res.Value = false
+	}
+	if !feat.IsActive {
+		return res, nil
+	}
+
+	// sin plan solo quedan default_value, add-ons y overrides
+	plan, err := s.repo.ResolvePlan(ctx, tenantID, projectID, asOf)
+	if err != nil && err.Error() != "no plan available" {
+		return nil, err
+	}
+	var pf *planfeatures.PlanFeatureResponse
+	if plan != nil {
+		res.PlanCode = plan.Code
+		// el valor (y la política de cuota) sale de la versión fijada o, si el
+		// plan no tiene ninguna publicada, puede venir de un plan padre
+		if plan.VersionID != nil {
+			pf, err = s.planFeatureRepo.GetVersionFeature(ctx, projectID, *plan.VersionID, feat.ID)
+		} else {
+			pf, err = s.planFeatureRepo.GetInherited(ctx, projectID, plan.ID, feat.ID)
+		}
+		if err != nil {
+			if err.Error() != "plan feature not found" {
+				return nil, err
+			}
+			pf = nil
+		}
+	}
+	if feat.Type == "numeric" {
+		res.Enforcement = planfeatures.EnforcementHard
+		if pf != nil {
+			res.Enforcement = pf.Enforcement
+			res.AlertThresholds = pf.AlertThresholds
+		}
+	}
+
+	o, err := s.overrideRepo.GetActive(ctx, tenantID, projectID, feat.ID, asOf)
+	if err == nil {
+		res.Value = o.Value
+		res.Source = ValueSourceOverride
+		res.Enabled = isEnabled(feat.Type, o.Value)
+		return res, nil
+	}
+	if err.Error() != "override not found" {
+		return nil, err
+	}
+
+	switch {
+	case pf != nil:
+		res.Value = pf.Value
+		res.Source = ValueSourcePlan
+	case feat.DefaultValue != nil:
+		res.Value = feat.DefaultValue
+		res.Source = ValueSourceDefault
+	}
+
+	addonValues, err := s.addonRepo.TenantFeatureValues(ctx, tenantID, projectID, feat.ID, asOf)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range addonValues {
+		if res.Source != ValueSourceNone {
+			v = mergeValues(feat.MergeStrategy, res.Value, v)
+		}
+		res.Value = v
+		res.Source = ValueSourceAddon
+	}
+
+	if res.Source != ValueSourceNone {
+		res.Enabled = isEnabled(feat.Type, res.Value)
+	}
+	return res, nil
+}
+
+// isEnabled: un flag está habilitado si vale true; numeric y value con
+// cualquier valor configurado
+func isEnabled(featureType string, value interface{}) bool {
+	if featureType == "flag" {
+		b, ok := value.(bool)
+		return ok && b
+	}
+	return value != nil
+}
+
+// BatchEntitlements resuelve muchos tenants con un número fijo de consultas;
+// el resultado respeta el orden de tenant_ids (sin duplicados). Los tenants
+// sin plan reciben los default_value, add-ons y overrides.
+func (s *entitlementService) BatchEntitlements(ctx context.Context, projectID uuid.UUID, req BatchEntitlementsRequest) (*BatchEntitlementsResponse, error) {
+	if len(req.TenantIDs) == 0 {
+		return nil, errors.New("tenant_ids is required")
+	}
+	if len(req.TenantIDs) > MaxBatchTenants {
+		return nil, fmt.Errorf("tenant_ids must contain at most %d items", MaxBatchTenants)
+	}
+	if err := validateAsOf(req.AsOf); err != nil {
+		return nil, err
+	}
+
+	seen := map[uuid.UUID]bool{}
+	tenantIDs := make([]uuid.UUID, 0, len(req.TenantIDs))
+	for _, id := range req.TenantIDs {
+		if !seen[id] {
+			seen[id] = true
+			tenantIDs = append(tenantIDs, id)
+		}
+	}
+	// mismo formato que features.normalizeCode
+	var codes []string
+	for _, c := range req.FeatureCodes {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+			codes = append(codes, c)
+		}
+	}
+
+	resolved, err := s.repo.ResolveBatch(ctx, tenantIDs, projectID, codes, req.AsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &BatchEntitlementsResponse{Results: make([]BatchTenantResult, 0, len(tenantIDs))}
+	for _, id := range tenantIDs {
+		res.Results = append(res.Results, BatchTenantResult{TenantID: id, Entitlements: resolved[id]})
+	}
+	return res, nil
+}
@@ -5,7 +5,9 @@ package features
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"plans-features/internal/auth"
 	"plans-features/internal/utils"
 
 	"github.com/go-chi/chi/v5"
@@ -20,6 +22,16 @@ func NewFeatureHandler(service FeatureService) *FeatureHandler {
 	return &FeatureHandler{service: service}
 }
 
+// isValidationError: errores de merge_strategy, reset_period, default_value o credit_cost (400)
+func isValidationError(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "invalid merge_strategy") ||
+		strings.HasPrefix(msg, "invalid reset_period") ||
+		strings.HasPrefix(msg, "value must be") ||
+		strings.HasPrefix(msg, "credit_cost must") ||
+		strings.HasSuffix(msg, "mutually exclusive")
+}
+
 // ListFeatures godoc
 // @Summary List features for a project
 // @Description List features available for the project identified by the API key
@@ -31,17 +43,11 @@ func NewFeatureHandler(service FeatureService) *FeatureHandler {
 // @Failure 500 {object} map[string]string
 // @Router /api/features [get]
 func (h *FeatureHandler) ListFeatures(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-
-	projectID, err := uuid.Parse(projectIDStr) // ← Parse middleware string
-	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid project ID")
-		return
-	}
 	fs, err := h.service.ListFeatures(r.Context(), projectID)
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err.Error())
@@ -52,7 +58,7 @@ func (h *FeatureHandler) ListFeatures(w http.ResponseWriter, r *http.Request) {
 
 // CreateFeature godoc
 // @Summary Create a feature for a project
-// @Description Create a new feature for the project identified by the API key
+// @Description Create a new feature for the project identified by the API key. default_value (validated against the type) is what tenants get when their plan does not assign the feature.
 // @Tags features
 // @Accept json
 // @Produce json
@@ -64,17 +70,11 @@ func (h *FeatureHandler) ListFeatures(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string
 // @Router /api/features [post]
 func (h *FeatureHandler) CreateFeature(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid project ID")
-		return
-	}
 	var req CreateFeatureRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.Error(w, http.StatusBadRequest, "invalid body")
@@ -86,6 +86,10 @@ func (h *FeatureHandler) CreateFeature(w http.ResponseWriter, r *http.Request) {
 	}
 	f, err := h.service.CreateFeature(r.Context(), projectID, req)
 	if err != nil {
+		if isValidationError(err) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -105,16 +109,11 @@ func (h *FeatureHandler) CreateFeature(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string
 // @Router /api/features/{featureId} [get]
 func (h *FeatureHandler) GetFeature(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid project ID")
-		return
-	}
 
 	featureIDStr := chi.URLParam(r, "featureId")
 	if !ok || featureIDStr == "" {
@@ -141,7 +140,7 @@ func (h *FeatureHandler) GetFeature(w http.ResponseWriter, r *http.Request) {
 
 // UpdateFeature godoc
 // @Summary Update a feature
-// @Description Update fields of a feature for the project identified by the API key
+// @Description Update fields of a feature for the project identified by the API key. clear_default_value removes the default value.
 // @Tags features
 // @Accept json
 // @Produce json
@@ -155,16 +154,11 @@ func (h *FeatureHandler) GetFeature(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string
 // @Router /api/features/{featureId} [put]
 func (h *FeatureHandler) UpdateFeature(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid project ID")
-		return
-	}
 
 	featureIDStr := chi.URLParam(r, "featureId")
 	if !ok || featureIDStr == "" {
@@ -188,6 +182,10 @@ func (h *FeatureHandler) UpdateFeature(w http.ResponseWriter, r *http.Request) {
 			utils.Error(w, http.StatusNotFound, "not found")
 			return
 		}
+		if isValidationError(err) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
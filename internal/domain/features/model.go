@@ -16,8 +16,16 @@ type Feature struct {
 	Name        string    `db:"name"`
 	Description *string   `db:"description"`
 	IsActive    bool      `db:"is_active"`
-	CreatedAt   time.Time `db:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at"`
+	// MergeStrategy: cómo se combina el valor de un add-on con el del plan
+	MergeStrategy string `db:"merge_strategy"`
+	// DefaultValue: valor cuando el plan no asigna la feature (nil = ninguno)
+	DefaultValue interface{} `db:"default_value"`
+	// ResetPeriod: ventana de cuota de una feature numeric (none = nunca se reinicia)
+	ResetPeriod string `db:"reset_period"`
+	// CreditCost: créditos que cuesta cada unidad de uso (nil = no se cobra)
+	CreditCost *float64  `db:"credit_cost"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
 }
 
 type CreateFeatureRequest struct {
@@ -26,32 +34,56 @@ type CreateFeatureRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description,omitempty"`
 	IsActive    *bool  `json:"is_active,omitempty"`
+	// vacío = estrategia por defecto del tipo (flag: or, numeric: sum, value: override)
+	MergeStrategy string `json:"merge_strategy,omitempty"`
+	// mismas reglas de tipo que los valores de plan
+	DefaultValue interface{} `json:"default_value,omitempty"`
+	// vacío = none; solo las features numeric admiten otro periodo
+	ResetPeriod string `json:"reset_period,omitempty"`
+	// créditos por unidad al debitar la feature del saldo del tenant
+	CreditCost *float64 `json:"credit_cost,omitempty"`
 }
 
 type UpdateFeatureRequest struct {
-	Name        *string `json:"name,omitempty"`
-	Description *string `json:"description,omitempty"`
-	IsActive    *bool   `json:"is_active,omitempty"`
+	Name          *string     `json:"name,omitempty"`
+	Description   *string     `json:"description,omitempty"`
+	IsActive      *bool       `json:"is_active,omitempty"`
+	MergeStrategy *string     `json:"merge_strategy,omitempty"`
+	DefaultValue  interface{} `json:"default_value,omitempty"`
+	// ClearDefaultValue quita el valor por defecto
+	ClearDefaultValue bool     `json:"clear_default_value,omitempty"`
+	ResetPeriod       *string  `json:"reset_period,omitempty"`
+	CreditCost        *float64 `json:"credit_cost,omitempty"`
+	// ClearCreditCost deja de cobrar la feature en créditos
+	ClearCreditCost bool `json:"clear_credit_cost,omitempty"`
 }
 
 type FeatureResponse struct {
-	ID          uuid.UUID `json:"id"`
-	ProjectID   uuid.UUID `json:"project_id"`
-	Code        string    `json:"code"`
-	Type        string    `json:"type"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	IsActive    bool      `json:"is_active"`
+	ID            uuid.UUID   `json:"id"`
+	ProjectID     uuid.UUID   `json:"project_id"`
+	Code          string      `json:"code"`
+	Type          string      `json:"type"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	IsActive      bool        `json:"is_active"`
+	MergeStrategy string      `json:"merge_strategy"`
+	DefaultValue  interface{} `json:"default_value,omitempty"`
+	ResetPeriod   string      `json:"reset_period"`
+	CreditCost    *float64    `json:"credit_cost,omitempty"`
 }
 
 func ToResponse(feat *Feature) *FeatureResponse {
 	resp := &FeatureResponse{
-		ID:        feat.ID,
-		ProjectID: feat.ProjectID,
-		Code:      feat.Code,
-		Type:      feat.Type,
-		Name:      feat.Name,
-		IsActive:  feat.IsActive,
+		ID:            feat.ID,
+		ProjectID:     feat.ProjectID,
+		Code:          feat.Code,
+		Type:          feat.Type,
+		Name:          feat.Name,
+		IsActive:      feat.IsActive,
+		MergeStrategy: feat.MergeStrategy,
+		DefaultValue:  feat.DefaultValue,
+		ResetPeriod:   feat.ResetPeriod,
+		CreditCost:    feat.CreditCost,
 	}
 	if feat.Description != nil {
 		resp.Description = *feat.Description
@@ -3,6 +3,7 @@ package features
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -14,6 +15,7 @@ type FeatureRepository interface {
 	List(ctx context.Context, projectID uuid.UUID) ([]FeatureResponse, error)
 	Create(ctx context.Context, projectID uuid.UUID, req CreateFeatureRequest) (*FeatureResponse, error)
 	GetByID(ctx context.Context, projectID uuid.UUID, featureID uuid.UUID) (*FeatureResponse, error)
+	GetByCode(ctx context.Context, projectID uuid.UUID, code string) (*FeatureResponse, error)
 	Update(ctx context.Context, projectID uuid.UUID, featureID uuid.UUID, req UpdateFeatureRequest) (*FeatureResponse, error)
 }
 
@@ -29,9 +31,45 @@ func normalizeCode(code string) string {
 	return strings.ToLower(strings.TrimSpace(code))
 }
 
+const featureColumns = `id, project_id, code, type, name, description, is_active, merge_strategy, default_value, reset_period, credit_cost, created_at, updated_at`
+
+// marshalDefault: interface{} → JSONB; nil → NULL
+func marshalDefault(value interface{}) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("marshal default_value: %w", err)
+	}
+	return b, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFeature(row rowScanner) (*Feature, error) {
+	feat := &Feature{}
+	var desc sql.NullString
+	var defaultJSON []byte
+	if err := row.Scan(&feat.ID, &feat.ProjectID, &feat.Code, &feat.Type, &feat.Name,
+		&desc, &feat.IsActive, &feat.MergeStrategy, &defaultJSON, &feat.ResetPeriod, &feat.CreditCost, &feat.CreatedAt, &feat.UpdatedAt); err != nil {
+		return nil, err
+	}
+	feat.Description = nullStringToPtr(desc)
+	// JSONB → interface{} (NULL = sin valor por defecto)
+	if defaultJSON != nil {
+		if err := json.Unmarshal(defaultJSON, &feat.DefaultValue); err != nil {
+			return nil, fmt.Errorf("unmarshal default_value: %w", err)
+		}
+	}
+	return feat, nil
+}
+
 func (r *featureRepository) List(ctx context.Context, projectID uuid.UUID) ([]FeatureResponse, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, project_id, code, type, name, description, is_active, created_at, updated_at
+		`SELECT `+featureColumns+`
          FROM features 
          WHERE project_id = $1 AND is_active = true 
          ORDER BY created_at DESC`,
@@ -43,13 +81,10 @@ func (r *featureRepository) List(ctx context.Context, projectID uuid.UUID) ([]Fe
 
 	var features []FeatureResponse
 	for rows.Next() {
-		feat := &Feature{}
-		var desc sql.NullString
-		if err := rows.Scan(&feat.ID, &feat.ProjectID, &feat.Code, &feat.Type,
-			&feat.Name, &desc, &feat.IsActive, &feat.CreatedAt, &feat.UpdatedAt); err != nil {
+		feat, err := scanFeature(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan feature: %w", err)
 		}
-		feat.Description = nullStringToPtr(desc)
 		features = append(features, *ToResponse(feat))
 	}
 	return features, rows.Err()
@@ -65,15 +100,16 @@ func (r *featureRepository) Create(ctx context.Context, projectID uuid.UUID, req
 	if req.Description != "" {
 		description = &req.Description
 	}
+	defaultJSON, err := marshalDefault(req.DefaultValue)
+	if err != nil {
+		return nil, err
+	}
 
-	feat := &Feature{}
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO features (id, project_id, code, type, name, description, is_active)
-         VALUES ($1, $2, $3, $4, $5, $6, $7)
-         RETURNING id, project_id, code, type, name, description, is_active, created_at, updated_at`,
-		id, projectID, normalizeCode(req.Code), req.Type, req.Name, description, isActive).
-		Scan(&feat.ID, &feat.ProjectID, &feat.Code, &feat.Type, &feat.Name,
-			&feat.Description, &feat.IsActive, &feat.CreatedAt, &feat.UpdatedAt)
+	feat, err := scanFeature(r.db.QueryRowContext(ctx,
+		`INSERT INTO features (id, project_id, code, type, name, description, is_active, merge_strategy, default_value, reset_period, credit_cost)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+         RETURNING `+featureColumns,
+		id, projectID, normalizeCode(req.Code), req.Type, req.Name, description, isActive, req.MergeStrategy, defaultJSON, req.ResetPeriod, req.CreditCost))
 
 	if err != nil {
 		return nil, fmt.Errorf("create feature: %w", err)
@@ -82,15 +118,11 @@ func (r *featureRepository) Create(ctx context.Context, projectID uuid.UUID, req
 }
 
 func (r *featureRepository) GetByID(ctx context.Context, projectID uuid.UUID, featureID uuid.UUID) (*FeatureResponse, error) {
-	feat := &Feature{}
-	var desc sql.NullString
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, project_id, code, type, name, description, is_active, created_at, updated_at
+	feat, err := scanFeature(r.db.QueryRowContext(ctx,
+		`SELECT `+featureColumns+`
          FROM features 
          WHERE project_id = $1 AND id = $2`,
-		projectID, featureID).
-		Scan(&feat.ID, &feat.ProjectID, &feat.Code, &feat.Type, &feat.Name,
-			&desc, &feat.IsActive, &feat.CreatedAt, &feat.UpdatedAt)
+		projectID, featureID))
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.New("feature not found")
@@ -98,9 +130,24 @@ func (r *featureRepository) GetByID(ctx context.Context, projectID uuid.UUID, fe
 	if err != nil {
 		return nil, fmt.Errorf("get feature: %w", err)
 	}
+	return ToResponse(feat), nil
+}
+
+// GetByCode prioriza la feature activa: el código solo es único entre las activas
+func (r *featureRepository) GetByCode(ctx context.Context, projectID uuid.UUID, code string) (*FeatureResponse, error) {
+	feat, err := scanFeature(r.db.QueryRowContext(ctx,
+		`SELECT `+featureColumns+`
+         FROM features 
+         WHERE project_id = $1 AND code = $2
+         ORDER BY is_active DESC, created_at DESC
+         LIMIT 1`,
+		projectID, normalizeCode(code)))
 
-	if desc.Valid {
-		feat.Description = &desc.String
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("feature not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get feature by code: %w", err)
 	}
 	return ToResponse(feat), nil
 }
@@ -130,6 +177,34 @@ func (r *featureRepository) Update(ctx context.Context, projectID uuid.UUID, fea
 		args = append(args, *req.IsActive)
 		argIdx++
 	}
+	if req.MergeStrategy != nil {
+		updates = append(updates, fmt.Sprintf("merge_strategy = $%d", argIdx))
+		args = append(args, *req.MergeStrategy)
+		argIdx++
+	}
+	if req.ResetPeriod != nil {
+		updates = append(updates, fmt.Sprintf("reset_period = $%d", argIdx))
+		args = append(args, *req.ResetPeriod)
+		argIdx++
+	}
+	if req.ClearCreditCost {
+		updates = append(updates, "credit_cost = NULL")
+	} else if req.CreditCost != nil {
+		updates = append(updates, fmt.Sprintf("credit_cost = $%d", argIdx))
+		args = append(args, *req.CreditCost)
+		argIdx++
+	}
+	if req.ClearDefaultValue {
+		updates = append(updates, "default_value = NULL")
+	} else if req.DefaultValue != nil {
+		defaultJSON, err := marshalDefault(req.DefaultValue)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, fmt.Sprintf("default_value = $%d", argIdx))
+		args = append(args, defaultJSON)
+		argIdx++
+	}
 
 	if len(updates) == 0 {
 		return r.GetByID(ctx, projectID, featureID)
@@ -142,15 +217,11 @@ func (r *featureRepository) Update(ctx context.Context, projectID uuid.UUID, fea
 		`UPDATE features 
          SET %s, updated_at = NOW()
          WHERE project_id = $%d AND %s
-         RETURNING id, project_id, code, type, name, description, is_active, created_at, updated_at`,
+         RETURNING `+featureColumns,
 		strings.Join(updates[:len(updates)-1], ", "),
 		argIdx, updates[len(updates)-1])
 
-	feat := &Feature{}
-	var desc sql.NullString
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(
-		&feat.ID, &feat.ProjectID, &feat.Code, &feat.Type, &feat.Name,
-		&desc, &feat.IsActive, &feat.CreatedAt, &feat.UpdatedAt)
+	feat, err := scanFeature(r.db.QueryRowContext(ctx, query, args...))
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.New("feature not found")
@@ -158,9 +229,5 @@ func (r *featureRepository) Update(ctx context.Context, projectID uuid.UUID, fea
 	if err != nil {
 		return nil, fmt.Errorf("update feature: %w", err)
 	}
-
-	if desc.Valid {
-		feat.Description = &desc.String
-	}
 	return ToResponse(feat), nil
 }
@@ -3,6 +3,7 @@ package features
 import (
 	"context"
 	"errors"
+	"fmt"
 	"plans-features/internal/domain/projects"
 
 	"github.com/google/uuid"
@@ -33,6 +34,92 @@ func isValidType(t string) bool {
 	}
 }
 
+// Estrategias para combinar el valor de un add-on con el del plan
+const (
+	MergeOr       = "or"       // flag: habilitado si cualquiera lo habilita
+	MergeSum      = "sum"      // numeric: se suman los valores
+	MergeMax      = "max"      // numeric: gana el mayor
+	MergeOverride = "override" // value: el add-on sustituye al plan
+)
+
+// DefaultMergeStrategy devuelve la estrategia de un tipo cuando no se indica
+func DefaultMergeStrategy(featureType string) string {
+	switch featureType {
+	case "flag":
+		return MergeOr
+	case "numeric":
+		return MergeSum
+	default:
+		return MergeOverride
+	}
+}
+
+// ValidateMergeStrategy: flag solo admite or, numeric sum o max y value override
+func ValidateMergeStrategy(featureType string, strategy string) error {
+	valid := false
+	switch featureType {
+	case "flag":
+		valid = strategy == MergeOr
+	case "numeric":
+		valid = strategy == MergeSum || strategy == MergeMax
+	case "value":
+		valid = strategy == MergeOverride
+	}
+	if !valid {
+		return fmt.Errorf("invalid merge_strategy %q for %s feature", strategy, featureType)
+	}
+	return nil
+}
+
+// Periodos de reset de la cuota de una feature numeric
+const (
+	ResetNone    = "none"
+	ResetDaily   = "daily"
+	ResetWeekly  = "weekly"
+	ResetMonthly = "monthly"
+	ResetYearly  = "yearly"
+)
+
+// ValidateResetPeriod: solo las features numeric pueden reiniciarse
+func ValidateResetPeriod(featureType string, period string) error {
+	switch period {
+	case ResetNone:
+		return nil
+	case ResetDaily, ResetWeekly, ResetMonthly, ResetYearly:
+		if featureType != "numeric" {
+			return fmt.Errorf("invalid reset_period %q for %s feature", period, featureType)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid reset_period %q", period)
+	}
+}
+
+// ValidateValue comprueba que value encaja con el tipo de la feature
+// (mismas reglas para valores de plan y overrides de tenant)
+func ValidateValue(featureType string, value interface{}) error {
+	switch featureType {
+	case "flag":
+		if _, ok := value.(bool); !ok {
+			return errors.New("value must be boolean for flag feature")
+		}
+	case "numeric":
+		switch value.(type) {
+		case float64, float32, int, int64, int32:
+			// ok
+		default:
+			return errors.New("value must be numeric for numeric feature")
+		}
+	case "value":
+		if _, ok := value.(string); !ok {
+			return errors.New("value must be string for value feature")
+		}
+	default:
+		return errors.New("invalid feature type")
+	}
+	return nil
+}
+
 func (s *featureService) ListFeatures(ctx context.Context, projectID uuid.UUID) ([]FeatureResponse, error) {
 	return s.repo.List(ctx, projectID)
 }
@@ -50,6 +137,25 @@ func (s *featureService) CreateFeature(ctx context.Context, projectID uuid.UUID,
 	if !isValidType(req.Type) {
 		return nil, errors.New("invalid type")
 	}
+	if req.MergeStrategy == "" {
+		req.MergeStrategy = DefaultMergeStrategy(req.Type)
+	} else if err := ValidateMergeStrategy(req.Type, req.MergeStrategy); err != nil {
+		return nil, err
+	}
+	if req.ResetPeriod == "" {
+		req.ResetPeriod = ResetNone
+	} else if err := ValidateResetPeriod(req.Type, req.ResetPeriod); err != nil {
+		return nil, err
+	}
+	// default value follows the same type rules as plan values
+	if req.DefaultValue != nil {
+		if err := ValidateValue(req.Type, req.DefaultValue); err != nil {
+			return nil, err
+		}
+	}
+	if req.CreditCost != nil && *req.CreditCost < 0 {
+		return nil, errors.New("credit_cost must be >= 0")
+	}
 	// code unique within project
 	existing, err := s.repo.List(ctx, projectID)
 	if err != nil {
@@ -73,6 +179,37 @@ func (s *featureService) UpdateFeature(ctx context.Context, projectID uuid.UUID,
 	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
 		return nil, errors.New("project not found")
 	}
+	if req.DefaultValue != nil && req.ClearDefaultValue {
+		return nil, errors.New("default_value and clear_default_value are mutually exclusive")
+	}
+	if req.CreditCost != nil && req.ClearCreditCost {
+		return nil, errors.New("credit_cost and clear_credit_cost are mutually exclusive")
+	}
+	if req.CreditCost != nil && *req.CreditCost < 0 {
+		return nil, errors.New("credit_cost must be >= 0")
+	}
+	// strategy, default value and reset period must fit the (immutable) feature type
+	if req.MergeStrategy != nil || req.DefaultValue != nil || req.ResetPeriod != nil {
+		current, err := s.repo.GetByID(ctx, projectID, featureID)
+		if err != nil {
+			return nil, err
+		}
+		if req.MergeStrategy != nil {
+			if err := ValidateMergeStrategy(current.Type, *req.MergeStrategy); err != nil {
+				return nil, err
+			}
+		}
+		if req.DefaultValue != nil {
+			if err := ValidateValue(current.Type, req.DefaultValue); err != nil {
+				return nil, err
+			}
+		}
+		if req.ResetPeriod != nil {
+			if err := ValidateResetPeriod(current.Type, *req.ResetPeriod); err != nil {
+				return nil, err
+			}
+		}
+	}
 	// ignore code changes (UpdateFeatureRequest has no Code)
 	return s.repo.Update(ctx, projectID, featureID, req)
 }
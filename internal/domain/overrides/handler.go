@@ -0,0 +1,224 @@
+package overrides
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type OverrideHandler struct {
+	service OverrideService
+}
+
+func NewOverrideHandler(s OverrideService) *OverrideHandler {
+	return &OverrideHandler{service: s}
+}
+
+// errorStatus traduce los errores del servicio a códigos HTTP
+func errorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		return http.StatusNotFound
+	case msg == "override already exists for feature":
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "value must be"),
+		strings.HasSuffix(msg, "is required"),
+		strings.HasSuffix(msg, "must be in the future"),
+		strings.HasSuffix(msg, "mutually exclusive"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func parseTenantID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+func parseOverrideID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	overrideID, err := uuid.Parse(chi.URLParam(r, "overrideId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid override ID")
+		return uuid.Nil, false
+	}
+	return overrideID, true
+}
+
+// ListOverrides godoc
+// @Summary List feature overrides for a tenant
+// @Description Admin: list the tenant's feature overrides, including expired ones (active=false)
+// @Tags overrides
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param project_id query string false "Filter by project ID"
+// @Success 200 {array} overrides.OverrideResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/overrides [get]
+func (h *OverrideHandler) ListOverrides(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := parseTenantID(w, r)
+	if !ok {
+		return
+	}
+	var projectID *uuid.UUID
+	if s := r.URL.Query().Get("project_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid project ID format")
+			return
+		}
+		projectID = &id
+	}
+	list, err := h.service.ListOverrides(r.Context(), tenantID, projectID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// CreateOverride godoc
+// @Summary Create a feature override for a tenant
+// @Description Admin: override a feature value for one tenant on top of its plan. The value is validated against the feature type; with expires_at the override stops applying automatically.
+// @Tags overrides
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param body body overrides.CreateOverrideRequest true "Create override"
+// @Success 201 {object} overrides.OverrideResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/overrides [post]
+func (h *OverrideHandler) CreateOverride(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := parseTenantID(w, r)
+	if !ok {
+		return
+	}
+	var req CreateOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	o, err := h.service.CreateOverride(r.Context(), tenantID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusCreated, o)
+}
+
+// GetOverride godoc
+// @Summary Get a feature override
+// @Description Admin: get one feature override of the tenant
+// @Tags overrides
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param overrideId path string true "Override ID"
+// @Success 200 {object} overrides.OverrideResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/overrides/{overrideId} [get]
+func (h *OverrideHandler) GetOverride(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := parseTenantID(w, r)
+	if !ok {
+		return
+	}
+	overrideID, ok := parseOverrideID(w, r)
+	if !ok {
+		return
+	}
+	o, err := h.service.GetOverride(r.Context(), tenantID, overrideID)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, o)
+}
+
+// UpdateOverride godoc
+// @Summary Update a feature override
+// @Description Admin: update value, reason or expiry of an override. clear_expires_at makes it permanent.
+// @Tags overrides
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param overrideId path string true "Override ID"
+// @Param body body overrides.UpdateOverrideRequest true "Update override"
+// @Success 200 {object} overrides.OverrideResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/overrides/{overrideId} [patch]
+func (h *OverrideHandler) UpdateOverride(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := parseTenantID(w, r)
+	if !ok {
+		return
+	}
+	overrideID, ok := parseOverrideID(w, r)
+	if !ok {
+		return
+	}
+	var req UpdateOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	o, err := h.service.UpdateOverride(r.Context(), tenantID, overrideID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, o)
+}
+
+// DeleteOverride godoc
+// @Summary Delete a feature override
+// @Description Admin: remove an override; the tenant goes back to its plan value
+// @Tags overrides
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param overrideId path string true "Override ID"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/overrides/{overrideId} [delete]
+func (h *OverrideHandler) DeleteOverride(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := parseTenantID(w, r)
+	if !ok {
+		return
+	}
+	overrideID, ok := parseOverrideID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteOverride(r.Context(), tenantID, overrideID); err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,76 @@
+package overrides
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Para DB (Scan)
+type Override struct {
+	ID          uuid.UUID   `db:"id"`
+	TenantID    uuid.UUID   `db:"tenant_id"`
+	ProjectID   uuid.UUID   `db:"project_id"`
+	FeatureID   uuid.UUID   `db:"feature_id"`
+	FeatureCode string      `db:"feature_code"`
+	FeatureType string      `db:"feature_type"`
+	Value       interface{} `db:"value_json"`
+	Reason      *string     `db:"reason"`
+	ExpiresAt   *time.Time  `db:"expires_at"`
+	CreatedAt   time.Time   `db:"created_at"`
+	UpdatedAt   time.Time   `db:"updated_at"`
+}
+
+// CreateOverrideRequest: la feature se indica por feature_id o feature_code
+type CreateOverrideRequest struct {
+	ProjectID   uuid.UUID   `json:"project_id"`
+	FeatureID   *uuid.UUID  `json:"feature_id,omitempty"`
+	FeatureCode *string     `json:"feature_code,omitempty"`
+	Value       interface{} `json:"value"`
+	Reason      string      `json:"reason,omitempty"`
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`
+}
+
+// UpdateOverrideRequest: solo se modifican los campos presentes;
+// clear_expires_at convierte el override en permanente.
+type UpdateOverrideRequest struct {
+	Value          interface{} `json:"value,omitempty"`
+	Reason         *string     `json:"reason,omitempty"`
+	ExpiresAt      *time.Time  `json:"expires_at,omitempty"`
+	ClearExpiresAt bool        `json:"clear_expires_at,omitempty"`
+}
+
+type OverrideResponse struct {
+	ID          uuid.UUID   `json:"id"`
+	TenantID    uuid.UUID   `json:"tenant_id"`
+	ProjectID   uuid.UUID   `json:"project_id"`
+	FeatureID   uuid.UUID   `json:"feature_id"`
+	FeatureCode string      `json:"feature_code"`
+	FeatureType string      `json:"feature_type"`
+	Value       interface{} `json:"value"`
+	Reason      string      `json:"reason,omitempty"`
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`
+	Active      bool        `json:"active"` // false si ya caducó
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+func ToResponse(o *Override) *OverrideResponse {
+	resp := &OverrideResponse{
+		ID:          o.ID,
+		TenantID:    o.TenantID,
+		ProjectID:   o.ProjectID,
+		FeatureID:   o.FeatureID,
+		FeatureCode: o.FeatureCode,
+		FeatureType: o.FeatureType,
+		Value:       o.Value,
+		ExpiresAt:   o.ExpiresAt,
+		Active:      o.ExpiresAt == nil || o.ExpiresAt.After(time.Now()),
+		CreatedAt:   o.CreatedAt,
+		UpdatedAt:   o.UpdatedAt,
+	}
+	if o.Reason != nil {
+		resp.Reason = *o.Reason
+	}
+	return resp
+}
@@ -0,0 +1,203 @@
+package overrides
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OverrideRepository interface {
+	List(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]OverrideResponse, error)
+	GetByID(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID) (*OverrideResponse, error)
+	GetActive(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, asOf *time.Time) (*OverrideResponse, error)
+	Create(ctx context.Context, tenantID uuid.UUID, featureID uuid.UUID, req CreateOverrideRequest) (*OverrideResponse, error)
+	Update(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID, req UpdateOverrideRequest) (*OverrideResponse, error)
+	Delete(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID) error
+}
+
+type overrideRepository struct {
+	db *sql.DB
+}
+
+func NewOverrideRepository(db *sql.DB) OverrideRepository {
+	return &overrideRepository{db: db}
+}
+
+// overrideColumns: o = tenant_feature_overrides, f = features
+const overrideColumns = `o.id, o.tenant_id, o.project_id, o.feature_id, f.code, f.type, o.value_json, o.reason, o.expires_at, o.created_at, o.updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOverride(row rowScanner) (*Override, error) {
+	o := &Override{}
+	var tenantID string
+	var valueJSON []byte
+	if err := row.Scan(&o.ID, &tenantID, &o.ProjectID, &o.FeatureID, &o.FeatureCode, &o.FeatureType,
+		&valueJSON, &o.Reason, &o.ExpiresAt, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if o.TenantID, err = uuid.Parse(tenantID); err != nil {
+		return nil, fmt.Errorf("parse tenant id: %w", err)
+	}
+	// JSONB → interface{}
+	if err := json.Unmarshal(valueJSON, &o.Value); err != nil {
+		return nil, fmt.Errorf("unmarshal value_json: %w", err)
+	}
+	return o, nil
+}
+
+func (r *overrideRepository) List(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]OverrideResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+overrideColumns+`
+         FROM tenant_feature_overrides o
+         JOIN features f ON f.id = o.feature_id
+         WHERE o.tenant_id = $1 AND ($2::uuid IS NULL OR o.project_id = $2)
+         ORDER BY o.created_at DESC`,
+		tenantID.String(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("list overrides: %w", err)
+	}
+	defer rows.Close()
+
+	var results []OverrideResponse
+	for rows.Next() {
+		o, err := scanOverride(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan override: %w", err)
+		}
+		results = append(results, *ToResponse(o))
+	}
+	return results, rows.Err()
+}
+
+func (r *overrideRepository) GetByID(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID) (*OverrideResponse, error) {
+	o, err := scanOverride(r.db.QueryRowContext(ctx,
+		`SELECT `+overrideColumns+`
+         FROM tenant_feature_overrides o
+         JOIN features f ON f.id = o.feature_id
+         WHERE o.tenant_id = $1 AND o.id = $2`,
+		tenantID.String(), overrideID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("override not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get override: %w", err)
+	}
+	return ToResponse(o), nil
+}
+
+// GetActive devuelve el override vigente (no caducado) de una feature; con
+// asOf, el que ya existía y seguía vigente en esa fecha
+func (r *overrideRepository) GetActive(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, asOf *time.Time) (*OverrideResponse, error) {
+	o, err := scanOverride(r.db.QueryRowContext(ctx,
+		`SELECT `+overrideColumns+`
+         FROM tenant_feature_overrides o
+         JOIN features f ON f.id = o.feature_id
+         WHERE o.tenant_id = $1 AND o.project_id = $2 AND o.feature_id = $3
+           AND o.created_at <= COALESCE($4::timestamptz, NOW())
+           AND (o.expires_at IS NULL OR o.expires_at > COALESCE($4::timestamptz, NOW()))`,
+		tenantID.String(), projectID, featureID, asOf))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("override not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get active override: %w", err)
+	}
+	return ToResponse(o), nil
+}
+
+// Create inserta el override; si ya existe uno caducado para la misma feature
+// lo sustituye, si sigue vigente devuelve error.
+func (r *overrideRepository) Create(ctx context.Context, tenantID uuid.UUID, featureID uuid.UUID, req CreateOverrideRequest) (*OverrideResponse, error) {
+	// interface{} → JSONB
+	valueJSON, err := json.Marshal(req.Value)
+	if err != nil {
+		return nil, fmt.Errorf("marshal value: %w", err)
+	}
+	var reason *string
+	if req.Reason != "" {
+		reason = &req.Reason
+	}
+
+	o, err := scanOverride(r.db.QueryRowContext(ctx,
+		`WITH o AS (
+             INSERT INTO tenant_feature_overrides (id, tenant_id, project_id, feature_id, value_json, reason, expires_at)
+             VALUES ($1, $2, $3, $4, $5, $6, $7)
+             ON CONFLICT (tenant_id, project_id, feature_id) DO UPDATE
+             SET id = EXCLUDED.id,
+                 value_json = EXCLUDED.value_json,
+                 reason = EXCLUDED.reason,
+                 expires_at = EXCLUDED.expires_at,
+                 created_at = NOW()
+             WHERE tenant_feature_overrides.expires_at IS NOT NULL
+               AND tenant_feature_overrides.expires_at <= NOW()
+             RETURNING *
+         )
+         SELECT `+overrideColumns+`
+         FROM o
+         JOIN features f ON f.id = o.feature_id`,
+		uuid.New(), tenantID.String(), req.ProjectID, featureID, valueJSON, reason, req.ExpiresAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("override already exists for feature")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create override: %w", err)
+	}
+	return ToResponse(o), nil
+}
+
+func (r *overrideRepository) Update(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID, req UpdateOverrideRequest) (*OverrideResponse, error) {
+	var valueJSON []byte
+	if req.Value != nil {
+		var err error
+		if valueJSON, err = json.Marshal(req.Value); err != nil {
+			return nil, fmt.Errorf("marshal value: %w", err)
+		}
+	}
+
+	o, err := scanOverride(r.db.QueryRowContext(ctx,
+		`WITH o AS (
+             UPDATE tenant_feature_overrides
+             SET value_json = COALESCE($1, value_json),
+                 reason = COALESCE($2, reason),
+                 expires_at = CASE WHEN $3 THEN NULL ELSE COALESCE($4, expires_at) END
+             WHERE tenant_id = $5 AND id = $6
+             RETURNING *
+         )
+         SELECT `+overrideColumns+`
+         FROM o
+         JOIN features f ON f.id = o.feature_id`,
+		valueJSON, req.Reason, req.ClearExpiresAt, req.ExpiresAt, tenantID.String(), overrideID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("override not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update override: %w", err)
+	}
+	return ToResponse(o), nil
+}
+
+func (r *overrideRepository) Delete(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM tenant_feature_overrides WHERE tenant_id = $1 AND id = $2`,
+		tenantID.String(), overrideID)
+	if err != nil {
+		return fmt.Errorf("delete override: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("override not found")
+	}
+	return nil
+}
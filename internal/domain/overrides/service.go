@@ -0,0 +1,98 @@
+package overrides
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"plans-features/internal/domain/features"
+	"plans-features/internal/domain/projects"
+
+	"github.com/google/uuid"
+)
+
+type OverrideService interface {
+	ListOverrides(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]OverrideResponse, error)
+	GetOverride(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID) (*OverrideResponse, error)
+	CreateOverride(ctx context.Context, tenantID uuid.UUID, req CreateOverrideRequest) (*OverrideResponse, error)
+	UpdateOverride(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID, req UpdateOverrideRequest) (*OverrideResponse, error)
+	DeleteOverride(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID) error
+}
+
+type overrideService struct {
+	repo        OverrideRepository
+	featureRepo features.FeatureRepository
+	projectRepo projects.ProjectRepository
+}
+
+func NewOverrideService(repo OverrideRepository, featureRepo features.FeatureRepository, projectRepo projects.ProjectRepository) OverrideService {
+	return &overrideService{repo: repo, featureRepo: featureRepo, projectRepo: projectRepo}
+}
+
+func (s *overrideService) ListOverrides(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]OverrideResponse, error) {
+	return s.repo.List(ctx, tenantID, projectID)
+}
+
+func (s *overrideService) GetOverride(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID) (*OverrideResponse, error) {
+	return s.repo.GetByID(ctx, tenantID, overrideID)
+}
+
+func (s *overrideService) CreateOverride(ctx context.Context, tenantID uuid.UUID, req CreateOverrideRequest) (*OverrideResponse, error) {
+	// validate project exists
+	if req.ProjectID == uuid.Nil {
+		return nil, errors.New("project_id is required")
+	}
+	if _, err := s.projectRepo.GetByID(ctx, req.ProjectID); err != nil {
+		return nil, errors.New("project not found")
+	}
+
+	// feature by id or code, always within the project
+	var (
+		feature *features.FeatureResponse
+		err     error
+	)
+	switch {
+	case req.FeatureID != nil:
+		feature, err = s.featureRepo.GetByID(ctx, req.ProjectID, *req.FeatureID)
+	case req.FeatureCode != nil:
+		feature, err = s.featureRepo.GetByCode(ctx, req.ProjectID, *req.FeatureCode)
+	default:
+		return nil, errors.New("feature_id or feature_code is required")
+	}
+	if err != nil {
+		return nil, errors.New("feature not found")
+	}
+
+	// same rules as plan values
+	if err := features.ValidateValue(feature.Type, req.Value); err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	return s.repo.Create(ctx, tenantID, feature.ID, req)
+}
+
+func (s *overrideService) UpdateOverride(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID, req UpdateOverrideRequest) (*OverrideResponse, error) {
+	current, err := s.repo.GetByID(ctx, tenantID, overrideID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Value != nil {
+		if err := features.ValidateValue(current.FeatureType, req.Value); err != nil {
+			return nil, err
+		}
+	}
+	if req.ExpiresAt != nil && req.ClearExpiresAt {
+		return nil, errors.New("expires_at and clear_expires_at are mutually exclusive")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	return s.repo.Update(ctx, tenantID, overrideID, req)
+}
+
+func (s *overrideService) DeleteOverride(ctx context.Context, tenantID uuid.UUID, overrideID uuid.UUID) error {
+	return s.repo.Delete(ctx, tenantID, overrideID)
+}
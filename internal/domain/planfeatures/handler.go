@@ -3,7 +3,9 @@ package planfeatures
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"plans-features/internal/auth"
 	"plans-features/internal/utils"
 
 	"github.com/go-chi/chi/v5"
@@ -20,28 +22,23 @@ func NewPlanFeatureHandler(s PlanFeatureService) *PlanFeatureHandler {
 
 // List godoc
 // @Summary List features assigned to a plan
-// @Description List features and their values assigned to the given plan
+// @Description List features and their values assigned to the given plan. With inherited=true returns the flattened view through the parent chain, where child values override parent values and each item carries its source plan.
 // @Tags planfeatures
 // @Produce json
 // @Param X-API-Key header string true "API Key"
 // @Param planId path string true "Plan ID"
+// @Param inherited query bool false "Include values inherited from parent plans"
 // @Success 200 {array} planfeatures.PlanFeatureResponse
 // @Failure 401 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/plans/{planId}/features [get]
 func (h *PlanFeatureHandler) List(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
 
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
-		return
-	}
-
 	planIDStr := chi.URLParam(r, "planId")
 	if !ok || planIDStr == "" {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
@@ -54,7 +51,8 @@ func (h *PlanFeatureHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res, err := h.service.ListByPlan(r.Context(), projectID, planID)
+	inherited := r.URL.Query().Get("inherited") == "true"
+	res, err := h.service.ListByPlan(r.Context(), projectID, planID, inherited)
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -64,7 +62,7 @@ func (h *PlanFeatureHandler) List(w http.ResponseWriter, r *http.Request) {
 
 // Assign godoc
 // @Summary Assign a feature to a plan
-// @Description Assign a feature to a plan with a value. Feature and plan must belong to the same project.
+// @Description Assign a feature to a plan with a value. Feature and plan must belong to the same project. The change goes into the plan's draft version and only reaches tenants once published (a plan with no published version yet resolves its current features). Numeric features accept a quota policy: enforcement (hard by default, soft, unlimited) and alert_thresholds (percentages of the limit).
 // @Tags planfeatures
 // @Accept json
 // @Produce json
@@ -77,16 +75,11 @@ func (h *PlanFeatureHandler) List(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string
 // @Router /api/plans/{planId}/features [post]
 func (h *PlanFeatureHandler) Assign(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
-		return
-	}
 
 	planIDStr := chi.URLParam(r, "planId")
 	planID, err := uuid.Parse(planIDStr)
@@ -111,3 +104,172 @@ func (h *PlanFeatureHandler) Assign(w http.ResponseWriter, r *http.Request) {
 	}
 	utils.JSON(w, http.StatusCreated, res)
 }
+
+// Update godoc
+// @Summary Update a plan feature assignment
+// @Description Update the value and/or the quota policy of a feature assigned to the plan. The change goes into the plan's draft version and only reaches tenants once published (a plan with no published version yet resolves its current features). enforcement (hard, soft, unlimited) and alert_thresholds (percentages of the limit; [] removes them) only apply to numeric features.
+// @Tags planfeatures
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param planId path string true "Plan ID"
+// @Param featureId path string true "Feature ID"
+// @Param body body planfeatures.UpdatePlanFeatureRequest true "Update assignment"
+// @Success 200 {object} planfeatures.PlanFeatureResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/plans/{planId}/features/{featureId} [patch]
+func (h *PlanFeatureHandler) Update(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+
+	planID, err := uuid.Parse(chi.URLParam(r, "planId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid plan ID format")
+		return
+	}
+	featureID, err := uuid.Parse(chi.URLParam(r, "featureId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid feature ID format")
+		return
+	}
+
+	var req UpdatePlanFeatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.UpdateFeature(r.Context(), projectID, planID, featureID, req)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			utils.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// versionErrorStatus traduce los errores de versiones a códigos HTTP
+func versionErrorStatus(err error) int {
+	switch err.Error() {
+	case "plan not found", "plan version not found":
+		return http.StatusNotFound
+	case "no draft version to publish":
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListVersions godoc
+// @Summary List plan versions
+// @Description Versions of the plan, newest first. Editing the plan's features opens a draft version; published versions are immutable and are what tenant assignments pin.
+// @Tags planfeatures
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param planId path string true "Plan ID"
+// @Success 200 {array} planfeatures.PlanVersionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/plans/{planId}/versions [get]
+func (h *PlanFeatureHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+	planID, err := uuid.Parse(chi.URLParam(r, "planId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid plan ID format")
+		return
+	}
+
+	res, err := h.service.ListVersions(r.Context(), projectID, planID)
+	if err != nil {
+		utils.Error(w, versionErrorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// GetVersion godoc
+// @Summary Get a plan version
+// @Description Returns the version with its features: for a published version the values frozen at publish time (inheritance already flattened, each with its source plan); for the draft, the current flattened plan features that publishing would freeze.
+// @Tags planfeatures
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param planId path string true "Plan ID"
+// @Param versionId path string true "Version ID"
+// @Success 200 {object} planfeatures.PlanVersionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/plans/{planId}/versions/{versionId} [get]
+func (h *PlanFeatureHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+	planID, err := uuid.Parse(chi.URLParam(r, "planId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid plan ID format")
+		return
+	}
+	versionID, err := uuid.Parse(chi.URLParam(r, "versionId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid version ID format")
+		return
+	}
+
+	res, err := h.service.GetVersion(r.Context(), projectID, planID, versionID)
+	if err != nil {
+		utils.Error(w, versionErrorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// PublishVersion godoc
+// @Summary Publish the plan's draft version
+// @Description Freezes the draft: the plan's features, flattened through the parent chain, become an immutable version. New assignments pin the latest published version; tenants already on the plan keep their version until migrated. Returns 409 if there are no unpublished changes.
+// @Tags planfeatures
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param planId path string true "Plan ID"
+// @Success 200 {object} planfeatures.PlanVersionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/plans/{planId}/versions/publish [post]
+func (h *PlanFeatureHandler) PublishVersion(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+	planID, err := uuid.Parse(chi.URLParam(r, "planId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid plan ID format")
+		return
+	}
+
+	res, err := h.service.PublishVersion(r.Context(), projectID, planID)
+	if err != nil {
+		utils.Error(w, versionErrorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
@@ -1,11 +1,33 @@
 package planfeatures
 
-import "github.com/google/uuid"
+import (
+	"time"
 
-// Create assignment request: feature id and dynamic value
+	"github.com/google/uuid"
+)
+
+// Políticas de cuota de una feature numeric en el plan
+const (
+	EnforcementHard      = "hard"      // se rechaza lo que supera el límite
+	EnforcementSoft      = "soft"      // se permite y se registra el exceso
+	EnforcementUnlimited = "unlimited" // no se aplica límite
+)
+
+// Create assignment request: feature id and dynamic value.
+// enforcement (hard por defecto) y alert_thresholds (porcentajes del límite)
+// solo se admiten en features numeric.
 type AssignFeatureRequest struct {
-	FeatureID uuid.UUID   `json:"feature_id"`
-	Value     interface{} `json:"value"`
+	FeatureID       uuid.UUID   `json:"feature_id"`
+	Value           interface{} `json:"value"`
+	Enforcement     string      `json:"enforcement,omitempty"`
+	AlertThresholds []float64   `json:"alert_thresholds,omitempty"`
+}
+
+// UpdatePlanFeatureRequest: campos nil = sin cambios; alert_thresholds [] los quita
+type UpdatePlanFeatureRequest struct {
+	Value           interface{} `json:"value,omitempty"`
+	Enforcement     *string     `json:"enforcement,omitempty"`
+	AlertThresholds []float64   `json:"alert_thresholds,omitempty"`
 }
 
 // PlanFeatureResponse returned to clients
@@ -15,6 +37,31 @@ type PlanFeatureResponse struct {
 	ProjectID uuid.UUID   `json:"project_id"`
 	FeatureID uuid.UUID   `json:"feature_id"`
 	Value     interface{} `json:"value"`
+	// política de cuota (features numeric)
+	Enforcement     string    `json:"enforcement"`
+	AlertThresholds []float64 `json:"alert_thresholds"`
+	// solo en la vista heredada: plan de la cadena que aporta el valor
+	SourcePlanID   *uuid.UUID `json:"source_plan_id,omitempty"`
+	SourcePlanCode string     `json:"source_plan_code,omitempty"`
+}
+
+// Estados de una versión de plan
+const (
+	VersionDraft     = "draft"     // abierta por la última edición de plan_features
+	VersionPublished = "published" // inmutable; las asignaciones la fijan
+)
+
+// PlanVersionResponse: features solo al pedir una versión concreta (en un
+// draft, la vista aplanada actual de plan_features, lo que se publicaría)
+type PlanVersionResponse struct {
+	ID          uuid.UUID             `json:"id"`
+	ProjectID   uuid.UUID             `json:"project_id"`
+	PlanID      uuid.UUID             `json:"plan_id"`
+	Version     int                   `json:"version"`
+	Status      string                `json:"status"`
+	PublishedAt *time.Time            `json:"published_at,omitempty"`
+	CreatedAt   time.Time             `json:"created_at"`
+	Features    []PlanFeatureResponse `json:"features,omitempty"`
 }
 
 // internal entity
@@ -14,6 +14,18 @@ type PlanFeatureRepository interface {
 	ListByPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanFeatureResponse, error)
 	Create(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, req AssignFeatureRequest) (*PlanFeatureResponse, error)
 	Exists(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID) (bool, error)
+	GetByPlanAndFeature(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID) (*PlanFeatureResponse, error)
+	ListInherited(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanFeatureResponse, error)
+	GetInherited(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID) (*PlanFeatureResponse, error)
+	Update(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID, req UpdatePlanFeatureRequest) (*PlanFeatureResponse, error)
+
+	// Versiones
+	EnsureDraft(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanVersionResponse, error)
+	PublishDraft(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanVersionResponse, error)
+	ListVersions(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanVersionResponse, error)
+	GetVersion(ctx context.Context, projectID uuid.UUID, versionID uuid.UUID) (*PlanVersionResponse, error)
+	ListVersionFeatures(ctx context.Context, projectID uuid.UUID, versionID uuid.UUID) ([]PlanFeatureResponse, error)
+	GetVersionFeature(ctx context.Context, projectID uuid.UUID, versionID uuid.UUID, featureID uuid.UUID) (*PlanFeatureResponse, error)
 }
 
 type planFeatureRepository struct {
@@ -24,9 +36,23 @@ func NewPlanFeatureRepository(db *sql.DB) PlanFeatureRepository {
 	return &planFeatureRepository{db: db}
 }
 
+// decodeValues: value_json y alert_thresholds (JSONB) → campos de la respuesta
+func decodeValues(pf *PlanFeatureResponse, valueJSON []byte, thresholdsJSON []byte) error {
+	if err := json.Unmarshal(valueJSON, &pf.Value); err != nil {
+		return fmt.Errorf("unmarshal value_json: %w", err)
+	}
+	pf.AlertThresholds = []float64{}
+	if len(thresholdsJSON) > 0 {
+		if err := json.Unmarshal(thresholdsJSON, &pf.AlertThresholds); err != nil {
+			return fmt.Errorf("unmarshal alert_thresholds: %w", err)
+		}
+	}
+	return nil
+}
+
 func (r *planFeatureRepository) ListByPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanFeatureResponse, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, project_id, plan_id, feature_id, value_json 
+		`SELECT id, project_id, plan_id, feature_id, value_json, enforcement, alert_thresholds
          FROM plan_features 
          WHERE project_id = $1 AND plan_id = $2 
          ORDER BY id`,
@@ -39,15 +65,15 @@ func (r *planFeatureRepository) ListByPlan(ctx context.Context, projectID uuid.U
 	var results []PlanFeatureResponse
 	for rows.Next() {
 		var pf PlanFeatureResponse
-		var valueJSON []byte
-		err := rows.Scan(&pf.ID, &pf.ProjectID, &pf.PlanID, &pf.FeatureID, &valueJSON)
+		var valueJSON, thresholdsJSON []byte
+		err := rows.Scan(&pf.ID, &pf.ProjectID, &pf.PlanID, &pf.FeatureID, &valueJSON, &pf.Enforcement, &thresholdsJSON)
 		if err != nil {
 			return nil, fmt.Errorf("scan plan feature: %w", err)
 		}
 
 		// JSONB → interface{}
-		if err := json.Unmarshal(valueJSON, &pf.Value); err != nil {
-			return nil, fmt.Errorf("unmarshal value_json: %w", err)
+		if err := decodeValues(&pf, valueJSON, thresholdsJSON); err != nil {
+			return nil, err
 		}
 
 		results = append(results, pf)
@@ -70,6 +96,111 @@ func (r *planFeatureRepository) Exists(ctx context.Context, projectID uuid.UUID,
 	return exists, nil
 }
 
+func (r *planFeatureRepository) GetByPlanAndFeature(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID) (*PlanFeatureResponse, error) {
+	pf := &PlanFeatureResponse{}
+	var valueJSON, thresholdsJSON []byte
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, project_id, plan_id, feature_id, value_json, enforcement, alert_thresholds
+         FROM plan_features
+         WHERE project_id = $1 AND plan_id = $2 AND feature_id = $3`,
+		projectID, planID, featureID).
+		Scan(&pf.ID, &pf.ProjectID, &pf.PlanID, &pf.FeatureID, &valueJSON, &pf.Enforcement, &thresholdsJSON)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("plan feature not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get plan feature: %w", err)
+	}
+
+	// JSONB → interface{}
+	if err := decodeValues(pf, valueJSON, thresholdsJSON); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+// planChainCTE recorre la cadena de herencia del plan $2 (proyecto $1):
+// depth 0 = el propio plan, 1 = su padre... El tope de 32 evita bucles si la
+// BD tuviera un ciclo.
+const planChainCTE = `plan_chain AS (
+             SELECT p.id, p.code, p.parent_plan_id, 0 AS depth
+             FROM plans p
+             WHERE p.project_id = $1 AND p.id = $2
+             UNION ALL
+             SELECT p.id, p.code, p.parent_plan_id, c.depth + 1
+             FROM plan_chain c
+             JOIN plans p ON p.id = c.parent_plan_id AND p.project_id = $1
+             WHERE c.depth < 32
+         )`
+
+// ListInherited aplana la cadena de herencia: una fila por feature con el
+// valor del plan más cercano (el hijo sustituye al padre).
+func (r *planFeatureRepository) ListInherited(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanFeatureResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`WITH RECURSIVE `+planChainCTE+`
+         SELECT DISTINCT ON (pf.feature_id) pf.id, pf.project_id, pf.feature_id, pf.value_json,
+                pf.enforcement, pf.alert_thresholds, c.id, c.code
+         FROM plan_chain c
+         JOIN plan_features pf ON pf.plan_id = c.id AND pf.project_id = $1
+         ORDER BY pf.feature_id, c.depth`,
+		projectID, planID)
+	if err != nil {
+		return nil, fmt.Errorf("list inherited plan features: %w", err)
+	}
+	defer rows.Close()
+
+	var results []PlanFeatureResponse
+	for rows.Next() {
+		pf := PlanFeatureResponse{PlanID: planID}
+		var valueJSON, thresholdsJSON []byte
+		var sourceID uuid.UUID
+		if err := rows.Scan(&pf.ID, &pf.ProjectID, &pf.FeatureID, &valueJSON, &pf.Enforcement, &thresholdsJSON, &sourceID, &pf.SourcePlanCode); err != nil {
+			return nil, fmt.Errorf("scan plan feature: %w", err)
+		}
+		pf.SourcePlanID = &sourceID
+
+		// JSONB → interface{}
+		if err := decodeValues(&pf, valueJSON, thresholdsJSON); err != nil {
+			return nil, err
+		}
+		results = append(results, pf)
+	}
+	return results, rows.Err()
+}
+
+// GetInherited devuelve el valor efectivo de una feature para el plan,
+// buscando en sus ancestros si el plan no la define.
+func (r *planFeatureRepository) GetInherited(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID) (*PlanFeatureResponse, error) {
+	pf := &PlanFeatureResponse{PlanID: planID}
+	var valueJSON, thresholdsJSON []byte
+	var sourceID uuid.UUID
+	err := r.db.QueryRowContext(ctx,
+		`WITH RECURSIVE `+planChainCTE+`
+         SELECT pf.id, pf.project_id, pf.feature_id, pf.value_json, pf.enforcement, pf.alert_thresholds, c.id, c.code
+         FROM plan_chain c
+         JOIN plan_features pf ON pf.plan_id = c.id AND pf.project_id = $1
+         WHERE pf.feature_id = $3
+         ORDER BY c.depth
+         LIMIT 1`,
+		projectID, planID, featureID).
+		Scan(&pf.ID, &pf.ProjectID, &pf.FeatureID, &valueJSON, &pf.Enforcement, &thresholdsJSON, &sourceID, &pf.SourcePlanCode)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("plan feature not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get inherited plan feature: %w", err)
+	}
+	pf.SourcePlanID = &sourceID
+
+	// JSONB → interface{}
+	if err := decodeValues(pf, valueJSON, thresholdsJSON); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
 func (r *planFeatureRepository) Create(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, req AssignFeatureRequest) (*PlanFeatureResponse, error) {
 	// Verificar unique constraint primero
 	exists, err := r.Exists(ctx, projectID, planID, req.FeatureID)
@@ -88,22 +219,288 @@ func (r *planFeatureRepository) Create(ctx context.Context, projectID uuid.UUID,
 		return nil, fmt.Errorf("marshal value: %w", err)
 	}
 
+	thresholdsJSON, err := marshalThresholds(req.AlertThresholds)
+	if err != nil {
+		return nil, err
+	}
+	enforcement := req.Enforcement
+	if enforcement == "" {
+		enforcement = EnforcementHard
+	}
+
 	pf := &PlanFeatureResponse{}
 	err = r.db.QueryRowContext(ctx,
-		`INSERT INTO plan_features (id, project_id, plan_id, feature_id, value_json)
-         VALUES ($1, $2, $3, $4, $5)
-         RETURNING id, project_id, plan_id, feature_id, value_json`,
-		id, projectID, planID, req.FeatureID, valueJSON).
-		Scan(&pf.ID, &pf.ProjectID, &pf.PlanID, &pf.FeatureID, &valueJSON)
+		`INSERT INTO plan_features (id, project_id, plan_id, feature_id, value_json, enforcement, alert_thresholds)
+         VALUES ($1, $2, $3, $4, $5, $6, $7)
+         RETURNING id, project_id, plan_id, feature_id, value_json, enforcement, alert_thresholds`,
+		id, projectID, planID, req.FeatureID, valueJSON, enforcement, thresholdsJSON).
+		Scan(&pf.ID, &pf.ProjectID, &pf.PlanID, &pf.FeatureID, &valueJSON, &pf.Enforcement, &thresholdsJSON)
 
 	if err != nil {
 		return nil, fmt.Errorf("create plan feature: %w", err)
 	}
 
 	// Re-unmarshal para Value interface{}
-	if err := json.Unmarshal(valueJSON, &pf.Value); err != nil {
-		return nil, fmt.Errorf("unmarshal response value: %w", err)
+	if err := decodeValues(pf, valueJSON, thresholdsJSON); err != nil {
+		return nil, err
 	}
 
 	return pf, nil
 }
+
+// Update cambia valor y/o política de la asignación; los campos nil no se tocan
+func (r *planFeatureRepository) Update(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID, req UpdatePlanFeatureRequest) (*PlanFeatureResponse, error) {
+	var valueJSON, thresholdsJSON []byte
+	var err error
+	if req.Value != nil {
+		if valueJSON, err = json.Marshal(req.Value); err != nil {
+			return nil, fmt.Errorf("marshal value: %w", err)
+		}
+	}
+	if req.AlertThresholds != nil {
+		if thresholdsJSON, err = marshalThresholds(req.AlertThresholds); err != nil {
+			return nil, err
+		}
+	}
+
+	pf := &PlanFeatureResponse{}
+	err = r.db.QueryRowContext(ctx,
+		`UPDATE plan_features
+         SET value_json = COALESCE($4, value_json),
+             enforcement = COALESCE($5, enforcement),
+             alert_thresholds = COALESCE($6, alert_thresholds)
+         WHERE project_id = $1 AND plan_id = $2 AND feature_id = $3
+         RETURNING id, project_id, plan_id, feature_id, value_json, enforcement, alert_thresholds`,
+		projectID, planID, featureID, valueJSON, req.Enforcement, thresholdsJSON).
+		Scan(&pf.ID, &pf.ProjectID, &pf.PlanID, &pf.FeatureID, &valueJSON, &pf.Enforcement, &thresholdsJSON)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("plan feature not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update plan feature: %w", err)
+	}
+	if err := decodeValues(pf, valueJSON, thresholdsJSON); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+// marshalThresholds: nil → [] (la columna es NOT NULL)
+func marshalThresholds(thresholds []float64) ([]byte, error) {
+	if thresholds == nil {
+		thresholds = []float64{}
+	}
+	b, err := json.Marshal(thresholds)
+	if err != nil {
+		return nil, fmt.Errorf("marshal alert_thresholds: %w", err)
+	}
+	return b, nil
+}
+
+const planVersionColumns = `id, project_id, plan_id, version, status, published_at, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPlanVersion(row rowScanner) (*PlanVersionResponse, error) {
+	v := &PlanVersionResponse{}
+	err := row.Scan(&v.ID, &v.ProjectID, &v.PlanID, &v.Version, &v.Status, &v.PublishedAt, &v.CreatedAt)
+	return v, err
+}
+
+// EnsureDraft devuelve el borrador del plan, abriéndolo (con el siguiente
+// número de versión) si no había ninguno
+func (r *planFeatureRepository) EnsureDraft(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanVersionResponse, error) {
+	// el índice único parcial deja un solo draft aunque dos ediciones lleguen a la vez
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO plan_versions (id, project_id, plan_id, version)
+         SELECT $1, $2, $3, COALESCE(MAX(version), 0) + 1
+         FROM plan_versions
+         WHERE plan_id = $3
+         ON CONFLICT DO NOTHING`,
+		uuid.New(), projectID, planID)
+	if err != nil {
+		return nil, fmt.Errorf("create draft version: %w", err)
+	}
+
+	v, err := scanPlanVersion(r.db.QueryRowContext(ctx,
+		`SELECT `+planVersionColumns+`
+         FROM plan_versions
+         WHERE project_id = $1 AND plan_id = $2 AND status = 'draft'`,
+		projectID, planID))
+	if errors.Is(err, sql.ErrNoRows) {
+		// otra edición lo publicó entre medias: se abre uno nuevo
+		return r.EnsureDraft(ctx, projectID, planID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get draft version: %w", err)
+	}
+	return v, nil
+}
+
+// PublishDraft congela el borrador: copia en plan_version_features la vista
+// aplanada de plan_features (herencia incluida) y lo marca published. Las
+// asignaciones del plan que aún no fijaban versión (el plan no tenía ninguna
+// publicada) quedan fijadas a esta.
+func (r *planFeatureRepository) PublishDraft(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanVersionResponse, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin publish version: %w", err)
+	}
+	defer tx.Rollback()
+
+	var versionID uuid.UUID
+	err = tx.QueryRowContext(ctx,
+		`SELECT id FROM plan_versions
+         WHERE project_id = $1 AND plan_id = $2 AND status = 'draft'
+         FOR UPDATE`,
+		projectID, planID).Scan(&versionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("no draft version to publish")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get draft version: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`WITH RECURSIVE `+planChainCTE+`
+         INSERT INTO plan_version_features (id, version_id, project_id, feature_id, source_plan_id, value_json, enforcement, alert_thresholds)
+         SELECT DISTINCT ON (pf.feature_id) uuid_generate_v4(), $3, pf.project_id, pf.feature_id, c.id,
+                pf.value_json, pf.enforcement, pf.alert_thresholds
+         FROM plan_chain c
+         JOIN plan_features pf ON pf.plan_id = c.id AND pf.project_id = $1
+         ORDER BY pf.feature_id, c.depth`,
+		projectID, planID, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot plan features: %w", err)
+	}
+
+	v, err := scanPlanVersion(tx.QueryRowContext(ctx,
+		`UPDATE plan_versions
+         SET status = 'published', published_at = NOW()
+         WHERE id = $1
+         RETURNING `+planVersionColumns,
+		versionID))
+	if err != nil {
+		return nil, fmt.Errorf("publish version: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE tenant_plans
+         SET plan_version_id = $2, updated_at = NOW()
+         WHERE plan_id = $1 AND plan_version_id IS NULL`,
+		planID, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("pin unversioned assignments: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit publish version: %w", err)
+	}
+	return v, nil
+}
+
+// ListVersions devuelve las versiones del plan, la más reciente primero
+func (r *planFeatureRepository) ListVersions(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanVersionResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+planVersionColumns+`
+         FROM plan_versions
+         WHERE project_id = $1 AND plan_id = $2
+         ORDER BY version DESC`,
+		projectID, planID)
+	if err != nil {
+		return nil, fmt.Errorf("list plan versions: %w", err)
+	}
+	defer rows.Close()
+
+	results := []PlanVersionResponse{}
+	for rows.Next() {
+		v, err := scanPlanVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan plan version: %w", err)
+		}
+		results = append(results, *v)
+	}
+	return results, rows.Err()
+}
+
+func (r *planFeatureRepository) GetVersion(ctx context.Context, projectID uuid.UUID, versionID uuid.UUID) (*PlanVersionResponse, error) {
+	v, err := scanPlanVersion(r.db.QueryRowContext(ctx,
+		`SELECT `+planVersionColumns+`
+         FROM plan_versions
+         WHERE project_id = $1 AND id = $2`,
+		projectID, versionID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("plan version not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get plan version: %w", err)
+	}
+	return v, nil
+}
+
+// versionFeatureColumns: pvf = plan_version_features, v = plan_versions, p = plan de origen
+const versionFeatureColumns = `pvf.id, pvf.project_id, v.plan_id, pvf.feature_id, pvf.value_json, pvf.enforcement, pvf.alert_thresholds, pvf.source_plan_id, p.code`
+
+func scanVersionFeature(row rowScanner) (*PlanFeatureResponse, error) {
+	pf := &PlanFeatureResponse{}
+	var valueJSON, thresholdsJSON []byte
+	var sourceID uuid.UUID
+	if err := row.Scan(&pf.ID, &pf.ProjectID, &pf.PlanID, &pf.FeatureID, &valueJSON, &pf.Enforcement,
+		&thresholdsJSON, &sourceID, &pf.SourcePlanCode); err != nil {
+		return nil, err
+	}
+	pf.SourcePlanID = &sourceID
+
+	// JSONB → interface{}
+	if err := decodeValues(pf, valueJSON, thresholdsJSON); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+// ListVersionFeatures devuelve las features congeladas de una versión, con el
+// plan de la cadena que aportaba cada valor al publicarla
+func (r *planFeatureRepository) ListVersionFeatures(ctx context.Context, projectID uuid.UUID, versionID uuid.UUID) ([]PlanFeatureResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+versionFeatureColumns+`
+         FROM plan_version_features pvf
+         JOIN plan_versions v ON v.id = pvf.version_id
+         JOIN plans p ON p.id = pvf.source_plan_id
+         WHERE pvf.project_id = $1 AND pvf.version_id = $2
+         ORDER BY pvf.feature_id`,
+		projectID, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("list version features: %w", err)
+	}
+	defer rows.Close()
+
+	var results []PlanFeatureResponse
+	for rows.Next() {
+		pf, err := scanVersionFeature(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan version feature: %w", err)
+		}
+		results = append(results, *pf)
+	}
+	return results, rows.Err()
+}
+
+func (r *planFeatureRepository) GetVersionFeature(ctx context.Context, projectID uuid.UUID, versionID uuid.UUID, featureID uuid.UUID) (*PlanFeatureResponse, error) {
+	pf, err := scanVersionFeature(r.db.QueryRowContext(ctx,
+		`SELECT `+versionFeatureColumns+`
+         FROM plan_version_features pvf
+         JOIN plan_versions v ON v.id = pvf.version_id
+         JOIN plans p ON p.id = pvf.source_plan_id
+         WHERE pvf.project_id = $1 AND pvf.version_id = $2 AND pvf.feature_id = $3`,
+		projectID, versionID, featureID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("plan feature not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get version feature: %w", err)
+	}
+	return pf, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 
 	"plans-features/internal/domain/features"
 	"plans-features/internal/domain/plans"
@@ -13,8 +15,13 @@ import (
 )
 
 type PlanFeatureService interface {
-	ListByPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanFeatureResponse, error)
+	ListByPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, inherited bool) ([]PlanFeatureResponse, error)
 	AssignFeature(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, req AssignFeatureRequest) (*PlanFeatureResponse, error)
+	UpdateFeature(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID, req UpdatePlanFeatureRequest) (*PlanFeatureResponse, error)
+
+	ListVersions(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanVersionResponse, error)
+	GetVersion(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, versionID uuid.UUID) (*PlanVersionResponse, error)
+	PublishVersion(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanVersionResponse, error)
 }
 
 type planFeatureService struct {
@@ -33,7 +40,12 @@ func NewPlanFeatureService(repo PlanFeatureRepository, planRepo plans.PlanReposi
 	}
 }
 
-func (s *planFeatureService) ListByPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanFeatureResponse, error) {
+// ListByPlan devuelve las features propias del plan o, con inherited, la vista
+// aplanada de toda la cadena de herencia con el plan de origen de cada valor.
+func (s *planFeatureService) ListByPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, inherited bool) ([]PlanFeatureResponse, error) {
+	if inherited {
+		return s.repo.ListInherited(ctx, projectID, planID)
+	}
 	return s.repo.ListByPlan(ctx, projectID, planID)
 }
 
@@ -62,27 +74,17 @@ func (s *planFeatureService) AssignFeature(ctx context.Context, projectID uuid.U
 	}
 
 	// 4. Validate value type based on feature.Type
-	switch feature.Type {
-	case "flag":
-		if _, ok := req.Value.(bool); !ok {
-			return nil, errors.New("value must be boolean for flag feature")
-		}
-	case "numeric":
-		switch req.Value.(type) {
-		case float64, float32, int, int64, int32:
-			// ok
-		default:
-			return nil, errors.New("value must be numeric for numeric feature")
-		}
-	case "value":
-		if _, ok := req.Value.(string); !ok {
-			return nil, errors.New("value must be string for value feature")
-		}
-	default:
-		return nil, errors.New("invalid feature type")
+	if err := features.ValidateValue(feature.Type, req.Value); err != nil {
+		return nil, err
 	}
 
-	// 5. Ensure not duplicate in plan (repo ya valida UNIQUE constraint)
+	// 5. Quota policy only makes sense for numeric features
+	if err := validatePolicy(feature.Type, req.Enforcement, req.AlertThresholds); err != nil {
+		return nil, err
+	}
+	req.AlertThresholds = normalizeThresholds(req.AlertThresholds)
+
+	// 6. Ensure not duplicate in plan (repo ya valida UNIQUE constraint)
 	exists, err := s.repo.Exists(ctx, projectID, planID, req.FeatureID)
 	if err != nil {
 		return nil, fmt.Errorf("check exists: %w", err)
@@ -91,6 +93,122 @@ func (s *planFeatureService) AssignFeature(ctx context.Context, projectID uuid.U
 		return nil, errors.New("feature already assigned to plan")
 	}
 
-	// 6. Create assignment
-	return s.repo.Create(ctx, projectID, planID, req)
+	// 7. Create assignment
+	pf, err := s.repo.Create(ctx, projectID, planID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 8. The change stays in the draft version until it is published
+	if _, err := s.repo.EnsureDraft(ctx, projectID, planID); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+func (s *planFeatureService) UpdateFeature(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, featureID uuid.UUID, req UpdatePlanFeatureRequest) (*PlanFeatureResponse, error) {
+	feature, err := s.featureRepo.GetByID(ctx, projectID, featureID)
+	if err != nil {
+		return nil, errors.New("feature not found")
+	}
+	if req.Value != nil {
+		if err := features.ValidateValue(feature.Type, req.Value); err != nil {
+			return nil, err
+		}
+	}
+	enforcement := ""
+	if req.Enforcement != nil {
+		enforcement = *req.Enforcement
+	}
+	if enforcement == "" {
+		req.Enforcement = nil
+	}
+	if err := validatePolicy(feature.Type, enforcement, req.AlertThresholds); err != nil {
+		return nil, err
+	}
+	if req.AlertThresholds != nil {
+		req.AlertThresholds = normalizeThresholds(req.AlertThresholds)
+	}
+	pf, err := s.repo.Update(ctx, projectID, planID, featureID, req)
+	if err != nil {
+		return nil, err
+	}
+	// el cambio queda en el borrador hasta que se publique
+	if _, err := s.repo.EnsureDraft(ctx, projectID, planID); err != nil {
+		return nil, err
+	}
+	return pf, nil
+}
+
+func (s *planFeatureService) ListVersions(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]PlanVersionResponse, error) {
+	if _, err := s.planRepo.GetByID(ctx, projectID, planID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListVersions(ctx, projectID, planID)
+}
+
+// GetVersion devuelve la versión con sus features: las congeladas si está
+// publicada o, en el borrador, la vista aplanada actual de plan_features
+func (s *planFeatureService) GetVersion(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, versionID uuid.UUID) (*PlanVersionResponse, error) {
+	v, err := s.repo.GetVersion(ctx, projectID, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if v.PlanID != planID {
+		return nil, errors.New("plan version not found")
+	}
+	if v.Status == VersionPublished {
+		v.Features, err = s.repo.ListVersionFeatures(ctx, projectID, versionID)
+	} else {
+		v.Features, err = s.repo.ListInherited(ctx, projectID, planID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// PublishVersion publica el borrador del plan. Las asignaciones nuevas fijan
+// esta versión; las existentes siguen en la suya hasta que se migren.
+func (s *planFeatureService) PublishVersion(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanVersionResponse, error) {
+	if _, err := s.planRepo.GetByID(ctx, projectID, planID); err != nil {
+		return nil, err
+	}
+	return s.repo.PublishDraft(ctx, projectID, planID)
+}
+
+// validatePolicy: enforcement vacío = sin cambio / hard; los umbrales son
+// porcentajes del límite mayores que 0
+func validatePolicy(featureType string, enforcement string, thresholds []float64) error {
+	switch enforcement {
+	case "", EnforcementHard, EnforcementSoft, EnforcementUnlimited:
+	default:
+		return fmt.Errorf("invalid enforcement %q", enforcement)
+	}
+	if featureType != "numeric" && (enforcement != "" || len(thresholds) > 0) {
+		return errors.New("enforcement and alert_thresholds only apply to numeric features")
+	}
+	for _, t := range thresholds {
+		if t <= 0 || math.IsNaN(t) || math.IsInf(t, 0) {
+			return errors.New("alert_thresholds must be percentages greater than 0")
+		}
+	}
+	return nil
+}
+
+// normalizeThresholds ordena y quita duplicados
+func normalizeThresholds(thresholds []float64) []float64 {
+	if thresholds == nil {
+		return nil
+	}
+	out := append([]float64{}, thresholds...)
+	sort.Float64s(out)
+	n := 0
+	for i, t := range out {
+		if i == 0 || t != out[n-1] {
+			out[n] = t
+			n++
+		}
+	}
+	return out[:n]
 }
@@ -3,7 +3,9 @@ package plans
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"plans-features/internal/auth"
 	"plans-features/internal/utils"
 
 	"github.com/go-chi/chi/v5"
@@ -18,29 +20,61 @@ func NewPlanHandler(service PlanService) *PlanHandler {
 	return &PlanHandler{service: service}
 }
 
+// isInheritanceError: errores de validación de parent_plan_id o credit_overdraft (400)
+func isInheritanceError(err error) bool {
+	msg := err.Error()
+	return msg == "parent plan not found" ||
+		msg == "credit_overdraft must be >= 0" ||
+		msg == "plan cannot extend itself" ||
+		msg == "plan inheritance cycle detected" ||
+		strings.HasPrefix(msg, "plan inheritance exceeds max depth")
+}
+
+// statusErrorCode traduce los errores del ciclo de vida del plan (0 = no lo es)
+func statusErrorCode(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "status must"),
+		strings.HasPrefix(msg, "invalid status transition"),
+		msg == "default plan must be active":
+		return http.StatusBadRequest
+	case strings.HasPrefix(msg, "plan still has"),
+		strings.HasPrefix(msg, "plan code already in use"):
+		return http.StatusConflict
+	}
+	return 0
+}
+
+// includeDrafts: los drafts solo se ven desde las rutas admin
+func includeDrafts(r *http.Request) bool {
+	_, ok := auth.AdminIDFromContext(r.Context())
+	return ok
+}
+
 // ListPlans godoc
 // @Summary List plans for a project
-// @Description List available plans for the project identified by the API key
+// @Description List the plans of the project identified by the API key: active, legacy (still valid for their tenants, not assignable) and retired. Draft plans are only listed by the admin routes
 // @Tags plans
 // @Produce json
 // @Param X-API-Key header string true "API Key"
+// @Param status query string false "Only plans in this status (draft, active, legacy, retired)"
 // @Success 200 {array} plans.PlanResponse
+// @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/plans [get]
 func (h *PlanHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusUnauthorized, "invalid project ID")
-		return
-	}
-	ps, err := h.service.ListPlans(r.Context(), projectID)
+	ps, err := h.service.ListPlans(r.Context(), projectID, r.URL.Query().Get("status"), includeDrafts(r))
 	if err != nil {
+		if code := statusErrorCode(err); code != 0 {
+			utils.Error(w, code, err.Error())
+			return
+		}
 		utils.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -49,7 +83,7 @@ func (h *PlanHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
 
 // CreatePlan godoc
 // @Summary Create a plan for a project
-// @Description Create a new plan for the project identified by the API key
+// @Description Create a new plan for the project identified by the API key. parent_plan_id makes it extend another plan of the same project (max depth 5, no cycles). status is active (default) or draft; a draft is hidden from /api and cannot be assigned or be the default plan.
 // @Tags plans
 // @Accept json
 // @Produce json
@@ -61,18 +95,12 @@ func (h *PlanHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string
 // @Router /api/plans [post]
 func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
 
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusUnauthorized, "invalid project ID")
-		return
-	}
-
 	var req CreatePlanRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.Error(w, http.StatusBadRequest, "invalid body")
@@ -84,6 +112,14 @@ func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
 	}
 	p, err := h.service.CreatePlan(r.Context(), projectID, req)
 	if err != nil {
+		if code := statusErrorCode(err); code != 0 {
+			utils.Error(w, code, err.Error())
+			return
+		}
+		if isInheritanceError(err) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -92,7 +128,7 @@ func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
 
 // GetPlan godoc
 // @Summary Get a plan by ID
-// @Description Retrieve a plan for the project identified by the API key
+// @Description Retrieve a plan for the project identified by the API key (draft plans only through the admin routes)
 // @Tags plans
 // @Produce json
 // @Param X-API-Key header string true "API Key"
@@ -103,16 +139,11 @@ func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} map[string]string
 // @Router /api/plans/{planId} [get]
 func (h *PlanHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusUnauthorized, "invalid project ID")
-		return
-	}
 
 	planIDStr := chi.URLParam(r, "planId")
 	planID, err := uuid.Parse(planIDStr)
@@ -121,9 +152,9 @@ func (h *PlanHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, err := h.service.GetPlan(r.Context(), projectID, planID)
+	p, err := h.service.GetPlan(r.Context(), projectID, planID, includeDrafts(r))
 	if err != nil {
-		if err.Error() == "not found" {
+		if err.Error() == "not found" || err.Error() == "plan not found" {
 			utils.Error(w, http.StatusNotFound, "not found")
 			return
 		}
@@ -135,7 +166,7 @@ func (h *PlanHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
 
 // UpdatePlan godoc
 // @Summary Update a plan
-// @Description Update fields of a plan for the project identified by the API key
+// @Description Update fields of a plan for the project identified by the API key. parent_plan_id changes the base plan (validated against cycles and max depth); clear_parent removes it. status moves the plan through its lifecycle: draft → active or retired, active → legacy or retired, legacy → active or retired; retired is final and is rejected with 409 while tenants are still assigned to the plan, have it as trial fallback or have a pending change to it. The default plan must stay active.
 // @Tags plans
 // @Accept json
 // @Produce json
@@ -146,19 +177,15 @@ func (h *PlanHandler) GetPlan(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/plans/{planId} [put]
 func (h *PlanHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusUnauthorized, "invalid project ID")
-		return
-	}
 
 	planIDStr := chi.URLParam(r, "planId")
 	planID, err := uuid.Parse(planIDStr)
@@ -172,12 +199,31 @@ func (h *PlanHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusBadRequest, "invalid body")
 		return
 	}
+	// un draft no existe para /api
+	if !includeDrafts(r) {
+		if _, err := h.service.GetPlan(r.Context(), projectID, planID, false); err != nil {
+			if err.Error() == "plan not found" {
+				utils.Error(w, http.StatusNotFound, "not found")
+				return
+			}
+			utils.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
 	p, err := h.service.UpdatePlan(r.Context(), projectID, planID, req)
 	if err != nil {
-		if err.Error() == "not found" {
+		if err.Error() == "not found" || err.Error() == "plan not found" {
 			utils.Error(w, http.StatusNotFound, "not found")
 			return
 		}
+		if code := statusErrorCode(err); code != 0 {
+			utils.Error(w, code, err.Error())
+			return
+		}
+		if isInheritanceError(err) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		utils.Error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
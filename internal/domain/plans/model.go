@@ -6,57 +6,81 @@ import (
 	"github.com/google/uuid"
 )
 
+// Estados del ciclo de vida de un plan
+const (
+	StatusDraft   = "draft"   // en preparación: invisible para /api y no asignable
+	StatusActive  = "active"  // visible y asignable
+	StatusLegacy  = "legacy"  // vale para sus tenants, sin asignaciones nuevas
+	StatusRetired = "retired" // sin tenants
+)
+
 // Para DB (Scan)
 type Plan struct {
-	ID          uuid.UUID              `db:"id"`
-	ProjectID   uuid.UUID              `db:"project_id"`
-	Code        string                 `db:"code"`
-	Name        string                 `db:"name"`
-	Description *string                `db:"description"`
-	IsActive    bool                   `db:"is_active"`
-	IsDefault   bool                   `db:"is_default"`
-	Limits      map[string]interface{} `db:"limits"`
-	CreatedAt   time.Time              `db:"created_at"`
-	UpdatedAt   time.Time              `db:"updated_at"`
+	ID           uuid.UUID              `db:"id"`
+	ProjectID    uuid.UUID              `db:"project_id"`
+	Code         string                 `db:"code"`
+	Name         string                 `db:"name"`
+	Description  *string                `db:"description"`
+	Status       string                 `db:"status"`
+	IsDefault    bool                   `db:"is_default"`
+	Limits       map[string]interface{} `db:"limits"`
+	ParentPlanID *uuid.UUID             `db:"parent_plan_id"`
+	// CreditOverdraft: cuánto puede quedar en negativo el saldo de créditos
+	CreditOverdraft float64   `db:"credit_overdraft"`
+	CreatedAt       time.Time `db:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at"`
 }
 
 type CreatePlanRequest struct {
-	Code        string                 `json:"code"`
-	Name        string                 `json:"name"`
-	Description string                 `json:"description,omitempty"`
-	IsActive    bool                   `json:"is_active"`
-	IsDefault   bool                   `json:"is_default"`
-	Limits      map[string]interface{} `json:"limits,omitempty"`
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// draft o active (por defecto)
+	Status    string                 `json:"status,omitempty"`
+	IsDefault bool                   `json:"is_default"`
+	Limits    map[string]interface{} `json:"limits,omitempty"`
+	// plan base del mismo proyecto del que se heredan las features
+	ParentPlanID *uuid.UUID `json:"parent_plan_id,omitempty"`
+	// 0 = el saldo de créditos nunca puede quedar en negativo
+	CreditOverdraft float64 `json:"credit_overdraft,omitempty"`
 }
 
 type UpdatePlanRequest struct {
-	Name        *string                `json:"name,omitempty"`
-	Description *string                `json:"description,omitempty"`
-	IsActive    *bool                  `json:"is_active,omitempty"`
-	IsDefault   *bool                  `json:"is_default,omitempty"`
-	Limits      map[string]interface{} `json:"limits,omitempty"`
+	Name         *string                `json:"name,omitempty"`
+	Description  *string                `json:"description,omitempty"`
+	Status       *string                `json:"status,omitempty"`
+	IsDefault    *bool                  `json:"is_default,omitempty"`
+	Limits       map[string]interface{} `json:"limits,omitempty"`
+	ParentPlanID *uuid.UUID             `json:"parent_plan_id,omitempty"`
+	// ClearParent quita la herencia (parent_plan_id = NULL)
+	ClearParent     bool     `json:"clear_parent,omitempty"`
+	CreditOverdraft *float64 `json:"credit_overdraft,omitempty"`
 }
 
 type PlanResponse struct {
-	ID          uuid.UUID              `json:"id"`
-	ProjectID   uuid.UUID              `json:"project_id"`
-	Code        string                 `json:"code"`
-	Name        string                 `json:"name"`
-	Description string                 `json:"description"`
-	IsActive    bool                   `json:"is_active"`
-	IsDefault   bool                   `json:"is_default"`
-	Limits      map[string]interface{} `json:"limits"`
+	ID              uuid.UUID              `json:"id"`
+	ProjectID       uuid.UUID              `json:"project_id"`
+	Code            string                 `json:"code"`
+	Name            string                 `json:"name"`
+	Description     string                 `json:"description"`
+	Status          string                 `json:"status"`
+	IsDefault       bool                   `json:"is_default"`
+	Limits          map[string]interface{} `json:"limits"`
+	ParentPlanID    *uuid.UUID             `json:"parent_plan_id,omitempty"`
+	CreditOverdraft float64                `json:"credit_overdraft"`
 }
 
 func ToResponse(plan *Plan) *PlanResponse {
 	resp := &PlanResponse{
-		ID:        plan.ID,
-		ProjectID: plan.ProjectID,
-		Code:      plan.Code,
-		Name:      plan.Name,
-		IsActive:  plan.IsActive,
-		IsDefault: plan.IsDefault,
-		Limits:    plan.Limits,
+		ID:              plan.ID,
+		ProjectID:       plan.ProjectID,
+		Code:            plan.Code,
+		Name:            plan.Name,
+		Status:          plan.Status,
+		IsDefault:       plan.IsDefault,
+		Limits:          plan.Limits,
+		ParentPlanID:    plan.ParentPlanID,
+		CreditOverdraft: plan.CreditOverdraft,
 	}
 	if plan.Description != nil {
 		resp.Description = *plan.Description
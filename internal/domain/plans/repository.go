@@ -12,10 +12,13 @@ import (
 )
 
 type PlanRepository interface {
-	List(ctx context.Context, projectID uuid.UUID) ([]PlanResponse, error)
+	List(ctx context.Context, projectID uuid.UUID, statuses []string) ([]PlanResponse, error)
 	Create(ctx context.Context, projectID uuid.UUID, req CreatePlanRequest) (*PlanResponse, error)
 	GetByID(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanResponse, error)
 	Update(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, req UpdatePlanRequest) (*PlanResponse, error)
+	Ancestors(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]uuid.UUID, error)
+	SubtreeDepth(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (int, error)
+	CountTenants(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (int, error)
 }
 
 type planRepository struct {
@@ -30,13 +33,18 @@ func normalizeCode(code string) string {
 	return strings.ToLower(strings.TrimSpace(code))
 }
 
-func (r *planRepository) List(ctx context.Context, projectID uuid.UUID) ([]PlanResponse, error) {
+// List devuelve los planes del proyecto con alguno de los estados indicados
+// (vacío = todos)
+func (r *planRepository) List(ctx context.Context, projectID uuid.UUID, statuses []string) ([]PlanResponse, error) {
+	if statuses == nil {
+		statuses = []string{}
+	}
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, project_id, code, name, description, is_active, is_default, limits_json, created_at, updated_at
+		`SELECT id, project_id, code, name, description, status, is_default, limits_json, parent_plan_id, credit_overdraft, created_at, updated_at
          FROM plans 
-         WHERE project_id = $1 AND is_active = true 
+         WHERE project_id = $1 AND (cardinality($2::text[]) = 0 OR status = ANY($2::text[]))
          ORDER BY is_default DESC, created_at DESC`,
-		projectID)
+		projectID, statuses)
 	if err != nil {
 		return nil, fmt.Errorf("list plans: %w", err)
 	}
@@ -48,8 +56,8 @@ func (r *planRepository) List(ctx context.Context, projectID uuid.UUID) ([]PlanR
 		var desc sql.NullString
 		var limitsJSON []byte
 		if err := rows.Scan(&plan.ID, &plan.ProjectID, &plan.Code, &plan.Name,
-			&desc, &plan.IsActive, &plan.IsDefault, &limitsJSON,
-			&plan.CreatedAt, &plan.UpdatedAt); err != nil {
+			&desc, &plan.Status, &plan.IsDefault, &limitsJSON,
+			&plan.ParentPlanID, &plan.CreditOverdraft, &plan.CreatedAt, &plan.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scan plan: %w", err)
 		}
 
@@ -77,13 +85,13 @@ func (r *planRepository) Create(ctx context.Context, projectID uuid.UUID, req Cr
 
 	plan := &Plan{}
 	err = r.db.QueryRowContext(ctx,
-		`INSERT INTO plans (id, project_id, code, name, description, is_active, is_default, limits_json)
-         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-         RETURNING id, project_id, code, name, description, is_active, is_default, limits_json, created_at, updated_at`,
+		`INSERT INTO plans (id, project_id, code, name, description, status, is_default, limits_json, parent_plan_id, credit_overdraft)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+         RETURNING id, project_id, code, name, description, status, is_default, limits_json, parent_plan_id, credit_overdraft, created_at, updated_at`,
 		id, projectID, normalizeCode(req.Code), req.Name, description,
-		req.IsActive, req.IsDefault, limitsJSON).
+		req.Status, req.IsDefault, limitsJSON, req.ParentPlanID, req.CreditOverdraft).
 		Scan(&plan.ID, &plan.ProjectID, &plan.Code, &plan.Name, &plan.Description,
-			&plan.IsActive, &plan.IsDefault, &limitsJSON, &plan.CreatedAt, &plan.UpdatedAt)
+			&plan.Status, &plan.IsDefault, &limitsJSON, &plan.ParentPlanID, &plan.CreditOverdraft, &plan.CreatedAt, &plan.UpdatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("create plan: %w", err)
@@ -102,13 +110,13 @@ func (r *planRepository) GetByID(ctx context.Context, projectID uuid.UUID, planI
 	var limitsJSON []byte
 
 	err := r.db.QueryRowContext(ctx,
-		`SELECT id, project_id, code, name, description, is_active, is_default, limits_json, created_at, updated_at
+		`SELECT id, project_id, code, name, description, status, is_default, limits_json, parent_plan_id, credit_overdraft, created_at, updated_at
          FROM plans 
          WHERE project_id = $1 AND id = $2`,
 		projectID, planID).
 		Scan(&plan.ID, &plan.ProjectID, &plan.Code, &plan.Name,
-			&desc, &plan.IsActive, &plan.IsDefault, &limitsJSON,
-			&plan.CreatedAt, &plan.UpdatedAt)
+			&desc, &plan.Status, &plan.IsDefault, &limitsJSON,
+			&plan.ParentPlanID, &plan.CreditOverdraft, &plan.CreatedAt, &plan.UpdatedAt)
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.New("plan not found")
@@ -140,9 +148,9 @@ func (r *planRepository) Update(ctx context.Context, projectID uuid.UUID, planID
 		args = append(args, req.Description)
 		argIdx++
 	}
-	if req.IsActive != nil {
-		updates = append(updates, fmt.Sprintf("is_active = $%d", argIdx))
-		args = append(args, *req.IsActive)
+	if req.Status != nil {
+		updates = append(updates, fmt.Sprintf("status = $%d", argIdx))
+		args = append(args, *req.Status)
 		argIdx++
 	}
 	if req.IsDefault != nil {
@@ -159,6 +167,18 @@ func (r *planRepository) Update(ctx context.Context, projectID uuid.UUID, planID
 		args = append(args, limitsJSON)
 		argIdx++
 	}
+	if req.CreditOverdraft != nil {
+		updates = append(updates, fmt.Sprintf("credit_overdraft = $%d", argIdx))
+		args = append(args, *req.CreditOverdraft)
+		argIdx++
+	}
+	if req.ClearParent {
+		updates = append(updates, "parent_plan_id = NULL")
+	} else if req.ParentPlanID != nil {
+		updates = append(updates, fmt.Sprintf("parent_plan_id = $%d", argIdx))
+		args = append(args, *req.ParentPlanID)
+		argIdx++
+	}
 
 	if len(updates) == 0 {
 		return r.GetByID(ctx, projectID, planID)
@@ -171,7 +191,7 @@ func (r *planRepository) Update(ctx context.Context, projectID uuid.UUID, planID
 		`UPDATE plans 
          SET %s, updated_at = NOW()
          WHERE project_id = $%d AND %s
-         RETURNING id, project_id, code, name, description, is_active, is_default, limits_json, created_at, updated_at`,
+         RETURNING id, project_id, code, name, description, status, is_default, limits_json, parent_plan_id, credit_overdraft, created_at, updated_at`,
 		strings.Join(updates[:len(updates)-1], ", "),
 		argIdx, updates[len(updates)-1])
 
@@ -180,8 +200,8 @@ func (r *planRepository) Update(ctx context.Context, projectID uuid.UUID, planID
 	var limitsJSON []byte
 	err := r.db.QueryRowContext(ctx, query, args...).Scan(
 		&plan.ID, &plan.ProjectID, &plan.Code, &plan.Name,
-		&desc, &plan.IsActive, &plan.IsDefault, &limitsJSON,
-		&plan.CreatedAt, &plan.UpdatedAt)
+		&desc, &plan.Status, &plan.IsDefault, &limitsJSON,
+		&plan.ParentPlanID, &plan.CreditOverdraft, &plan.CreatedAt, &plan.UpdatedAt)
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.New("plan not found")
@@ -198,6 +218,90 @@ func (r *planRepository) Update(ctx context.Context, projectID uuid.UUID, planID
 	return ToResponse(plan), nil
 }
 
+// maxChainWalk acota las consultas recursivas aunque la BD tuviera un ciclo
+// (el servicio los impide, pero un UPDATE manual no)
+const maxChainWalk = 32
+
+// Ancestors devuelve la cadena de herencia empezando por el propio plan:
+// [plan, padre, abuelo, ...]
+func (r *planRepository) Ancestors(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`WITH RECURSIVE chain AS (
+             SELECT id, parent_plan_id, 0 AS depth
+             FROM plans
+             WHERE project_id = $1 AND id = $2
+             UNION ALL
+             SELECT p.id, p.parent_plan_id, c.depth + 1
+             FROM chain c
+             JOIN plans p ON p.id = c.parent_plan_id AND p.project_id = $1
+             WHERE c.depth < $3
+         )
+         SELECT id FROM chain ORDER BY depth`,
+		projectID, planID, maxChainWalk)
+	if err != nil {
+		return nil, fmt.Errorf("list plan ancestors: %w", err)
+	}
+	defer rows.Close()
+
+	var chain []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan plan ancestor: %w", err)
+		}
+		chain = append(chain, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list plan ancestors: %w", err)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("plan not found")
+	}
+	return chain, nil
+}
+
+// SubtreeDepth devuelve cuántos niveles de planes hijos cuelgan de planID
+// (0 = ningún plan lo extiende)
+func (r *planRepository) SubtreeDepth(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (int, error) {
+	var depth int
+	err := r.db.QueryRowContext(ctx,
+		`WITH RECURSIVE subtree AS (
+             SELECT id, 0 AS depth
+             FROM plans
+             WHERE project_id = $1 AND id = $2
+             UNION ALL
+             SELECT p.id, s.depth + 1
+             FROM subtree s
+             JOIN plans p ON p.parent_plan_id = s.id AND p.project_id = $1
+             WHERE s.depth < $3
+         )
+         SELECT COALESCE(MAX(depth), 0) FROM subtree`,
+		projectID, planID, maxChainWalk).Scan(&depth)
+	if err != nil {
+		return 0, fmt.Errorf("plan subtree depth: %w", err)
+	}
+	return depth, nil
+}
+
+// CountTenants cuenta los tenants que aún dependen de planID: asignados a él,
+// con él como fallback de un trial o con un cambio pendiente hacia él
+func (r *planRepository) CountTenants(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(DISTINCT tenant_id) FROM (
+             SELECT tenant_id FROM tenant_plans
+             WHERE project_id = $1 AND (plan_id = $2 OR fallback_plan_id = $2)
+             UNION ALL
+             SELECT tenant_id FROM scheduled_plan_changes
+             WHERE project_id = $1 AND plan_id = $2 AND status = 'pending'
+         ) t`,
+		projectID, planID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count plan tenants: %w", err)
+	}
+	return n, nil
+}
+
 // Helpers
 func nullStringToPtr(ns sql.NullString) *string {
 	if ns.Valid && ns.String != "" {
@@ -3,6 +3,7 @@ package plans
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"plans-features/internal/domain/projects"
 
@@ -11,12 +12,32 @@ import (
 
 // PlanService defines business operations for plans
 type PlanService interface {
-	ListPlans(ctx context.Context, projectID uuid.UUID) ([]PlanResponse, error)
+	ListPlans(ctx context.Context, projectID uuid.UUID, status string, includeDrafts bool) ([]PlanResponse, error)
 	CreatePlan(ctx context.Context, projectID uuid.UUID, req CreatePlanRequest) (*PlanResponse, error)
-	GetPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanResponse, error)
+	GetPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, includeDrafts bool) (*PlanResponse, error)
 	UpdatePlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, req UpdatePlanRequest) (*PlanResponse, error)
 }
 
+// MaxPlanDepth es el número máximo de planes en una cadena de herencia,
+// contando el propio plan (plan → padre → abuelo ...)
+const MaxPlanDepth = 5
+
+// statusTransitions: estados a los que se puede pasar desde cada uno. retired
+// es final.
+var statusTransitions = map[string][]string{
+	StatusDraft:  {StatusActive, StatusRetired},
+	StatusActive: {StatusLegacy, StatusRetired},
+	StatusLegacy: {StatusActive, StatusRetired},
+}
+
+func validStatus(status string) bool {
+	switch status {
+	case StatusDraft, StatusActive, StatusLegacy, StatusRetired:
+		return true
+	}
+	return false
+}
+
 type planService struct {
 	repo        PlanRepository
 	projectRepo projects.ProjectRepository
@@ -26,8 +47,21 @@ func NewPlanService(repo PlanRepository, projectRepo projects.ProjectRepository)
 	return &planService{repo: repo, projectRepo: projectRepo}
 }
 
-func (s *planService) ListPlans(ctx context.Context, projectID uuid.UUID) ([]PlanResponse, error) {
-	return s.repo.List(ctx, projectID)
+// ListPlans lista los planes del proyecto, opcionalmente de un solo estado.
+// Sin includeDrafts (llamadas de /api) los drafts no aparecen.
+func (s *planService) ListPlans(ctx context.Context, projectID uuid.UUID, status string, includeDrafts bool) ([]PlanResponse, error) {
+	var statuses []string
+	switch {
+	case status != "" && !validStatus(status):
+		return nil, errors.New("status must be one of draft, active, legacy, retired")
+	case status == StatusDraft && !includeDrafts:
+		return []PlanResponse{}, nil
+	case status != "":
+		statuses = []string{status}
+	case !includeDrafts:
+		statuses = []string{StatusActive, StatusLegacy, StatusRetired}
+	}
+	return s.repo.List(ctx, projectID, statuses)
 }
 
 func (s *planService) CreatePlan(ctx context.Context, projectID uuid.UUID, req CreatePlanRequest) (*PlanResponse, error) {
@@ -39,20 +73,39 @@ func (s *planService) CreatePlan(ctx context.Context, projectID uuid.UUID, req C
 	if req.Name == "" {
 		return nil, errors.New("name is required")
 	}
-	// code unique within project
-	plans, err := s.repo.List(ctx, projectID)
+	if req.CreditOverdraft < 0 {
+		return nil, errors.New("credit_overdraft must be >= 0")
+	}
+	// a plan starts as draft or active (default)
+	if req.Status == "" {
+		req.Status = StatusActive
+	}
+	if req.Status != StatusDraft && req.Status != StatusActive {
+		return nil, errors.New("status must be draft or active")
+	}
+	// code unique within project among draft and active plans
+	plans, err := s.repo.List(ctx, projectID, nil)
 	if err != nil {
 		return nil, err
 	}
 	for _, p := range plans {
-		if p.Code == normalizeCode(req.Code) {
+		if p.Code == normalizeCode(req.Code) && (p.Status == StatusDraft || p.Status == StatusActive) {
 			return nil, errors.New("plan code already exists")
 		}
 	}
+	// parent must be in the project and keep the chain within MaxPlanDepth
+	if req.ParentPlanID != nil {
+		if err := s.validateParent(ctx, projectID, uuid.Nil, *req.ParentPlanID); err != nil {
+			return nil, err
+		}
+	}
 	// if first plan for project and IsDefault == false, force it to true
-	if len(plans) == 0 && !req.IsDefault {
+	if len(plans) == 0 && !req.IsDefault && req.Status == StatusActive {
 		req.IsDefault = true
 	}
+	if req.IsDefault && req.Status != StatusActive {
+		return nil, errors.New("default plan must be active")
+	}
 	// if IsDefault true, unset others
 	if req.IsDefault {
 		for _, p := range plans {
@@ -65,8 +118,16 @@ func (s *planService) CreatePlan(ctx context.Context, projectID uuid.UUID, req C
 	return s.repo.Create(ctx, projectID, req)
 }
 
-func (s *planService) GetPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID) (*PlanResponse, error) {
-	return s.repo.GetByID(ctx, projectID, planID)
+// GetPlan devuelve el plan; sin includeDrafts un draft no existe
+func (s *planService) GetPlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, includeDrafts bool) (*PlanResponse, error) {
+	p, err := s.repo.GetByID(ctx, projectID, planID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == StatusDraft && !includeDrafts {
+		return nil, errors.New("plan not found")
+	}
+	return p, nil
 }
 
 func (s *planService) UpdatePlan(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, req UpdatePlanRequest) (*PlanResponse, error) {
@@ -74,9 +135,35 @@ func (s *planService) UpdatePlan(ctx context.Context, projectID uuid.UUID, planI
 	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
 		return nil, errors.New("project not found")
 	}
+	if req.CreditOverdraft != nil && *req.CreditOverdraft < 0 {
+		return nil, errors.New("credit_overdraft must be >= 0")
+	}
+	if req.ParentPlanID != nil && !req.ClearParent {
+		if err := s.validateParent(ctx, projectID, planID, *req.ParentPlanID); err != nil {
+			return nil, err
+		}
+	}
+	current, err := s.repo.GetByID(ctx, projectID, planID)
+	if err != nil {
+		return nil, err
+	}
+	status := current.Status
+	if req.Status != nil && *req.Status != current.Status {
+		if err := s.validateTransition(ctx, projectID, current, *req.Status); err != nil {
+			return nil, err
+		}
+		status = *req.Status
+	}
+	isDefault := current.IsDefault
+	if req.IsDefault != nil {
+		isDefault = *req.IsDefault
+	}
+	if isDefault && status != StatusActive {
+		return nil, errors.New("default plan must be active")
+	}
 	// If IsDefault true, unset others
 	if req.IsDefault != nil && *req.IsDefault {
-		plans, err := s.repo.List(ctx, projectID)
+		plans, err := s.repo.List(ctx, projectID, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -90,3 +177,77 @@ func (s *planService) UpdatePlan(ctx context.Context, projectID uuid.UUID, planI
 	// ignore any Code changes (UpdatePlanRequest does not have Code)
 	return s.repo.Update(ctx, projectID, planID, req)
 }
+
+// validateTransition comprueba que current pueda pasar a status. Un plan solo
+// pasa a active si ningún otro draft o active usa su código, y solo se retira
+// cuando ya no le queda ningún tenant (asignado, como fallback de un trial o
+// con un cambio programado hacia él).
+func (s *planService) validateTransition(ctx context.Context, projectID uuid.UUID, current *PlanResponse, status string) error {
+	if !validStatus(status) {
+		return errors.New("status must be one of draft, active, legacy, retired")
+	}
+	allowed := false
+	for _, to := range statusTransitions[current.Status] {
+		if to == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("invalid status transition from %s to %s", current.Status, status)
+	}
+	// el código solo es único entre draft y active: al reactivar un legacy
+	// puede que otro plan ya lo esté usando
+	if status == StatusActive {
+		plans, err := s.repo.List(ctx, projectID, []string{StatusDraft, StatusActive})
+		if err != nil {
+			return err
+		}
+		for _, p := range plans {
+			if p.ID != current.ID && p.Code == current.Code {
+				return fmt.Errorf("plan code already in use by plan %s", p.ID)
+			}
+		}
+	}
+	if status == StatusRetired {
+		n, err := s.repo.CountTenants(ctx, projectID, current.ID)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return fmt.Errorf("plan still has %d tenants; migrate them before retiring it", n)
+		}
+	}
+	return nil
+}
+
+// validateParent comprueba que parentID exista en el proyecto, que no cree un
+// ciclo con planID (uuid.Nil al crear) y que la cadena resultante, incluidos
+// los planes que ya extienden a planID, no supere MaxPlanDepth.
+func (s *planService) validateParent(ctx context.Context, projectID uuid.UUID, planID uuid.UUID, parentID uuid.UUID) error {
+	if parentID == planID {
+		return errors.New("plan cannot extend itself")
+	}
+	chain, err := s.repo.Ancestors(ctx, projectID, parentID)
+	if err != nil {
+		if err.Error() == "plan not found" {
+			return errors.New("parent plan not found")
+		}
+		return err
+	}
+	for _, id := range chain {
+		if id == planID {
+			return errors.New("plan inheritance cycle detected")
+		}
+	}
+	below := 0
+	if planID != uuid.Nil {
+		if below, err = s.repo.SubtreeDepth(ctx, projectID, planID); err != nil {
+			return err
+		}
+	}
+	if len(chain)+1+below > MaxPlanDepth {
+		return fmt.Errorf("plan inheritance exceeds max depth of %d", MaxPlanDepth)
+	}
+	return nil
+}
@@ -23,7 +23,7 @@ func NewProjectHandler(service ProjectService) *ProjectHandler {
 // @Description Retrieve the list of projects
 // @Tags projects
 // @Produce json
-// @Param X-API-Key header string true "API Key"
+// @Param X-Admin-Token header string true "Admin token"
 // @Success 200 {array} projects.ProjectResponse
 // @Failure 401 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -43,7 +43,7 @@ func (h *ProjectHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
 // @Tags projects
 // @Accept json
 // @Produce json
-// @Param X-API-Key header string true "API Key"
+// @Param X-Admin-Token header string true "Admin token"
 // @Param project body projects.CreateProjectRequest true "Create project"
 // @Success 201 {object} projects.ProjectResponse
 // @Failure 400 {object} map[string]string
@@ -74,7 +74,7 @@ func (h *ProjectHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
 // @Description Retrieve a project by its ID
 // @Tags projects
 // @Produce json
-// @Param X-API-Key header string true "API Key"
+// @Param X-Admin-Token header string true "Admin token"
 // @Param projectId path string true "Project ID"
 // @Success 200 {object} projects.ProjectResponse
 // @Failure 401 {object} map[string]string
@@ -107,7 +107,7 @@ func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) {
 // @Tags projects
 // @Accept json
 // @Produce json
-// @Param X-API-Key header string true "API Key"
+// @Param X-Admin-Token header string true "Admin token"
 // @Param projectId path string true "Project ID"
 // @Param project body projects.UpdateProjectRequest true "Update project"
 // @Success 200 {object} projects.ProjectResponse
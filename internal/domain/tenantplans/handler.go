@@ -5,7 +5,9 @@ package tenantplans
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"plans-features/internal/auth"
 	"plans-features/internal/utils"
 
 	"github.com/go-chi/chi/v5"
@@ -25,8 +27,10 @@ func NewTenantPlanHandler(service TenantPlanService) *TenantPlanHandler {
 // @Description Admin: list all plan assignments for a tenant
 // @Tags tenantplans
 // @Produce json
+// @Param X-Admin-Token header string true "Admin token"
 // @Param tenantId path string true "Tenant ID"
 // @Success 200 {array} tenantplans.TenantPlanResponse
+// @Failure 401 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /admin/tenants/{tenantId}/assignments [get]
 func (h *TenantPlanHandler) ListAssignments(w http.ResponseWriter, r *http.Request) {
@@ -45,16 +49,128 @@ func (h *TenantPlanHandler) ListAssignments(w http.ResponseWriter, r *http.Reque
 	utils.JSON(w, http.StatusOK, as)
 }
 
+// ListHistory godoc
+// @Summary List tenant assignment history
+// @Description Admin: append-only history of the tenant's plan assignments, newest first. Each entry is the plan the tenant had from valid_from until valid_to (the next entry of the same project; null = current). plan_id null means the tenant was left without an assignment. actor is who made the change (admin:<token id>, api_key:<key id>, token:<key id> or system) and reason one of assigned, trial_started, trial_ended, scheduled_change, version_migrated or backfill
+// @Tags tenantplans
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param tenantId path string true "Tenant ID"
+// @Param project_id query string false "Only this project"
+// @Success 200 {array} tenantplans.HistoryResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/tenants/{tenantId}/assignments/history [get]
+func (h *TenantPlanHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
+		return
+	}
+	var projectID *uuid.UUID
+	if s := r.URL.Query().Get("project_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid project ID")
+			return
+		}
+		projectID = &id
+	}
+
+	history, err := h.service.ListHistory(r.Context(), tenantID, projectID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, history)
+}
+
+// MigrateVersion godoc
+// @Summary Migrate tenants between plan versions
+// @Description Admin: moves every tenant of the project pinned to from_version_id to to_version_id (a published version, possibly of another plan of the project) in one transaction, recording a version_migrated history entry per tenant. Running trials keep their end date, now on the target plan. Returns how many tenants were migrated
+// @Tags tenantplans
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param projectId path string true "Project ID"
+// @Param body body tenantplans.MigrateVersionRequest true "Versions"
+// @Success 200 {object} tenantplans.MigrateVersionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/projects/{projectId}/plan-versions/migrate [post]
+func (h *TenantPlanHandler) MigrateVersion(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+	var req MigrateVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+
+	res, err := h.service.MigrateVersion(r.Context(), projectID, req)
+	if err != nil {
+		utils.Error(w, migrateErrorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// migrateErrorStatus traduce los errores de la migración de versiones a códigos HTTP
+func migrateErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "plan version not found":
+		return http.StatusNotFound
+	case msg == "target version must be published",
+		strings.HasPrefix(msg, "target plan is"):
+		return http.StatusConflict
+	case strings.HasSuffix(msg, "is required"),
+		strings.HasPrefix(msg, "to_version_id must"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// assignmentErrorStatus traduce los errores de las asignaciones admin a códigos HTTP
+func assignmentErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "plan not found", msg == "tenant plan not found":
+		return http.StatusNotFound
+	case msg == "project already assigned",
+		strings.HasSuffix(msg, "cannot be newly assigned"):
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "invalid "),
+		strings.HasSuffix(msg, "is required"),
+		msg == "plan does not belong to project":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // CreateAssignment godoc
 // @Summary Create a tenant assignment
 // @Description Admin: create a tenant assignment for a given project and plan
 // @Tags tenantplans
 // @Accept json
 // @Produce json
+// @Param X-Admin-Token header string true "Admin token"
 // @Param tenantId path string true "Tenant ID"
 // @Param assignment body tenantplans.CreateTenantPlanRequest true "Create assignment"
 // @Success 201 {object} tenantplans.TenantPlanResponse
 // @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /admin/tenants/{tenantId}/assignments [post]
 func (h *TenantPlanHandler) CreateAssignment(w http.ResponseWriter, r *http.Request) {
@@ -76,7 +192,7 @@ func (h *TenantPlanHandler) CreateAssignment(w http.ResponseWriter, r *http.Requ
 	}
 	p, err := h.service.CreateAssignment(r.Context(), tenantID, req)
 	if err != nil {
-		utils.Error(w, http.StatusInternalServerError, err.Error())
+		utils.Error(w, assignmentErrorStatus(err), err.Error())
 		return
 	}
 	utils.JSON(w, http.StatusCreated, p)
@@ -84,16 +200,19 @@ func (h *TenantPlanHandler) CreateAssignment(w http.ResponseWriter, r *http.Requ
 
 // UpdateAssignment godoc
 // @Summary Update a tenant assignment
-// @Description Admin: update the plan for a tenant assignment
+// @Description Admin: update the plan and/or the billing cycle anchor of a tenant assignment
 // @Tags tenantplans
 // @Accept json
 // @Produce json
+// @Param X-Admin-Token header string true "Admin token"
 // @Param tenantId path string true "Tenant ID"
 // @Param assignmentId path string true "Assignment ID"
 // @Param assignment body tenantplans.UpdateTenantPlanRequest true "Update assignment"
 // @Success 200 {object} tenantplans.TenantPlanResponse
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /admin/tenants/{tenantId}/assignments/{assignmentId} [patch]
 func (h *TenantPlanHandler) UpdateAssignment(w http.ResponseWriter, r *http.Request) {
@@ -117,11 +236,7 @@ func (h *TenantPlanHandler) UpdateAssignment(w http.ResponseWriter, r *http.Requ
 	}
 	p, err := h.service.UpdateAssignment(r.Context(), tenantID, assignmentID, req)
 	if err != nil {
-		if err.Error() == "not found" {
-			utils.Error(w, http.StatusNotFound, "not found")
-			return
-		}
-		utils.Error(w, http.StatusInternalServerError, err.Error())
+		utils.Error(w, assignmentErrorStatus(err), err.Error())
 		return
 	}
 	utils.JSON(w, http.StatusOK, p)
@@ -129,7 +244,7 @@ func (h *TenantPlanHandler) UpdateAssignment(w http.ResponseWriter, r *http.Requ
 
 // API: GetTenantPlan godoc
 // @Summary Get effective tenant plan for a project
-// @Description Returns the assigned plan for tenant+project, falling back to project's default plan
+// @Description Returns the assigned plan for tenant+project, falling back to project's default plan. During a trial it includes trial_ends_at and fallback_plan_id; once the trial has ended plan_id is already the fallback plan
 // @Tags tenantplans
 // @Produce json
 // @Param X-API-Key header string true "API Key"
@@ -147,16 +262,11 @@ func (h *TenantPlanHandler) GetTenantPlan(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-	projectID, err := uuid.Parse(projectIDStr)
-	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid project ID")
-		return
-	}
 	p, err := h.service.GetTenantPlan(r.Context(), tenantID, projectID)
 	if err != nil {
 		utils.Error(w, http.StatusNotFound, err.Error())
@@ -167,7 +277,7 @@ func (h *TenantPlanHandler) GetTenantPlan(w http.ResponseWriter, r *http.Request
 
 // API: AssignTenantPlan godoc
 // @Summary Assign a plan to tenant for the project from context
-// @Description Assigns or updates a tenant's plan for the project identified by the API key
+// @Description Assigns or updates a tenant's plan for the project identified by the API key. cycle_anchor (optional) sets the start of the billing cycle used for usage reset periods; it defaults to now on first assignment and is kept on plan changes. With trial_ends_at the plan is a trial: once it ends the tenant moves to fallback_plan_id, or to the project's default plan if omitted. Assigning a plan without trial_ends_at ends any running trial. Only active plans can be assigned (also as fallback); a legacy plan can only be reassigned to tenants already on it
 // @Tags tenantplans
 // @Accept json
 // @Produce json
@@ -186,12 +296,11 @@ func (h *TenantPlanHandler) AssignTenantPlan(w http.ResponseWriter, r *http.Requ
 		utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
 		return
 	}
-	projectIDStr, ok := r.Context().Value("project_id").(string)
-	if !ok || projectIDStr == "" {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
 		utils.Error(w, http.StatusUnauthorized, "missing project context")
 		return
 	}
-	projectID, err := uuid.Parse(projectIDStr)
 	var req PlanAssignRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.Error(w, http.StatusBadRequest, "invalid body")
@@ -201,10 +310,141 @@ func (h *TenantPlanHandler) AssignTenantPlan(w http.ResponseWriter, r *http.Requ
 		utils.Error(w, http.StatusBadRequest, "plan_id is required")
 		return
 	}
-	p, err := h.service.AssignTenantPlan(r.Context(), tenantID, projectID, req.PlanID)
+	var trial *Trial
+	if req.TrialEndsAt != nil {
+		trial = &Trial{EndsAt: *req.TrialEndsAt, FallbackPlanID: req.FallbackPlanID}
+	} else if req.FallbackPlanID != nil {
+		utils.Error(w, http.StatusBadRequest, "fallback_plan_id requires trial_ends_at")
+		return
+	}
+	p, err := h.service.AssignTenantPlan(r.Context(), tenantID, projectID, req.PlanID, req.CycleAnchor, trial)
 	if err != nil {
 		utils.Error(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	utils.JSON(w, http.StatusOK, p)
 }
+
+// changeErrorStatus traduce los errores de los cambios programados a códigos HTTP
+func changeErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "plan not found", msg == "plan change not found":
+		return http.StatusNotFound
+	case msg == "plan change already scheduled", msg == "plan change is not pending",
+		strings.HasSuffix(msg, "cannot be newly assigned"):
+		return http.StatusConflict
+	case strings.HasSuffix(msg, "is required"),
+		strings.HasPrefix(msg, "effective_at must"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// tenantAndProject lee el tenant de la ruta y el proyecto de la API key
+func tenantAndProject(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, projectID, true
+}
+
+// API: ScheduleChange godoc
+// @Summary Schedule a plan change
+// @Description Schedules the tenant to move to plan_id at effective_at (e.g. a downgrade at the end of the paid period). The current assignment is untouched until then; a background job applies the change once effective_at is reached, exactly once even with several replicas, ending any running trial. Only one pending change per tenant is allowed: cancel it first to reschedule. plan_id must be an active plan (409 otherwise)
+// @Tags tenantplans
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param body body tenantplans.ScheduleChangeRequest true "Scheduled change"
+// @Success 201 {object} tenantplans.PlanChangeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/plan/changes [post]
+func (h *TenantPlanHandler) ScheduleChange(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	var req ScheduleChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	c, err := h.service.ScheduleChange(r.Context(), tenantID, projectID, req)
+	if err != nil {
+		utils.Error(w, changeErrorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusCreated, c)
+}
+
+// API: ListChanges godoc
+// @Summary List scheduled plan changes
+// @Description Lists the tenant's scheduled plan changes (pending, applied and canceled), latest effective_at first
+// @Tags tenantplans
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Success 200 {array} tenantplans.PlanChangeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/plan/changes [get]
+func (h *TenantPlanHandler) ListChanges(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.service.ListChanges(r.Context(), tenantID, projectID)
+	if err != nil {
+		utils.Error(w, changeErrorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// API: CancelChange godoc
+// @Summary Cancel a scheduled plan change
+// @Description Cancels a pending plan change and returns it. Changes already applied or canceled return 409
+// @Tags tenantplans
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param changeId path string true "Change ID"
+// @Success 200 {object} tenantplans.PlanChangeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/plan/changes/{changeId} [delete]
+func (h *TenantPlanHandler) CancelChange(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	changeID, err := uuid.Parse(chi.URLParam(r, "changeId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid change ID")
+		return
+	}
+	c, err := h.service.CancelChange(r.Context(), tenantID, projectID, changeID)
+	if err != nil {
+		utils.Error(w, changeErrorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, c)
+}
@@ -14,7 +14,8 @@ type CreateTenantPlanRequest struct {
 
 // UpdateTenantPlanRequest representa la petición para actualizar la asignación (admin)
 type UpdateTenantPlanRequest struct {
-	PlanCode *string `json:"plan_code,omitempty"`
+	PlanCode    *string    `json:"plan_code,omitempty"`
+	CycleAnchor *time.Time `json:"cycle_anchor,omitempty"`
 }
 
 // TenantPlanResponse representa la respuesta de una asignación
@@ -23,19 +24,146 @@ type TenantPlanResponse struct {
 	TenantID  uuid.UUID `json:"tenant_id"`
 	ProjectID uuid.UUID `json:"project_id"`
 	PlanID    uuid.UUID `json:"plan_id"`
+	// CycleAnchor: inicio del ciclo de facturación (nil en el plan por defecto)
+	CycleAnchor *time.Time `json:"cycle_anchor,omitempty"`
+	// Trial en curso (o vencido y aún sin finalizar): hasta trial_ends_at el
+	// tenant tiene trial_plan_id; después fallback_plan_id o el plan por defecto
+	TrialPlanID    *uuid.UUID `json:"trial_plan_id,omitempty"`
+	TrialEndsAt    *time.Time `json:"trial_ends_at,omitempty"`
+	FallbackPlanID *uuid.UUID `json:"fallback_plan_id,omitempty"`
+	// PlanVersionID: versión publicada del plan a la que está fijado el tenant
+	// (nil si el plan aún no tiene versiones publicadas)
+	PlanVersionID *uuid.UUID `json:"plan_version_id,omitempty"`
 }
 
 // API request para asignar plan usando project_id desde context
+// cycle_anchor opcional: al crear la asignación por defecto es ahora y al
+// cambiar de plan se conserva el ancla anterior.
+// Con trial_ends_at plan_id es el plan del trial; al terminar el tenant pasa a
+// fallback_plan_id (o al plan por defecto del proyecto si no se indica).
 type PlanAssignRequest struct {
-	PlanID uuid.UUID `json:"plan_id"`
+	PlanID         uuid.UUID  `json:"plan_id"`
+	CycleAnchor    *time.Time `json:"cycle_anchor,omitempty"`
+	TrialEndsAt    *time.Time `json:"trial_ends_at,omitempty"`
+	FallbackPlanID *uuid.UUID `json:"fallback_plan_id,omitempty"`
+}
+
+// Trial: el plan asignado dura hasta EndsAt; después el tenant pasa a
+// FallbackPlanID (nil = plan por defecto del proyecto)
+type Trial struct {
+	EndsAt         time.Time
+	FallbackPlanID *uuid.UUID
+}
+
+// Motivos de tenant_plan_transitions
+const (
+	TransitionTrialEnded      = "trial_ended"
+	TransitionScheduledChange = "scheduled_change"
+)
+
+// Transition es un cambio de plan hecho por el sistema; ToPlanID nil = el
+// tenant se queda sin asignación
+type Transition struct {
+	ID         uuid.UUID  `db:"id"`
+	TenantID   uuid.UUID  `db:"tenant_id"`
+	ProjectID  uuid.UUID  `db:"project_id"`
+	FromPlanID *uuid.UUID `db:"from_plan_id"`
+	ToPlanID   *uuid.UUID `db:"to_plan_id"`
+	Reason     string     `db:"reason"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+// Motivos de tenant_plan_history. Los cambios hechos por el sistema usan los
+// mismos motivos que tenant_plan_transitions.
+const (
+	HistoryAssigned     = "assigned"         // asignación o cambio de plan
+	HistoryTrialStarted = "trial_started"    // asignación con trial_ends_at
+	HistoryBackfill     = "backfill"         // asignación anterior al historial
+	HistoryMigrated     = "version_migrated" // migración masiva a otra versión del plan
+)
+
+// HistoryEntry es una fila del historial de asignaciones (solo inserción):
+// el plan del tenant desde ValidFrom hasta la siguiente fila. PlanID nil = sin
+// asignación.
+type HistoryEntry struct {
+	ID             uuid.UUID  `db:"id"`
+	TenantID       uuid.UUID  `db:"tenant_id"`
+	ProjectID      uuid.UUID  `db:"project_id"`
+	PlanID         *uuid.UUID `db:"plan_id"`
+	PlanVersionID  *uuid.UUID `db:"plan_version_id"`
+	TrialEndsAt    *time.Time `db:"trial_ends_at"`
+	FallbackPlanID *uuid.UUID `db:"fallback_plan_id"`
+	ValidFrom      time.Time  `db:"valid_from"`
+	Actor          string     `db:"actor"`
+	Reason         string     `db:"reason"`
+	CreatedAt      time.Time  `db:"created_at"`
+}
+
+// HistoryResponse: valid_to es el valid_from de la siguiente entrada del
+// mismo proyecto (nil = asignación vigente)
+type HistoryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	TenantID       uuid.UUID  `json:"tenant_id"`
+	ProjectID      uuid.UUID  `json:"project_id"`
+	PlanID         *uuid.UUID `json:"plan_id"`
+	PlanVersionID  *uuid.UUID `json:"plan_version_id,omitempty"`
+	TrialEndsAt    *time.Time `json:"trial_ends_at,omitempty"`
+	FallbackPlanID *uuid.UUID `json:"fallback_plan_id,omitempty"`
+	ValidFrom      time.Time  `json:"valid_from"`
+	ValidTo        *time.Time `json:"valid_to"`
+	Actor          string     `json:"actor"`
+	Reason         string     `json:"reason"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// MigrateVersionRequest mueve a to_version_id todos los tenants fijados a
+// from_version_id (admin)
+type MigrateVersionRequest struct {
+	FromVersionID uuid.UUID `json:"from_version_id"`
+	ToVersionID   uuid.UUID `json:"to_version_id"`
+}
+
+type MigrateVersionResponse struct {
+	FromVersionID uuid.UUID `json:"from_version_id"`
+	ToVersionID   uuid.UUID `json:"to_version_id"`
+	Migrated      int       `json:"migrated"`
+}
+
+// Estados de un cambio de plan programado
+const (
+	ChangePending  = "pending"
+	ChangeApplied  = "applied"
+	ChangeCanceled = "canceled"
+)
+
+// ScheduleChangeRequest programa el paso a plan_id en effective_at (p. ej. un
+// downgrade al final del periodo pagado)
+type ScheduleChangeRequest struct {
+	PlanID      uuid.UUID  `json:"plan_id"`
+	EffectiveAt *time.Time `json:"effective_at"`
+}
+
+// PlanChangeResponse es un cambio de plan programado (tabla scheduled_plan_changes)
+type PlanChangeResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	TenantID    uuid.UUID  `json:"tenant_id"`
+	ProjectID   uuid.UUID  `json:"project_id"`
+	PlanID      uuid.UUID  `json:"plan_id"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	Status      string     `json:"status"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	CanceledAt  *time.Time `json:"canceled_at,omitempty"`
+	RequestedBy *string    `json:"requested_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
 }
 
 // Interno para DB (Scan)
 type TenantPlan struct {
-	ID        uuid.UUID `db:"id"`
-	TenantID  uuid.UUID `db:"tenant_id"`
-	ProjectID uuid.UUID `db:"project_id"`
-	PlanID    uuid.UUID `db:"plan_id"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID          uuid.UUID `db:"id"`
+	TenantID    uuid.UUID `db:"tenant_id"`
+	ProjectID   uuid.UUID `db:"project_id"`
+	PlanID      uuid.UUID `db:"plan_id"`
+	CycleAnchor time.Time `db:"cycle_anchor"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
 }
@@ -7,15 +7,27 @@ import (
 	"fmt"
 	"time"
 
+	"plans-features/internal/auth"
+
 	"github.com/google/uuid"
 )
 
 type TenantPlanRepository interface {
 	ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]TenantPlanResponse, error)
-	Create(ctx context.Context, tenantID uuid.UUID, req CreateTenantPlanRequest) (*TenantPlanResponse, error)
-	Update(ctx context.Context, tenantID uuid.UUID, assignmentID uuid.UUID, req UpdateTenantPlanRequest) (*TenantPlanResponse, error)
+	Create(ctx context.Context, tenantID uuid.UUID, req CreateTenantPlanRequest, actor string) (*TenantPlanResponse, error)
+	Update(ctx context.Context, tenantID uuid.UUID, assignmentID uuid.UUID, req UpdateTenantPlanRequest, actor string) (*TenantPlanResponse, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*TenantPlanResponse, error)
 	GetByTenantAndProject(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (*TenantPlanResponse, error)
-	UpsertByTenantAndProject(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID) (*TenantPlanResponse, error)
+	UpsertByTenantAndProject(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID, cycleAnchor *time.Time, trial *Trial, actor string) (*TenantPlanResponse, error)
+	FinalizeExpiredTrials(ctx context.Context, limit int) ([]Transition, error)
+	ListHistory(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]HistoryResponse, error)
+	MigrateVersion(ctx context.Context, projectID uuid.UUID, fromVersionID uuid.UUID, toVersionID uuid.UUID, actor string) (int, error)
+
+	// Cambios de plan programados
+	CreateChange(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID, effectiveAt time.Time, actor string) (*PlanChangeResponse, error)
+	ListChanges(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) ([]PlanChangeResponse, error)
+	CancelChange(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, changeID uuid.UUID) (*PlanChangeResponse, error)
+	ApplyDueChanges(ctx context.Context, limit int) ([]Transition, error)
 }
 
 type tenantPlanRepository struct {
@@ -26,11 +38,95 @@ func NewTenantPlanRepository(db *sql.DB) TenantPlanRepository {
 	return &tenantPlanRepository{db: db}
 }
 
+const tenantPlanColumns = `id, tenant_id, project_id, plan_id, cycle_anchor, trial_plan_id, trial_ends_at, fallback_plan_id, plan_version_id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTenantPlan(row rowScanner) (*TenantPlanResponse, error) {
+	tp := &TenantPlanResponse{}
+	err := row.Scan(&tp.ID, &tp.TenantID, &tp.ProjectID, &tp.PlanID, &tp.CycleAnchor,
+		&tp.TrialPlanID, &tp.TrialEndsAt, &tp.FallbackPlanID, &tp.PlanVersionID)
+	return tp, err
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordHistory añade una entrada al historial de asignaciones; ValidFrom
+// cero = NOW() (la hora de la transacción)
+func recordHistory(ctx context.Context, ex execer, h HistoryEntry) error {
+	var validFrom *time.Time
+	if !h.ValidFrom.IsZero() {
+		validFrom = &h.ValidFrom
+	}
+	_, err := ex.ExecContext(ctx,
+		`INSERT INTO tenant_plan_history (id, tenant_id, project_id, plan_id, plan_version_id, trial_ends_at, fallback_plan_id, valid_from, actor, reason)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, NOW()), $9, $10)`,
+		uuid.New(), h.TenantID.String(), h.ProjectID, h.PlanID, h.PlanVersionID, h.TrialEndsAt, h.FallbackPlanID, validFrom, h.Actor, h.Reason)
+	if err != nil {
+		return fmt.Errorf("record plan history: %w", err)
+	}
+	return nil
+}
+
+// assignmentHistory: entrada de historial con el estado actual de tp
+func assignmentHistory(tp *TenantPlanResponse, actor string) HistoryEntry {
+	planID := tp.PlanID
+	reason := HistoryAssigned
+	if tp.TrialEndsAt != nil {
+		reason = HistoryTrialStarted
+	}
+	return HistoryEntry{
+		TenantID:       tp.TenantID,
+		ProjectID:      tp.ProjectID,
+		PlanID:         &planID,
+		PlanVersionID:  tp.PlanVersionID,
+		TrialEndsAt:    tp.TrialEndsAt,
+		FallbackPlanID: tp.FallbackPlanID,
+		Actor:          actor,
+		Reason:         reason,
+	}
+}
+
+// assignmentChanged: cambió lo que resuelve la asignación (plan, versión o trial)
+func assignmentChanged(prev, tp *TenantPlanResponse) bool {
+	if prev == nil {
+		return true
+	}
+	return prev.PlanID != tp.PlanID ||
+		!sameUUID(prev.PlanVersionID, tp.PlanVersionID) ||
+		!sameTime(prev.TrialEndsAt, tp.TrialEndsAt) ||
+		!sameUUID(prev.FallbackPlanID, tp.FallbackPlanID)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func sameUUID(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// keepPinnedVersion: en un upsert, si el plan no cambia se conserva la
+// versión fijada; si cambia, la última publicada del nuevo plan
+const keepPinnedVersion = `CASE WHEN tenant_plans.plan_id = EXCLUDED.plan_id
+                  THEN COALESCE(tenant_plans.plan_version_id, EXCLUDED.plan_version_id)
+                  ELSE EXCLUDED.plan_version_id END`
+
 func (r *tenantPlanRepository) ListByTenant(ctx context.Context, tenantID uuid.UUID) ([]TenantPlanResponse, error) {
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, tenant_id, project_id, plan_id, created_at, updated_at
-         FROM tenant_plans 
-         WHERE tenant_id = $1 
+		`SELECT `+tenantPlanColumns+`
+         FROM tenant_plans
+         WHERE tenant_id = $1
          ORDER BY created_at DESC`,
 		tenantID)
 	if err != nil {
@@ -40,67 +136,103 @@ func (r *tenantPlanRepository) ListByTenant(ctx context.Context, tenantID uuid.U
 
 	var results []TenantPlanResponse
 	for rows.Next() {
-		var tp TenantPlanResponse
-		var createdAt, updatedAt time.Time
-		err := rows.Scan(&tp.ID, &tp.TenantID, &tp.ProjectID, &tp.PlanID, &createdAt, &updatedAt)
+		tp, err := scanTenantPlan(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scan tenant plan: %w", err)
 		}
-		results = append(results, tp)
+		results = append(results, *tp)
 	}
 	return results, rows.Err()
 }
 
-func (r *tenantPlanRepository) Create(ctx context.Context, tenantID uuid.UUID, req CreateTenantPlanRequest) (*TenantPlanResponse, error) {
+func (r *tenantPlanRepository) Create(ctx context.Context, tenantID uuid.UUID, req CreateTenantPlanRequest, actor string) (*TenantPlanResponse, error) {
 	id := uuid.New()
 
-	tp := &TenantPlanResponse{}
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO tenant_plans (id, tenant_id, project_id, plan_id)
-         VALUES ($1, $2, $3, $4)
-         RETURNING id, tenant_id, project_id, plan_id`,
-		id, tenantID, req.ProjectCode, req.PlanCode).
-		Scan(&tp.ID, &tp.TenantID, &tp.ProjectID, &tp.PlanID)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin create tenant plan: %w", err)
+	}
+	defer tx.Rollback()
+
+	tp, err := scanTenantPlan(tx.QueryRowContext(ctx,
+		`INSERT INTO tenant_plans (id, tenant_id, project_id, plan_id, plan_version_id)
+         VALUES ($1, $2, $3, $4, plan_version_at($4::uuid, NOW()))
+         RETURNING `+tenantPlanColumns,
+		id, tenantID, req.ProjectCode, req.PlanCode))
 
 	if err != nil {
 		return nil, fmt.Errorf("create tenant plan: %w", err)
 	}
+	if err := recordHistory(ctx, tx, assignmentHistory(tp, actor)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit create tenant plan: %w", err)
+	}
 	return tp, nil
 }
 
-func (r *tenantPlanRepository) Update(ctx context.Context, tenantID uuid.UUID, assignmentID uuid.UUID, req UpdateTenantPlanRequest) (*TenantPlanResponse, error) {
-	if req.PlanCode == nil {
+func (r *tenantPlanRepository) Update(ctx context.Context, tenantID uuid.UUID, assignmentID uuid.UUID, req UpdateTenantPlanRequest, actor string) (*TenantPlanResponse, error) {
+	if req.PlanCode == nil && req.CycleAnchor == nil {
 		// Nada que actualizar
 		return r.GetByID(ctx, assignmentID)
 	}
 
-	tp := &TenantPlanResponse{}
-	err := r.db.QueryRowContext(ctx,
-		`UPDATE tenant_plans 
-         SET plan_id = $1, updated_at = NOW()
-         WHERE id = $2 AND tenant_id = $3
-         RETURNING id, tenant_id, project_id, plan_id`,
-		req.PlanCode, assignmentID, tenantID).
-		Scan(&tp.ID, &tp.TenantID, &tp.ProjectID, &tp.PlanID)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin update tenant plan: %w", err)
+	}
+	defer tx.Rollback()
 
+	prev, err := scanTenantPlan(tx.QueryRowContext(ctx,
+		`SELECT `+tenantPlanColumns+`
+         FROM tenant_plans
+         WHERE id = $1 AND tenant_id = $2
+         FOR UPDATE`,
+		assignmentID, tenantID))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.New("tenant plan not found")
 	}
+	if err != nil {
+		return nil, fmt.Errorf("get tenant plan: %w", err)
+	}
+
+	// cambiar el plan termina cualquier trial en curso y fija la última
+	// versión publicada del nuevo plan
+	tp, err := scanTenantPlan(tx.QueryRowContext(ctx,
+		`UPDATE tenant_plans
+         SET plan_id = COALESCE($1, plan_id),
+             plan_version_id = CASE WHEN $1::uuid IS NULL OR $1::uuid = plan_id THEN plan_version_id
+                                    ELSE plan_version_at($1::uuid, NOW()) END,
+             cycle_anchor = COALESCE($4, cycle_anchor),
+             trial_plan_id = CASE WHEN $1::uuid IS NULL THEN trial_plan_id END,
+             trial_ends_at = CASE WHEN $1::uuid IS NULL THEN trial_ends_at END,
+             fallback_plan_id = CASE WHEN $1::uuid IS NULL THEN fallback_plan_id END,
+             updated_at = NOW()
+         WHERE id = $2 AND tenant_id = $3
+         RETURNING `+tenantPlanColumns,
+		req.PlanCode, assignmentID, tenantID, req.CycleAnchor))
 	if err != nil {
 		return nil, fmt.Errorf("update tenant plan: %w", err)
 	}
 
+	if assignmentChanged(prev, tp) {
+		if err := recordHistory(ctx, tx, assignmentHistory(tp, actor)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit update tenant plan: %w", err)
+	}
 	return tp, nil
 }
 
 func (r *tenantPlanRepository) GetByTenantAndProject(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (*TenantPlanResponse, error) {
-	tp := &TenantPlanResponse{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, tenant_id, project_id, plan_id
-         FROM tenant_plans 
+	tp, err := scanTenantPlan(r.db.QueryRowContext(ctx,
+		`SELECT `+tenantPlanColumns+`
+         FROM tenant_plans
          WHERE tenant_id = $1 AND project_id = $2`,
-		tenantID, projectID).
-		Scan(&tp.ID, &tp.TenantID, &tp.ProjectID, &tp.PlanID)
+		tenantID, projectID))
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.New("tenant plan not found")
@@ -112,34 +244,445 @@ func (r *tenantPlanRepository) GetByTenantAndProject(ctx context.Context, tenant
 	return tp, nil
 }
 
-func (r *tenantPlanRepository) UpsertByTenantAndProject(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID) (*TenantPlanResponse, error) {
-	tp := &TenantPlanResponse{}
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO tenant_plans (tenant_id, project_id, plan_id)
-         VALUES ($1, $2, $3)
+// UpsertByTenantAndProject asigna planID. Con trial el plan dura hasta
+// trial.EndsAt; sin él, cualquier trial en curso termina aquí. Si cambia el
+// plan o el trial se registra en el historial. Un plan nuevo fija su última
+// versión publicada; reasignar el mismo plan conserva la versión fijada.
+func (r *tenantPlanRepository) UpsertByTenantAndProject(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID, cycleAnchor *time.Time, trial *Trial, actor string) (*TenantPlanResponse, error) {
+	var trialPlanID, fallbackPlanID *uuid.UUID
+	var trialEndsAt *time.Time
+	if trial != nil {
+		trialPlanID, trialEndsAt, fallbackPlanID = &planID, &trial.EndsAt, trial.FallbackPlanID
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin upsert tenant plan: %w", err)
+	}
+	defer tx.Rollback()
+
+	prev, err := scanTenantPlan(tx.QueryRowContext(ctx,
+		`SELECT `+tenantPlanColumns+`
+         FROM tenant_plans
+         WHERE tenant_id = $1 AND project_id = $2
+         FOR UPDATE`,
+		tenantID, projectID))
+	if errors.Is(err, sql.ErrNoRows) {
+		prev = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get tenant plan: %w", err)
+	}
+
+	tp, err := scanTenantPlan(tx.QueryRowContext(ctx,
+		`INSERT INTO tenant_plans (tenant_id, project_id, plan_id, plan_version_id, cycle_anchor, trial_plan_id, trial_ends_at, fallback_plan_id)
+         VALUES ($1, $2, $3, plan_version_at($3, NOW()), COALESCE($4, NOW()), $5, $6, $7)
          ON CONFLICT (tenant_id, project_id)
-         DO UPDATE SET 
+         DO UPDATE SET
              plan_id = EXCLUDED.plan_id,
+             plan_version_id = `+keepPinnedVersion+`,
+             cycle_anchor = COALESCE($4, tenant_plans.cycle_anchor),
+             trial_plan_id = EXCLUDED.trial_plan_id,
+             trial_ends_at = EXCLUDED.trial_ends_at,
+             fallback_plan_id = EXCLUDED.fallback_plan_id,
              updated_at = NOW()
-         RETURNING id, tenant_id, project_id, plan_id`,
-		tenantID, projectID, planID).
-		Scan(&tp.ID, &tp.TenantID, &tp.ProjectID, &tp.PlanID)
+         RETURNING `+tenantPlanColumns,
+		tenantID, projectID, planID, cycleAnchor, trialPlanID, trialEndsAt, fallbackPlanID))
 
 	if err != nil {
 		return nil, fmt.Errorf("upsert tenant plan: %w", err)
 	}
 
+	if assignmentChanged(prev, tp) {
+		if err := recordHistory(ctx, tx, assignmentHistory(tp, actor)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit upsert tenant plan: %w", err)
+	}
 	return tp, nil
 }
 
-// Helper para GetByID (si lo necesitas en otros métodos)
+// FinalizeExpiredTrials pasa hasta limit trials vencidos a su plan de
+// fallback (o al plan por defecto del proyecto) y registra cada transición,
+// también en el historial con valid_from = trial_ends_at.
+// Sin ninguno de los dos la asignación se borra y el tenant queda sin plan.
+// Las filas se reclaman con SKIP LOCKED, así que varias réplicas pueden
+// ejecutarlo a la vez sin procesar dos veces el mismo trial.
+func (r *tenantPlanRepository) FinalizeExpiredTrials(ctx context.Context, limit int) ([]Transition, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin finalize trials: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT tp.id, tp.tenant_id, tp.project_id, tp.plan_id, tp.trial_ends_at,
+                COALESCE(tp.fallback_plan_id, (
+                    SELECT p.id FROM plans p
+                    WHERE p.project_id = tp.project_id AND p.is_default = true
+                    ORDER BY p.created_at DESC
+                    LIMIT 1
+                ))
+         FROM tenant_plans tp
+         WHERE tp.trial_ends_at <= NOW()
+         ORDER BY tp.trial_ends_at
+         LIMIT $1
+         FOR UPDATE OF tp SKIP LOCKED`,
+		limit)
+	if err != nil {
+		return nil, fmt.Errorf("list expired trials: %w", err)
+	}
+	type expired struct {
+		assignmentID uuid.UUID
+		trialEndsAt  time.Time
+		versionID    *uuid.UUID
+		transition   Transition
+	}
+	var due []expired
+	for rows.Next() {
+		var e expired
+		var fromPlanID uuid.UUID
+		if err := rows.Scan(&e.assignmentID, &e.transition.TenantID, &e.transition.ProjectID,
+			&fromPlanID, &e.trialEndsAt, &e.transition.ToPlanID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan expired trial: %w", err)
+		}
+		e.transition.FromPlanID = &fromPlanID
+		due = append(due, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list expired trials: %w", err)
+	}
+
+	transitions := make([]Transition, 0, len(due))
+	for _, e := range due {
+		if e.transition.ToPlanID == nil {
+			_, err = tx.ExecContext(ctx, `DELETE FROM tenant_plans WHERE id = $1`, e.assignmentID)
+		} else {
+			err = tx.QueryRowContext(ctx,
+				`UPDATE tenant_plans
+                 SET plan_id = $2, plan_version_id = plan_version_at($2, NOW()),
+                     trial_plan_id = NULL, trial_ends_at = NULL,
+                     fallback_plan_id = NULL, updated_at = NOW()
+                 WHERE id = $1
+                 RETURNING plan_version_id`,
+				e.assignmentID, *e.transition.ToPlanID).Scan(&e.versionID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("finalize trial: %w", err)
+		}
+
+		t := e.transition
+		t.ID = uuid.New()
+		t.Reason = TransitionTrialEnded
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO tenant_plan_transitions (id, tenant_id, project_id, from_plan_id, to_plan_id, reason)
+             VALUES ($1, $2, $3, $4, $5, $6)
+             RETURNING created_at`,
+			t.ID, t.TenantID.String(), t.ProjectID, t.FromPlanID, t.ToPlanID, t.Reason).Scan(&t.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("record plan transition: %w", err)
+		}
+		err = recordHistory(ctx, tx, HistoryEntry{
+			TenantID:      t.TenantID,
+			ProjectID:     t.ProjectID,
+			PlanID:        t.ToPlanID,
+			PlanVersionID: e.versionID,
+			ValidFrom:     e.trialEndsAt,
+			Actor:         auth.ActorSystem,
+			Reason:        TransitionTrialEnded,
+		})
+		if err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit finalize trials: %w", err)
+	}
+	return transitions, nil
+}
+
+const planChangeColumns = `id, tenant_id, project_id, plan_id, effective_at, status, applied_at, canceled_at, requested_by, created_at`
+
+func scanPlanChange(row rowScanner) (*PlanChangeResponse, error) {
+	c := &PlanChangeResponse{}
+	err := row.Scan(&c.ID, &c.TenantID, &c.ProjectID, &c.PlanID, &c.EffectiveAt,
+		&c.Status, &c.AppliedAt, &c.CanceledAt, &c.RequestedBy, &c.CreatedAt)
+	return c, err
+}
+
+// CreateChange programa un cambio de plan. Solo puede haber uno pending por
+// tenant/proyecto (índice único parcial).
+func (r *tenantPlanRepository) CreateChange(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID, effectiveAt time.Time, actor string) (*PlanChangeResponse, error) {
+	c, err := scanPlanChange(r.db.QueryRowContext(ctx,
+		`INSERT INTO scheduled_plan_changes (id, tenant_id, project_id, plan_id, effective_at, requested_by)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         ON CONFLICT (tenant_id, project_id) WHERE status = 'pending' DO NOTHING
+         RETURNING `+planChangeColumns,
+		uuid.New(), tenantID.String(), projectID, planID, effectiveAt, actor))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("plan change already scheduled")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create plan change: %w", err)
+	}
+	return c, nil
+}
+
+func (r *tenantPlanRepository) ListChanges(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) ([]PlanChangeResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+planChangeColumns+`
+         FROM scheduled_plan_changes
+         WHERE tenant_id = $1 AND project_id = $2
+         ORDER BY effective_at DESC, created_at DESC`,
+		tenantID.String(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("list plan changes: %w", err)
+	}
+	defer rows.Close()
+
+	results := []PlanChangeResponse{}
+	for rows.Next() {
+		c, err := scanPlanChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan plan change: %w", err)
+		}
+		results = append(results, *c)
+	}
+	return results, rows.Err()
+}
+
+// CancelChange cancela un cambio pending. Si el scheduler lo está aplicando
+// en ese momento, el UPDATE espera a su transacción y ya no lo encuentra pending.
+func (r *tenantPlanRepository) CancelChange(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, changeID uuid.UUID) (*PlanChangeResponse, error) {
+	c, err := scanPlanChange(r.db.QueryRowContext(ctx,
+		`UPDATE scheduled_plan_changes
+         SET status = 'canceled', canceled_at = NOW()
+         WHERE id = $1 AND tenant_id = $2 AND project_id = $3 AND status = 'pending'
+         RETURNING `+planChangeColumns,
+		changeID, tenantID.String(), projectID))
+	if err == nil {
+		return c, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("cancel plan change: %w", err)
+	}
+
+	var status string
+	err = r.db.QueryRowContext(ctx,
+		`SELECT status FROM scheduled_plan_changes
+         WHERE id = $1 AND tenant_id = $2 AND project_id = $3`,
+		changeID, tenantID.String(), projectID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("plan change not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get plan change: %w", err)
+	}
+	return nil, errors.New("plan change is not pending")
+}
+
+// ApplyDueChanges aplica hasta limit cambios pending cuyo effective_at ya
+// pasó: fija el plan en tenant_plans (terminando cualquier trial), marca el
+// cambio como applied y registra la transición y la entrada del historial
+// (con quien programó el cambio como actor), todo en una transacción.
+// Los cambios se reclaman con SKIP LOCKED y solo se aplican si siguen
+// pending, así que con varias réplicas cada uno se aplica una sola vez.
+// Si el plan destino dejó de estar active desde que se programó (salvo que el
+// tenant ya lo tenga), el cambio se cancela en vez de aplicarse.
+func (r *tenantPlanRepository) ApplyDueChanges(ctx context.Context, limit int) ([]Transition, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin apply plan changes: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE scheduled_plan_changes
+         SET status = 'canceled', canceled_at = NOW()
+         WHERE id IN (
+             SELECT c.id
+             FROM scheduled_plan_changes c
+             JOIN plans p ON p.id = c.plan_id
+             LEFT JOIN tenant_plans tp
+                    ON tp.tenant_id = c.tenant_id AND tp.project_id = c.project_id
+             WHERE c.status = 'pending' AND c.effective_at <= NOW()
+               AND p.status <> 'active' AND tp.plan_id IS DISTINCT FROM c.plan_id
+             FOR UPDATE OF c SKIP LOCKED)`)
+	if err != nil {
+		return nil, fmt.Errorf("cancel unassignable plan changes: %w", err)
+	}
+
+	// el plan se bloquea FOR SHARE: su status no cambia hasta el commit
+	rows, err := tx.QueryContext(ctx,
+		`SELECT c.id, c.tenant_id, c.project_id, c.plan_id, tp.plan_id,
+                COALESCE(c.requested_by, $2)
+         FROM scheduled_plan_changes c
+         JOIN plans p ON p.id = c.plan_id
+         LEFT JOIN tenant_plans tp
+                ON tp.tenant_id = c.tenant_id AND tp.project_id = c.project_id
+         WHERE c.status = 'pending' AND c.effective_at <= NOW()
+           AND (p.status = 'active' OR tp.plan_id = c.plan_id)
+         ORDER BY c.effective_at
+         LIMIT $1
+         FOR UPDATE OF c SKIP LOCKED
+         FOR SHARE OF p`,
+		limit, auth.ActorSystem)
+	if err != nil {
+		return nil, fmt.Errorf("list due plan changes: %w", err)
+	}
+	type dueChange struct {
+		changeID   uuid.UUID
+		actor      string
+		transition Transition
+	}
+	var due []dueChange
+	for rows.Next() {
+		var d dueChange
+		var toPlanID uuid.UUID
+		if err := rows.Scan(&d.changeID, &d.transition.TenantID, &d.transition.ProjectID,
+			&toPlanID, &d.transition.FromPlanID, &d.actor); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan due plan change: %w", err)
+		}
+		d.transition.ToPlanID = &toPlanID
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list due plan changes: %w", err)
+	}
+
+	transitions := make([]Transition, 0, len(due))
+	for _, d := range due {
+		t := d.transition
+		var versionID *uuid.UUID
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO tenant_plans (tenant_id, project_id, plan_id, plan_version_id, cycle_anchor)
+             VALUES ($1, $2, $3, plan_version_at($3, NOW()), NOW())
+             ON CONFLICT (tenant_id, project_id)
+             DO UPDATE SET
+                 plan_id = EXCLUDED.plan_id,
+                 plan_version_id = `+keepPinnedVersion+`,
+                 trial_plan_id = NULL,
+                 trial_ends_at = NULL,
+                 fallback_plan_id = NULL,
+                 updated_at = NOW()
+             RETURNING plan_version_id`,
+			t.TenantID, t.ProjectID, *t.ToPlanID).Scan(&versionID)
+		if err != nil {
+			return nil, fmt.Errorf("apply plan change: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`UPDATE scheduled_plan_changes
+             SET status = 'applied', applied_at = NOW()
+             WHERE id = $1`,
+			d.changeID)
+		if err != nil {
+			return nil, fmt.Errorf("mark plan change applied: %w", err)
+		}
+
+		t.ID = uuid.New()
+		t.Reason = TransitionScheduledChange
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO tenant_plan_transitions (id, tenant_id, project_id, from_plan_id, to_plan_id, reason)
+             VALUES ($1, $2, $3, $4, $5, $6)
+             RETURNING created_at`,
+			t.ID, t.TenantID.String(), t.ProjectID, t.FromPlanID, t.ToPlanID, t.Reason).Scan(&t.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("record plan transition: %w", err)
+		}
+		err = recordHistory(ctx, tx, HistoryEntry{
+			TenantID:      t.TenantID,
+			ProjectID:     t.ProjectID,
+			PlanID:        t.ToPlanID,
+			PlanVersionID: versionID,
+			Actor:         d.actor,
+			Reason:        TransitionScheduledChange,
+		})
+		if err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit apply plan changes: %w", err)
+	}
+	return transitions, nil
+}
+
+// ListHistory devuelve el historial de asignaciones del tenant (de un proyecto
+// o de todos), lo más reciente primero. valid_to se deriva de la entrada
+// siguiente del mismo proyecto.
+func (r *tenantPlanRepository) ListHistory(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]HistoryResponse, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, tenant_id, project_id, plan_id, plan_version_id, trial_ends_at, fallback_plan_id, valid_from,
+                LEAD(valid_from) OVER (PARTITION BY project_id ORDER BY valid_from, created_at, id),
+                actor, reason, created_at
+         FROM tenant_plan_history
+         WHERE tenant_id = $1 AND ($2::uuid IS NULL OR project_id = $2)
+         ORDER BY valid_from DESC, created_at DESC, id DESC`,
+		tenantID.String(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("list plan history: %w", err)
+	}
+	defer rows.Close()
+
+	results := []HistoryResponse{}
+	for rows.Next() {
+		var h HistoryResponse
+		if err := rows.Scan(&h.ID, &h.TenantID, &h.ProjectID, &h.PlanID, &h.PlanVersionID, &h.TrialEndsAt, &h.FallbackPlanID,
+			&h.ValidFrom, &h.ValidTo, &h.Actor, &h.Reason, &h.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan plan history: %w", err)
+		}
+		results = append(results, h)
+	}
+	return results, rows.Err()
+}
+
+// MigrateVersion fija toVersionID (y su plan) en todas las asignaciones del
+// proyecto fijadas a fromVersionID, con una entrada de historial por tenant.
+// Un trial en curso sigue en curso, ahora sobre el plan de la nueva versión.
+func (r *tenantPlanRepository) MigrateVersion(ctx context.Context, projectID uuid.UUID, fromVersionID uuid.UUID, toVersionID uuid.UUID, actor string) (int, error) {
+	res, err := r.db.ExecContext(ctx,
+		`WITH moved AS (
+             UPDATE tenant_plans tp
+             SET plan_id = v.plan_id,
+                 plan_version_id = v.id,
+                 trial_plan_id = CASE WHEN tp.trial_plan_id IS NOT NULL THEN v.plan_id END,
+                 updated_at = NOW()
+             FROM plan_versions v
+             WHERE v.id = $3 AND v.project_id = $1
+               AND tp.project_id = $1 AND tp.plan_version_id = $2
+             RETURNING tp.tenant_id, tp.project_id, tp.plan_id, tp.plan_version_id,
+                       tp.trial_ends_at, tp.fallback_plan_id
+         )
+         INSERT INTO tenant_plan_history (tenant_id, project_id, plan_id, plan_version_id, trial_ends_at, fallback_plan_id, valid_from, actor, reason)
+         SELECT tenant_id, project_id, plan_id, plan_version_id, trial_ends_at, fallback_plan_id, NOW(), $4, $5
+         FROM moved`,
+		projectID, fromVersionID, toVersionID, actor, HistoryMigrated)
+	if err != nil {
+		return 0, fmt.Errorf("migrate plan version: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("migrate plan version: %w", err)
+	}
+	return int(n), nil
+}
+
 func (r *tenantPlanRepository) GetByID(ctx context.Context, id uuid.UUID) (*TenantPlanResponse, error) {
-	tp := &TenantPlanResponse{}
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, tenant_id, project_id, plan_id
+	tp, err := scanTenantPlan(r.db.QueryRowContext(ctx,
+		`SELECT `+tenantPlanColumns+`
          FROM tenant_plans WHERE id = $1`,
-		id).
-		Scan(&tp.ID, &tp.TenantID, &tp.ProjectID, &tp.PlanID)
+		id))
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, errors.New("tenant plan not found")
@@ -0,0 +1,244 @@
+package tenantplans
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"plans-features/internal/db/dbtest"
+)
+
+// changeFixture: tenants en el plan from con un cambio a to ya vencido
+type changeFixture struct {
+	dbtest.Fixture
+	repo     TenantPlanRepository
+	from, to uuid.UUID
+	changes  map[uuid.UUID]uuid.UUID // tenant → cambio pending
+}
+
+func newChangeFixture(t *testing.T, tenants int) changeFixture {
+	t.Helper()
+	base := dbtest.New(t)
+	f := changeFixture{
+		Fixture: base,
+		repo:    NewTenantPlanRepository(base.DB),
+		from:    base.Plan(t, "basic"),
+		to:      base.Plan(t, "pro"),
+		changes: map[uuid.UUID]uuid.UUID{},
+	}
+	ctx := context.Background()
+	for i := 0; i < tenants; i++ {
+		tenantID := uuid.New()
+		if _, err := f.repo.UpsertByTenantAndProject(ctx, tenantID, f.ProjectID, f.from, nil, nil, "test"); err != nil {
+			t.Fatalf("UpsertByTenantAndProject: %v", err)
+		}
+		// el servicio exige effective_at futuro; el repositorio no
+		c, err := f.repo.CreateChange(ctx, tenantID, f.ProjectID, f.to, time.Now().Add(-time.Minute), "test")
+		if err != nil {
+			t.Fatalf("CreateChange: %v", err)
+		}
+		f.changes[tenantID] = c.ID
+	}
+	return f
+}
+
+// schedule ejecuta ApplyDueChanges hasta que no quede nada que reclamar y
+// devuelve las transiciones del proyecto del fixture
+func (f changeFixture) schedule(t *testing.T, start <-chan struct{}) []Transition {
+	<-start
+	var own []Transition
+	for {
+		transitions, err := f.repo.ApplyDueChanges(context.Background(), 3)
+		if err != nil {
+			t.Errorf("ApplyDueChanges: %v", err)
+			return own
+		}
+		if len(transitions) == 0 {
+			return own
+		}
+		for _, tr := range transitions {
+			if tr.ProjectID == f.ProjectID {
+				own = append(own, tr)
+			}
+		}
+	}
+}
+
+func (f changeFixture) currentPlan(t *testing.T, tenantID uuid.UUID) uuid.UUID {
+	t.Helper()
+	tp, err := f.repo.GetByTenantAndProject(context.Background(), tenantID, f.ProjectID)
+	if err != nil || tp == nil {
+		t.Fatalf("GetByTenantAndProject = %v, %v", tp, err)
+	}
+	return tp.PlanID
+}
+
+func (f changeFixture) changeStatus(t *testing.T, changeID uuid.UUID) string {
+	t.Helper()
+	var status string
+	if err := f.DB.QueryRow(`SELECT status FROM scheduled_plan_changes WHERE id = $1`, changeID).Scan(&status); err != nil {
+		t.Fatalf("get plan change status: %v", err)
+	}
+	return status
+}
+
+func TestApplyDueChangesConcurrentSchedulers(t *testing.T) {
+	const tenants = 20
+	f := newChangeFixture(t, tenants)
+
+	start := make(chan struct{})
+	results := make([][]Transition, 2)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = f.schedule(t, start)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	// cada cambio lo aplica exactamente una de las dos réplicas
+	applied := map[uuid.UUID]int{}
+	for _, transitions := range results {
+		for _, tr := range transitions {
+			applied[tr.TenantID]++
+			if tr.Reason != TransitionScheduledChange || tr.FromPlanID == nil || *tr.FromPlanID != f.from ||
+				tr.ToPlanID == nil || *tr.ToPlanID != f.to {
+				t.Errorf("transition = %+v", tr)
+			}
+		}
+	}
+	if len(applied) != tenants {
+		t.Errorf("applied changes for %d tenants, want %d", len(applied), tenants)
+	}
+	for tenantID, changeID := range f.changes {
+		if n := applied[tenantID]; n != 1 {
+			t.Errorf("tenant %s: applied %d times", tenantID, n)
+		}
+		if status := f.changeStatus(t, changeID); status != ChangeApplied {
+			t.Errorf("change %s: status %s, want applied", changeID, status)
+		}
+		if plan := f.currentPlan(t, tenantID); plan != f.to {
+			t.Errorf("tenant %s: plan %s, want %s", tenantID, plan, f.to)
+		}
+	}
+
+	if n := f.Count(t, `SELECT COUNT(*) FROM tenant_plan_transitions WHERE project_id = $1`, f.ProjectID); n != tenants {
+		t.Errorf("transitions = %d, want %d", n, tenants)
+	}
+	if n := f.Count(t, `SELECT COUNT(*) FROM tenant_plan_history WHERE project_id = $1 AND reason = $2`,
+		f.ProjectID, TransitionScheduledChange); n != tenants {
+		t.Errorf("history entries = %d, want %d", n, tenants)
+	}
+}
+
+func TestCancelChangeRacesApply(t *testing.T) {
+	const tenants = 20
+	f := newChangeFixture(t, tenants)
+	ctx := context.Background()
+
+	start := make(chan struct{})
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		canceled = map[uuid.UUID]bool{}
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f.schedule(t, start)
+	}()
+	for tenantID, changeID := range f.changes {
+		wg.Add(1)
+		go func(tenantID, changeID uuid.UUID) {
+			defer wg.Done()
+			<-start
+			_, err := f.repo.CancelChange(ctx, tenantID, f.ProjectID, changeID)
+			if err != nil && err.Error() != "plan change is not pending" {
+				t.Errorf("CancelChange: %v", err)
+				return
+			}
+			mu.Lock()
+			canceled[tenantID] = err == nil
+			mu.Unlock()
+		}(tenantID, changeID)
+	}
+	close(start)
+	wg.Wait()
+
+	// lo que no se canceló a tiempo lo aplica una pasada más del scheduler
+	f.schedule(t, start)
+
+	for tenantID, changeID := range f.changes {
+		transitions := f.Count(t, `SELECT COUNT(*) FROM tenant_plan_transitions WHERE tenant_id = $1 AND project_id = $2`,
+			tenantID.String(), f.ProjectID)
+		status, plan := f.changeStatus(t, changeID), f.currentPlan(t, tenantID)
+		if canceled[tenantID] {
+			if status != ChangeCanceled || plan != f.from || transitions != 0 {
+				t.Errorf("canceled change %s: status %s, plan %s, %d transitions", changeID, status, plan, transitions)
+			}
+		} else {
+			if status != ChangeApplied || plan != f.to || transitions != 1 {
+				t.Errorf("applied change %s: status %s, plan %s, %d transitions", changeID, status, plan, transitions)
+			}
+		}
+	}
+}
+
+func TestApplyDueChangesUnassignableTarget(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		alreadyOnTo bool
+		wantStatus  string
+	}{
+		{"legacy target", "legacy", false, ChangeCanceled},
+		{"draft target", "draft", false, ChangeCanceled},
+		{"legacy target already assigned", "legacy", true, ChangeApplied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newChangeFixture(t, 1)
+			ctx := context.Background()
+			var tenantID, changeID uuid.UUID
+			for id, c := range f.changes {
+				tenantID, changeID = id, c
+			}
+			wantPlan := f.from
+			if tt.alreadyOnTo {
+				// el tenant ya está en to: el cambio solo termina de fijarlo
+				if _, err := f.repo.UpsertByTenantAndProject(ctx, tenantID, f.ProjectID, f.to, nil, nil, "test"); err != nil {
+					t.Fatalf("UpsertByTenantAndProject: %v", err)
+				}
+				wantPlan = f.to
+			}
+			// el plan cambia de estado después de programar el cambio
+			if _, err := f.DB.Exec(`UPDATE plans SET status = $2 WHERE id = $1`, f.to, tt.status); err != nil {
+				t.Fatalf("update plan status: %v", err)
+			}
+
+			start := make(chan struct{})
+			close(start)
+			f.schedule(t, start)
+
+			if status := f.changeStatus(t, changeID); status != tt.wantStatus {
+				t.Errorf("change status = %s, want %s", status, tt.wantStatus)
+			}
+			if plan := f.currentPlan(t, tenantID); plan != wantPlan {
+				t.Errorf("plan = %s, want %s", plan, wantPlan)
+			}
+			wantTransitions := 0
+			if tt.wantStatus == ChangeApplied {
+				wantTransitions = 1
+			}
+			if n := f.Count(t, `SELECT COUNT(*) FROM tenant_plan_transitions WHERE project_id = $1`, f.ProjectID); n != wantTransitions {
+				t.Errorf("transitions = %d, want %d", n, wantTransitions)
+			}
+		})
+	}
+}
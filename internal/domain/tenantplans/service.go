@@ -3,7 +3,12 @@ package tenantplans
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"time"
 
+	"plans-features/internal/auth"
+	"plans-features/internal/domain/planfeatures"
 	"plans-features/internal/domain/plans"
 	"plans-features/internal/domain/projects"
 
@@ -14,27 +19,47 @@ type TenantPlanService interface {
 	ListAssignments(ctx context.Context, tenantID uuid.UUID) ([]TenantPlanResponse, error)
 	CreateAssignment(ctx context.Context, tenantID uuid.UUID, req CreateTenantPlanRequest) (*TenantPlanResponse, error)
 	UpdateAssignment(ctx context.Context, tenantID uuid.UUID, assignmentID uuid.UUID, req UpdateTenantPlanRequest) (*TenantPlanResponse, error)
+	ListHistory(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]HistoryResponse, error)
+	MigrateVersion(ctx context.Context, projectID uuid.UUID, req MigrateVersionRequest) (*MigrateVersionResponse, error)
 
 	// API methods
 	GetTenantPlan(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (*TenantPlanResponse, error)
-	AssignTenantPlan(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID) (*TenantPlanResponse, error)
+	AssignTenantPlan(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID, cycleAnchor *time.Time, trial *Trial) (*TenantPlanResponse, error)
+
+	// Scheduled plan changes
+	ScheduleChange(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req ScheduleChangeRequest) (*PlanChangeResponse, error)
+	ListChanges(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) ([]PlanChangeResponse, error)
+	CancelChange(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, changeID uuid.UUID) (*PlanChangeResponse, error)
+
+	// Background
+	FinalizeExpiredTrials(ctx context.Context) error
+	ApplyScheduledChanges(ctx context.Context) error
 }
 
+// Tamaño de lote de los jobs: trials finalizados / cambios aplicados por transacción
+const (
+	trialBatchSize  = 100
+	changeBatchSize = 100
+)
+
 type tenantPlanService struct {
-	repo        TenantPlanRepository
-	projectRepo projects.ProjectRepository
-	planRepo    plans.PlanRepository
+	repo            TenantPlanRepository
+	projectRepo     projects.ProjectRepository
+	planRepo        plans.PlanRepository
+	planFeatureRepo planfeatures.PlanFeatureRepository
 }
 
 func NewTenantPlanService(
 	repo TenantPlanRepository,
 	projectRepo projects.ProjectRepository,
 	planRepo plans.PlanRepository,
+	planFeatureRepo planfeatures.PlanFeatureRepository,
 ) TenantPlanService {
 	return &tenantPlanService{
-		repo:        repo,
-		projectRepo: projectRepo,
-		planRepo:    planRepo,
+		repo:            repo,
+		projectRepo:     projectRepo,
+		planRepo:        planRepo,
+		planFeatureRepo: planFeatureRepo,
 	}
 }
 
@@ -54,8 +79,15 @@ func (s *tenantPlanService) CreateAssignment(ctx context.Context, tenantID uuid.
 		return nil, errors.New("plan code is required")
 	}
 
-	// (Validaciones futuras con BD: validar projectCode y planCode)
-	// Por ahora solo validaciones internas:
+	// project_code y plan_code llevan los ids
+	projectID, err := uuid.Parse(req.ProjectCode)
+	if err != nil {
+		return nil, errors.New("invalid project_code")
+	}
+	planID, err := uuid.Parse(req.PlanCode)
+	if err != nil {
+		return nil, errors.New("invalid plan_code")
+	}
 
 	// tenant can have only one plan per project
 	assignments, err := s.repo.ListByTenant(ctx, tenantID)
@@ -63,58 +95,266 @@ func (s *tenantPlanService) CreateAssignment(ctx context.Context, tenantID uuid.
 		return nil, err
 	}
 	for _, a := range assignments {
-		if a.ProjectID.String() == req.ProjectCode {
+		if a.ProjectID == projectID {
 			return nil, errors.New("project already assigned")
 		}
 	}
 
-	return s.repo.Create(ctx, tenantID, req)
+	if err := s.checkAssignable(ctx, tenantID, projectID, planID); err != nil {
+		return nil, err
+	}
+	return s.repo.Create(ctx, tenantID, req, auth.ActorFromContext(ctx))
 }
 
 func (s *tenantPlanService) UpdateAssignment(ctx context.Context, tenantID uuid.UUID, assignmentID uuid.UUID, req UpdateTenantPlanRequest) (*TenantPlanResponse, error) {
 	// ignore project changes (not allowed yet)
-	return s.repo.Update(ctx, tenantID, assignmentID, req)
+	if req.PlanCode != nil {
+		planID, err := uuid.Parse(*req.PlanCode)
+		if err != nil {
+			return nil, errors.New("invalid plan_code")
+		}
+		cur, err := s.repo.GetByID(ctx, assignmentID)
+		if err != nil {
+			return nil, err
+		}
+		if cur.TenantID != tenantID {
+			return nil, errors.New("tenant plan not found")
+		}
+		if err := s.checkAssignable(ctx, tenantID, cur.ProjectID, planID); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.Update(ctx, tenantID, assignmentID, req, auth.ActorFromContext(ctx))
 }
 
-// GetTenantPlan devuelve la asignación efectiva del tenant para el proyecto o el plan por defecto
+// ListHistory devuelve el historial de asignaciones del tenant, opcionalmente
+// de un solo proyecto
+func (s *tenantPlanService) ListHistory(ctx context.Context, tenantID uuid.UUID, projectID *uuid.UUID) ([]HistoryResponse, error) {
+	return s.repo.ListHistory(ctx, tenantID, projectID)
+}
+
+// MigrateVersion mueve a la versión destino todos los tenants del proyecto
+// fijados a la versión origen. El destino tiene que estar publicado; puede ser
+// de otro plan del proyecto.
+func (s *tenantPlanService) MigrateVersion(ctx context.Context, projectID uuid.UUID, req MigrateVersionRequest) (*MigrateVersionResponse, error) {
+	if req.FromVersionID == uuid.Nil {
+		return nil, errors.New("from_version_id is required")
+	}
+	if req.ToVersionID == uuid.Nil {
+		return nil, errors.New("to_version_id is required")
+	}
+	if req.FromVersionID == req.ToVersionID {
+		return nil, errors.New("to_version_id must differ from from_version_id")
+	}
+	if _, err := s.planFeatureRepo.GetVersion(ctx, projectID, req.FromVersionID); err != nil {
+		return nil, err
+	}
+	to, err := s.planFeatureRepo.GetVersion(ctx, projectID, req.ToVersionID)
+	if err != nil {
+		return nil, err
+	}
+	if to.Status != planfeatures.VersionPublished {
+		return nil, errors.New("target version must be published")
+	}
+	// los tenants migrados no pueden acabar en un plan draft o retired
+	p, err := s.planRepo.GetByID(ctx, projectID, to.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == plans.StatusDraft || p.Status == plans.StatusRetired {
+		return nil, fmt.Errorf("target plan is %s", p.Status)
+	}
+
+	n, err := s.repo.MigrateVersion(ctx, projectID, req.FromVersionID, req.ToVersionID, auth.ActorFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return &MigrateVersionResponse{
+		FromVersionID: req.FromVersionID,
+		ToVersionID:   req.ToVersionID,
+		Migrated:      n,
+	}, nil
+}
+
+// GetTenantPlan devuelve la asignación efectiva del tenant para el proyecto o
+// el plan por defecto. Un trial vencido que el finalizador aún no ha procesado
+// ya resuelve a su plan de fallback.
 func (s *tenantPlanService) GetTenantPlan(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (*TenantPlanResponse, error) {
 	// try explicit assignment
 	if tp, err := s.repo.GetByTenantAndProject(ctx, tenantID, projectID); err == nil {
+		if tp.TrialEndsAt == nil || tp.TrialEndsAt.After(time.Now()) {
+			return tp, nil
+		}
+		// la versión fijada era la del plan del trial
+		tp.PlanVersionID = nil
+		if tp.FallbackPlanID != nil {
+			tp.PlanID = *tp.FallbackPlanID
+			return tp, nil
+		}
+		planID, err := s.defaultPlanID(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+		tp.PlanID = planID
 		return tp, nil
 	}
 	// else fallback to default plan for project
-	plans, err := s.planRepo.List(ctx, projectID)
+	planID, err := s.defaultPlanID(ctx, projectID)
 	if err != nil {
 		return nil, err
 	}
+	// return a synthetic assignment (not persisted)
+	res := TenantPlanResponse{
+		ID:        uuid.Nil,
+		TenantID:  tenantID,
+		ProjectID: projectID,
+		PlanID:    planID,
+	}
+	return &res, nil
+}
+
+func (s *tenantPlanService) defaultPlanID(ctx context.Context, projectID uuid.UUID) (uuid.UUID, error) {
+	plans, err := s.planRepo.List(ctx, projectID, nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
 	for _, p := range plans {
 		if p.IsDefault {
-			// return a synthetic assignment (not persisted)
-			res := TenantPlanResponse{
-				ID:        uuid.Nil,
-				TenantID:  tenantID,
-				ProjectID: projectID,
-				PlanID:    p.ID,
-			}
-			return &res, nil
+			return p.ID, nil
 		}
 	}
-	return nil, errors.New("no plan available")
+	return uuid.Nil, errors.New("no plan available")
 }
 
-// AssignTenantPlan asigna o actualiza la asignación del tenant para el proyecto
-func (s *tenantPlanService) AssignTenantPlan(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID) (*TenantPlanResponse, error) {
+// AssignTenantPlan asigna o actualiza la asignación del tenant para el
+// proyecto. Con trial el plan dura hasta trial.EndsAt.
+func (s *tenantPlanService) AssignTenantPlan(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID, cycleAnchor *time.Time, trial *Trial) (*TenantPlanResponse, error) {
 	// validate project exists
 	if _, err := s.projectRepo.GetByID(ctx, projectID); err != nil {
 		return nil, errors.New("project not found")
 	}
+	if err := s.checkAssignable(ctx, tenantID, projectID, planID); err != nil {
+		return nil, err
+	}
+	if trial != nil {
+		if !trial.EndsAt.After(time.Now()) {
+			return nil, errors.New("trial_ends_at must be in the future")
+		}
+		if trial.FallbackPlanID != nil {
+			fp, err := s.planRepo.GetByID(ctx, projectID, *trial.FallbackPlanID)
+			if err != nil {
+				return nil, errors.New("fallback plan not found")
+			}
+			if err := assignable(fp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.repo.UpsertByTenantAndProject(ctx, tenantID, projectID, planID, cycleAnchor, trial, auth.ActorFromContext(ctx))
+}
+
+// checkAssignable valida que el tenant pueda pasar a planID en el proyecto:
+// el plan tiene que ser active, o legacy si el tenant ya lo tiene. Todas las
+// rutas que asignan un plan pasan por aquí.
+func (s *tenantPlanService) checkAssignable(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, planID uuid.UUID) error {
 	// validate plan exists and belongs to project
 	p, err := s.planRepo.GetByID(ctx, projectID, planID)
 	if err != nil {
-		return nil, errors.New("plan not found")
+		return errors.New("plan not found")
 	}
 	if p.ProjectID != projectID {
-		return nil, errors.New("plan does not belong to project")
+		return errors.New("plan does not belong to project")
+	}
+	// un plan legacy solo vale para quien ya lo tiene
+	if p.Status == plans.StatusLegacy {
+		cur, err := s.repo.GetByTenantAndProject(ctx, tenantID, projectID)
+		if err != nil && err.Error() != "tenant plan not found" {
+			return err
+		}
+		if cur == nil || cur.PlanID != planID {
+			return errors.New("plan is legacy and cannot be newly assigned")
+		}
+		return nil
+	}
+	return assignable(p)
+}
+
+// assignable: solo los planes active admiten asignaciones nuevas
+func assignable(p *plans.PlanResponse) error {
+	if p.Status != plans.StatusActive {
+		return fmt.Errorf("plan is %s and cannot be newly assigned", p.Status)
+	}
+	return nil
+}
+
+// FinalizeExpiredTrials pasa los trials vencidos a su plan de fallback, por
+// lotes, hasta que no quede ninguno
+func (s *tenantPlanService) FinalizeExpiredTrials(ctx context.Context) error {
+	for {
+		transitions, err := s.repo.FinalizeExpiredTrials(ctx, trialBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, t := range transitions {
+			to := "none"
+			if t.ToPlanID != nil {
+				to = t.ToPlanID.String()
+			}
+			log.Printf("trial ended: tenant %s project %s plan %s -> %s", t.TenantID, t.ProjectID, t.FromPlanID, to)
+		}
+		if len(transitions) < trialBatchSize {
+			return nil
+		}
+	}
+}
+
+// ScheduleChange programa el paso del tenant a req.PlanID en effective_at.
+// Hasta entonces la asignación actual no cambia.
+func (s *tenantPlanService) ScheduleChange(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req ScheduleChangeRequest) (*PlanChangeResponse, error) {
+	if req.PlanID == uuid.Nil {
+		return nil, errors.New("plan_id is required")
+	}
+	if req.EffectiveAt == nil {
+		return nil, errors.New("effective_at is required")
+	}
+	if !req.EffectiveAt.After(time.Now()) {
+		return nil, errors.New("effective_at must be in the future")
+	}
+	p, err := s.planRepo.GetByID(ctx, projectID, req.PlanID)
+	if err != nil {
+		return nil, errors.New("plan not found")
+	}
+	if err := assignable(p); err != nil {
+		return nil, err
+	}
+	return s.repo.CreateChange(ctx, tenantID, projectID, req.PlanID, *req.EffectiveAt, auth.ActorFromContext(ctx))
+}
+
+func (s *tenantPlanService) ListChanges(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) ([]PlanChangeResponse, error) {
+	return s.repo.ListChanges(ctx, tenantID, projectID)
+}
+
+func (s *tenantPlanService) CancelChange(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, changeID uuid.UUID) (*PlanChangeResponse, error) {
+	return s.repo.CancelChange(ctx, tenantID, projectID, changeID)
+}
+
+// ApplyScheduledChanges aplica los cambios de plan vencidos, por lotes,
+// hasta que no quede ninguno
+func (s *tenantPlanService) ApplyScheduledChanges(ctx context.Context) error {
+	for {
+		transitions, err := s.repo.ApplyDueChanges(ctx, changeBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, t := range transitions {
+			from := "none"
+			if t.FromPlanID != nil {
+				from = t.FromPlanID.String()
+			}
+			log.Printf("scheduled plan change applied: tenant %s project %s plan %s -> %s", t.TenantID, t.ProjectID, from, t.ToPlanID)
+		}
+		if len(transitions) < changeBatchSize {
+			return nil
+		}
 	}
-	return s.repo.UpsertByTenantAndProject(ctx, tenantID, projectID, planID)
 }
@@ -0,0 +1,409 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"plans-features/internal/auth"
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type UsageHandler struct {
+	service UsageService
+}
+
+func NewUsageHandler(s UsageService) *UsageHandler {
+	return &UsageHandler{service: s}
+}
+
+// errorStatus traduce los errores del servicio a códigos HTTP
+func errorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "feature not found", msg == "reservation not found":
+		return http.StatusNotFound
+	case msg == "reservation is not pending", msg == "reservation expired",
+		msg == "idempotency_key already used":
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "amount must"),
+		strings.HasPrefix(msg, "kind must"),
+		strings.HasPrefix(msg, "ttl_seconds must"),
+		strings.HasPrefix(msg, "limit must"),
+		strings.HasSuffix(msg, "is required"),
+		strings.HasPrefix(msg, "usage can only"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// tenantAndProject lee el tenant de la ruta y el proyecto de la API key
+func tenantAndProject(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return uuid.Nil, uuid.Nil, false
+	}
+	tenantID, err := uuid.Parse(chi.URLParam(r, "tenantId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, projectID, true
+}
+
+// RecordUsage godoc
+// @Summary Record usage of a numeric feature
+// @Description Records a usage event for the tenant: kind=increment (default) adds amount, kind=gauge sets the absolute value. The raw event is stored and the tenant/feature/period aggregate updated. A repeated idempotency_key is not counted twice; reusing it for the same feature with a different kind or amount returns 409.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param body body usage.RecordUsageRequest true "Usage event"
+// @Success 200 {object} usage.UsageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/usage [post]
+func (h *UsageHandler) RecordUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	var req RecordUsageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.RecordUsage(r.Context(), tenantID, projectID, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// ListUsage godoc
+// @Summary List usage of a tenant
+// @Description Returns used, limit and remaining for every numeric feature of the tenant's entitlements or with recorded usage
+// @Tags usage
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Success 200 {array} usage.UsageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/usage [get]
+func (h *UsageHandler) ListUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	list, err := h.service.ListUsage(r.Context(), tenantID, projectID)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// GetUsage godoc
+// @Summary Get usage of one numeric feature
+// @Description Returns used, limit (the tenant's effective numeric value) and remaining for the feature
+// @Tags usage
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param featureCode path string true "Feature code"
+// @Success 200 {object} usage.UsageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/usage/{featureCode} [get]
+func (h *UsageHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.service.GetUsage(r.Context(), tenantID, projectID, chi.URLParam(r, "featureCode"))
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// quotaExceeded responde 409 con la cuota que queda (0 si no hay límite
+// numérico) y, si la feature se reinicia, cuándo vuelve a haber cuota
+func quotaExceeded(w http.ResponseWriter, remaining *float64, resetsAt *time.Time) {
+	var left float64
+	if remaining != nil {
+		left = *remaining
+	}
+	body := map[string]interface{}{
+		"error":     ErrQuotaExceeded.Error(),
+		"remaining": left,
+	}
+	if resetsAt != nil {
+		body["resets_at"] = resetsAt
+	}
+	utils.JSON(w, http.StatusConflict, body)
+}
+
+// reservationParams lee tenant, proyecto y reserva de la petición
+func reservationParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, uuid.UUID, bool) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "reservationId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid reservation ID")
+		return uuid.Nil, uuid.Nil, uuid.Nil, false
+	}
+	return tenantID, projectID, id, true
+}
+
+// ConsumeUsage godoc
+// @Summary Atomically check and consume quota
+// @Description Debits amount from the tenant's remaining quota for the numeric feature. With enforcement=hard (default) it only succeeds if it fits (used + pending reservations + amount <= limit) and returns 409 with the remaining amount (and resets_at for features with a reset period) otherwise; soft accepts it and records the overage, unlimited never checks. Concurrent calls from any replica are serialized per tenant/feature. A repeated idempotency_key is not debited twice; reusing it for the same feature with a different kind or amount returns 409.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param featureCode path string true "Feature code"
+// @Param body body usage.ConsumeRequest true "Amount to consume"
+// @Success 200 {object} usage.UsageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/features/{featureCode}/consume [post]
+func (h *UsageHandler) ConsumeUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	var req ConsumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.ConsumeUsage(r.Context(), tenantID, projectID, chi.URLParam(r, "featureCode"), req)
+	if errors.Is(err, ErrQuotaExceeded) {
+		quotaExceeded(w, res.Remaining, res.ResetsAt)
+		return
+	}
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// ReserveUsage godoc
+// @Summary Reserve quota for a long-running operation
+// @Description Sets amount aside from the tenant's quota until the reservation is committed, released or expires (ttl_seconds, 300 by default, max 86400). Returns 409 with the remaining amount if it does not fit and the plan enforces a hard limit. A repeated idempotency_key returns the original reservation; reusing it with a different amount returns 409.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param featureCode path string true "Feature code"
+// @Param body body usage.ReserveRequest true "Reservation"
+// @Success 201 {object} usage.ReservationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/features/{featureCode}/reservations [post]
+func (h *UsageHandler) ReserveUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, ok := tenantAndProject(w, r)
+	if !ok {
+		return
+	}
+	var req ReserveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.ReserveUsage(r.Context(), tenantID, projectID, chi.URLParam(r, "featureCode"), req)
+	if errors.Is(err, ErrQuotaExceeded) {
+		quotaExceeded(w, res.Remaining, res.ResetsAt)
+		return
+	}
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusCreated, res)
+}
+
+// GetReservation godoc
+// @Summary Get a quota reservation
+// @Description status is pending, committed, released or expired (pending past its expires_at)
+// @Tags usage
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param reservationId path string true "Reservation ID"
+// @Success 200 {object} usage.ReservationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/reservations/{reservationId} [get]
+func (h *UsageHandler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, id, ok := reservationParams(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.service.GetReservation(r.Context(), tenantID, projectID, id)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// CommitReservation godoc
+// @Summary Commit a quota reservation
+// @Description Turns a pending, unexpired reservation into usage. amount (optional) commits less than reserved and releases the rest. 409 if the reservation is not pending or has expired.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param reservationId path string true "Reservation ID"
+// @Param body body usage.CommitReservationRequest false "Amount actually used"
+// @Success 200 {object} usage.ReservationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/reservations/{reservationId}/commit [post]
+func (h *UsageHandler) CommitReservation(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, id, ok := reservationParams(w, r)
+	if !ok {
+		return
+	}
+	var req CommitReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.service.CommitReservation(r.Context(), tenantID, projectID, id, req)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// ReleaseReservation godoc
+// @Summary Release a quota reservation
+// @Description Frees a pending reservation without recording usage. 409 if it was already committed or released.
+// @Tags usage
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenantId path string true "Tenant ID"
+// @Param reservationId path string true "Reservation ID"
+// @Success 200 {object} usage.ReservationResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/tenants/{tenantId}/reservations/{reservationId}/release [post]
+func (h *UsageHandler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	tenantID, projectID, id, ok := reservationParams(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.service.ReleaseReservation(r.Context(), tenantID, projectID, id)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// ListAlerts godoc
+// @Summary List usage threshold alerts
+// @Description Alerts emitted when a tenant's usage crosses one of the alert_thresholds (percent of the limit) of its plan-feature assignment. Each threshold fires once per tenant and reset period. Oldest first, so since can be used to poll for new alerts.
+// @Tags usage
+// @Produce json
+// @Param X-API-Key header string true "API Key"
+// @Param tenant_id query string false "Filter by tenant ID"
+// @Param since query string false "Only alerts created after this time (RFC3339)"
+// @Param limit query int false "Max items (default 100, max 1000)"
+// @Success 200 {array} usage.AlertResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/usage/alerts [get]
+func (h *UsageHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	projectID, ok := auth.ProjectIDFromContext(r.Context())
+	if !ok {
+		utils.Error(w, http.StatusUnauthorized, "missing project context")
+		return
+	}
+	var filter AlertFilter
+	q := r.URL.Query()
+	if s := q.Get("tenant_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid tenant ID")
+			return
+		}
+		filter.TenantID = &id
+	}
+	if s := q.Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "since must be RFC3339")
+			return
+		}
+		filter.Since = &t
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			utils.Error(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = n
+	}
+	list, err := h.service.ListAlerts(r.Context(), projectID, filter)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
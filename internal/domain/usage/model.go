@@ -0,0 +1,184 @@
+package usage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tipos de evento de uso
+const (
+	KindIncrement = "increment" // suma amount al uso del periodo
+	KindGauge     = "gauge"     // fija el uso del periodo al valor absoluto amount
+)
+
+// Event es un evento de uso en bruto (tabla usage_events)
+type Event struct {
+	ID             uuid.UUID `db:"id"`
+	TenantID       uuid.UUID `db:"tenant_id"`
+	ProjectID      uuid.UUID `db:"project_id"`
+	FeatureID      uuid.UUID `db:"feature_id"`
+	Kind           string    `db:"kind"`
+	Amount         float64   `db:"amount"`
+	PeriodStart    time.Time `db:"period_start"`
+	IdempotencyKey *string   `db:"idempotency_key"`
+	OccurredAt     time.Time `db:"occurred_at"`
+	// Overage: parte del evento por encima del límite (la calcula el repositorio)
+	Overage float64 `db:"overage"`
+}
+
+// RecordUsageRequest: kind vacío = increment. Con idempotency_key un reintento
+// no vuelve a contar el evento.
+type RecordUsageRequest struct {
+	FeatureCode    string     `json:"feature_code"`
+	Kind           string     `json:"kind,omitempty"`
+	Amount         float64    `json:"amount"`
+	IdempotencyKey *string    `json:"idempotency_key,omitempty"`
+	OccurredAt     *time.Time `json:"occurred_at,omitempty"`
+}
+
+// Quota es el uso agregado del periodo más las reservas vivas
+type Quota struct {
+	Used     float64
+	Reserved float64
+}
+
+// UsageResponse: limit es el valor numérico efectivo de la feature para el
+// tenant (nil = sin límite); remaining = limit - used - reserved, nunca negativo.
+// resets_at es el fin de la ventana actual (nil si la feature no se reinicia).
+// Con enforcement unlimited limit y remaining son nil; overage es el uso por
+// encima del límite (posible con soft o con uso registrado a posteriori).
+type UsageResponse struct {
+	TenantID    uuid.UUID  `json:"tenant_id"`
+	FeatureCode string     `json:"feature_code"`
+	Used        float64    `json:"used"`
+	Reserved    float64    `json:"reserved"`
+	Limit       *float64   `json:"limit"`
+	Remaining   *float64   `json:"remaining"`
+	Overage     float64    `json:"overage"`
+	Enforcement string     `json:"enforcement,omitempty"`
+	PeriodStart time.Time  `json:"period_start"`
+	ResetsAt    *time.Time `json:"resets_at"`
+}
+
+// ConsumeRequest descuenta amount de la cuota solo si cabe entero
+type ConsumeRequest struct {
+	Amount         float64 `json:"amount"`
+	IdempotencyKey *string `json:"idempotency_key,omitempty"`
+}
+
+// Estados de una reserva. expired no se guarda: es una reserva pending
+// cuyo expires_at ya pasó.
+const (
+	ReservationPending   = "pending"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation es una reserva de cuota (tabla usage_reservations)
+type Reservation struct {
+	ID             uuid.UUID `db:"id"`
+	TenantID       uuid.UUID `db:"tenant_id"`
+	ProjectID      uuid.UUID `db:"project_id"`
+	FeatureID      uuid.UUID `db:"feature_id"`
+	FeatureCode    string    `db:"feature_code"`
+	PeriodStart    time.Time `db:"period_start"`
+	Amount         float64   `db:"amount"`
+	Status         string    `db:"status"`
+	ExpiresAt      time.Time `db:"expires_at"`
+	IdempotencyKey *string   `db:"idempotency_key"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+// ReserveRequest: ttl_seconds vacío = 5 minutos; con idempotency_key un
+// reintento devuelve la reserva ya creada
+type ReserveRequest struct {
+	Amount         float64 `json:"amount"`
+	TTLSeconds     int     `json:"ttl_seconds,omitempty"`
+	IdempotencyKey *string `json:"idempotency_key,omitempty"`
+}
+
+// CommitReservationRequest: amount opcional para confirmar menos de lo
+// reservado; el resto se libera
+type CommitReservationRequest struct {
+	Amount *float64 `json:"amount,omitempty"`
+}
+
+// ReservationResponse: remaining es la cuota que queda tras la operación
+type ReservationResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	TenantID    uuid.UUID  `json:"tenant_id"`
+	FeatureCode string     `json:"feature_code"`
+	Amount      float64    `json:"amount"`
+	Status      string     `json:"status"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Remaining   *float64   `json:"remaining,omitempty"`
+	ResetsAt    *time.Time `json:"resets_at,omitempty"`
+}
+
+// ToResponse convierte la reserva; una pending caducada se muestra como expired
+func ToResponse(r *Reservation, now time.Time) *ReservationResponse {
+	status := r.Status
+	if status == ReservationPending && !r.ExpiresAt.After(now) {
+		status = ReservationExpired
+	}
+	return &ReservationResponse{
+		ID:          r.ID,
+		TenantID:    r.TenantID,
+		FeatureCode: r.FeatureCode,
+		Amount:      r.Amount,
+		Status:      status,
+		ExpiresAt:   r.ExpiresAt,
+		CreatedAt:   r.CreatedAt,
+	}
+}
+
+// Alert es un umbral de alerta cruzado (tabla usage_alerts); se registra una
+// sola vez por tenant/feature/periodo/umbral
+type Alert struct {
+	ID          uuid.UUID `db:"id"`
+	TenantID    uuid.UUID `db:"tenant_id"`
+	ProjectID   uuid.UUID `db:"project_id"`
+	FeatureID   uuid.UUID `db:"feature_id"`
+	FeatureCode string    `db:"feature_code"`
+	PeriodStart time.Time `db:"period_start"`
+	Threshold   float64   `db:"threshold"`
+	Used        float64   `db:"used"`
+	Limit       float64   `db:"limit_value"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// AlertResponse: threshold es el porcentaje del límite que se cruzó
+type AlertResponse struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenant_id"`
+	FeatureCode string    `json:"feature_code"`
+	PeriodStart time.Time `json:"period_start"`
+	Threshold   float64   `json:"threshold"`
+	Used        float64   `json:"used"`
+	Limit       float64   `json:"limit"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func ToAlertResponse(a *Alert) *AlertResponse {
+	return &AlertResponse{
+		ID:          a.ID,
+		TenantID:    a.TenantID,
+		FeatureCode: a.FeatureCode,
+		PeriodStart: a.PeriodStart,
+		Threshold:   a.Threshold,
+		Used:        a.Used,
+		Limit:       a.Limit,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+// AlertFilter: TenantID nil = todos los tenants del proyecto; Since filtra por created_at
+type AlertFilter struct {
+	TenantID *uuid.UUID
+	Since    *time.Time
+	Limit    int
+}
@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"time"
+
+	"plans-features/internal/domain/features"
+)
+
+// lifetimePeriod: las features sin reset acumulan todo el uso en un único periodo
+var lifetimePeriod = time.Unix(0, 0).UTC()
+
+// periodBounds devuelve el inicio y el fin de la ventana de reset que contiene
+// now. Las ventanas empiezan a medianoche en loc; la semanal, la mensual y la
+// anual arrancan en el día de la semana / del mes / del año de anchor (el
+// ciclo del tenant) o, sin ancla, el lunes, el día 1 y el 1 de enero.
+// Con reset none devuelve lifetimePeriod y un fin cero (nunca se reinicia).
+func periodBounds(reset string, anchor *time.Time, loc *time.Location, now time.Time) (time.Time, time.Time) {
+	now = now.In(loc)
+	var a time.Time
+	if anchor != nil {
+		a = anchor.In(loc)
+	}
+
+	switch reset {
+	// el fin se calcula desde la fecha y no con start.AddDate: si la medianoche
+	// no existe en loc (cambio de hora a las 00:00) time.Date la normaliza al
+	// día anterior y AddDate arrastraría ese desfase
+	case features.ResetDaily:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		return start, time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
+
+	case features.ResetWeekly:
+		weekday := time.Monday
+		if anchor != nil {
+			weekday = a.Weekday()
+		}
+		offset := (int(now.Weekday()) - int(weekday) + 7) % 7
+		start := time.Date(now.Year(), now.Month(), now.Day()-offset, 0, 0, 0, 0, loc)
+		return start, time.Date(now.Year(), now.Month(), now.Day()-offset+7, 0, 0, 0, 0, loc)
+
+	case features.ResetMonthly:
+		day := 1
+		if anchor != nil {
+			day = a.Day()
+		}
+		start := monthDay(now.Year(), now.Month(), day, loc)
+		if start.After(now) {
+			start = monthDay(now.Year(), now.Month()-1, day, loc)
+		}
+		return start, monthDay(start.Year(), start.Month()+1, day, loc)
+
+	case features.ResetYearly:
+		month, day := time.January, 1
+		if anchor != nil {
+			month, day = a.Month(), a.Day()
+		}
+		start := monthDay(now.Year(), month, day, loc)
+		if start.After(now) {
+			start = monthDay(now.Year()-1, month, day, loc)
+		}
+		return start, monthDay(start.Year()+1, month, day, loc)
+
+	default:
+		return lifetimePeriod, time.Time{}
+	}
+}
+
+// monthDay es la medianoche del día day del mes, recortado al último día
+// cuando el mes es más corto (un ancla el 31 cae el 30, o el 28/29 en febrero)
+func monthDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, loc)
+}
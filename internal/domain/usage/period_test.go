@@ -0,0 +1,194 @@
+package usage
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // zonas IANA aunque el sistema no tenga zoneinfo
+
+	"plans-features/internal/domain/features"
+)
+
+func mustLoc(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("LoadLocation(%s): %v", name, err)
+	}
+	return loc
+}
+
+func at(loc *time.Location, year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, loc)
+}
+
+func TestPeriodBounds(t *testing.T) {
+	utc := time.UTC
+	madrid := mustLoc(t, "Europe/Madrid")
+	newYork := mustLoc(t, "America/New_York")
+	tokyo := mustLoc(t, "Asia/Tokyo")
+
+	anchor := func(tm time.Time) *time.Time { return &tm }
+
+	tests := []struct {
+		name      string
+		reset     string
+		anchor    *time.Time
+		loc       *time.Location
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		// diario
+		{"daily", features.ResetDaily, nil, utc,
+			at(utc, 2026, 3, 10, 15, 4), at(utc, 2026, 3, 10, 0, 0), at(utc, 2026, 3, 11, 0, 0)},
+		{"daily at midnight", features.ResetDaily, nil, utc,
+			at(utc, 2026, 3, 10, 0, 0), at(utc, 2026, 3, 10, 0, 0), at(utc, 2026, 3, 11, 0, 0)},
+		{"daily in loc from utc instant", features.ResetDaily, nil, tokyo,
+			at(utc, 2026, 3, 10, 20, 0), at(tokyo, 2026, 3, 11, 0, 0), at(tokyo, 2026, 3, 12, 0, 0)},
+		{"daily spring forward is 23h", features.ResetDaily, nil, madrid,
+			at(madrid, 2026, 3, 29, 12, 0), at(madrid, 2026, 3, 29, 0, 0), at(madrid, 2026, 3, 30, 0, 0)},
+		{"daily fall back is 25h", features.ResetDaily, nil, newYork,
+			at(newYork, 2026, 11, 1, 1, 30), at(newYork, 2026, 11, 1, 0, 0), at(newYork, 2026, 11, 2, 0, 0)},
+
+		// semanal
+		{"weekly starts monday", features.ResetWeekly, nil, utc,
+			at(utc, 2026, 3, 12, 9, 0), at(utc, 2026, 3, 9, 0, 0), at(utc, 2026, 3, 16, 0, 0)},
+		{"weekly sunday belongs to previous monday", features.ResetWeekly, nil, utc,
+			at(utc, 2026, 3, 15, 23, 59), at(utc, 2026, 3, 9, 0, 0), at(utc, 2026, 3, 16, 0, 0)},
+		{"weekly anchored wednesday", features.ResetWeekly, anchor(at(utc, 2025, 1, 1, 8, 0)), utc,
+			at(utc, 2026, 3, 10, 9, 0), at(utc, 2026, 3, 4, 0, 0), at(utc, 2026, 3, 11, 0, 0)},
+		{"weekly across month and year", features.ResetWeekly, nil, utc,
+			at(utc, 2027, 1, 2, 9, 0), at(utc, 2026, 12, 28, 0, 0), at(utc, 2027, 1, 4, 0, 0)},
+		{"weekly across dst", features.ResetWeekly, nil, madrid,
+			at(madrid, 2026, 3, 30, 9, 0), at(madrid, 2026, 3, 30, 0, 0), at(madrid, 2026, 4, 6, 0, 0)},
+
+		// mensual
+		{"monthly without anchor", features.ResetMonthly, nil, utc,
+			at(utc, 2026, 3, 10, 9, 0), at(utc, 2026, 3, 1, 0, 0), at(utc, 2026, 4, 1, 0, 0)},
+		{"monthly anchor 15 before anchor day", features.ResetMonthly, anchor(at(utc, 2025, 6, 15, 10, 0)), utc,
+			at(utc, 2026, 3, 10, 9, 0), at(utc, 2026, 2, 15, 0, 0), at(utc, 2026, 3, 15, 0, 0)},
+		{"monthly anchor 15 on anchor day", features.ResetMonthly, anchor(at(utc, 2025, 6, 15, 10, 0)), utc,
+			at(utc, 2026, 3, 15, 0, 0), at(utc, 2026, 3, 15, 0, 0), at(utc, 2026, 4, 15, 0, 0)},
+		{"monthly anchor 31 in 30-day month", features.ResetMonthly, anchor(at(utc, 2025, 1, 31, 0, 0)), utc,
+			at(utc, 2026, 4, 30, 12, 0), at(utc, 2026, 4, 30, 0, 0), at(utc, 2026, 5, 31, 0, 0)},
+		{"monthly anchor 31 before clamped day", features.ResetMonthly, anchor(at(utc, 2025, 1, 31, 0, 0)), utc,
+			at(utc, 2026, 4, 29, 12, 0), at(utc, 2026, 3, 31, 0, 0), at(utc, 2026, 4, 30, 0, 0)},
+		{"monthly anchor 31 in february", features.ResetMonthly, anchor(at(utc, 2025, 1, 31, 0, 0)), utc,
+			at(utc, 2026, 3, 1, 12, 0), at(utc, 2026, 2, 28, 0, 0), at(utc, 2026, 3, 31, 0, 0)},
+		{"monthly anchor 30 in february", features.ResetMonthly, anchor(at(utc, 2025, 1, 30, 0, 0)), utc,
+			at(utc, 2026, 2, 10, 12, 0), at(utc, 2026, 1, 30, 0, 0), at(utc, 2026, 2, 28, 0, 0)},
+		{"monthly anchor 29 non-leap february", features.ResetMonthly, anchor(at(utc, 2025, 1, 29, 0, 0)), utc,
+			at(utc, 2026, 2, 28, 12, 0), at(utc, 2026, 2, 28, 0, 0), at(utc, 2026, 3, 29, 0, 0)},
+		{"monthly anchor 29 leap february", features.ResetMonthly, anchor(at(utc, 2025, 1, 29, 0, 0)), utc,
+			at(utc, 2028, 2, 28, 12, 0), at(utc, 2028, 1, 29, 0, 0), at(utc, 2028, 2, 29, 0, 0)},
+		{"monthly anchor 29 on leap day", features.ResetMonthly, anchor(at(utc, 2025, 1, 29, 0, 0)), utc,
+			at(utc, 2028, 2, 29, 12, 0), at(utc, 2028, 2, 29, 0, 0), at(utc, 2028, 3, 29, 0, 0)},
+		{"monthly across year", features.ResetMonthly, anchor(at(utc, 2025, 1, 20, 0, 0)), utc,
+			at(utc, 2027, 1, 5, 12, 0), at(utc, 2026, 12, 20, 0, 0), at(utc, 2027, 1, 20, 0, 0)},
+		{"monthly anchor read in loc", features.ResetMonthly, anchor(at(utc, 2025, 1, 31, 20, 0)), tokyo,
+			at(tokyo, 2026, 3, 10, 9, 0), at(tokyo, 2026, 3, 1, 0, 0), at(tokyo, 2026, 4, 1, 0, 0)},
+		{"monthly across dst", features.ResetMonthly, nil, newYork,
+			at(newYork, 2026, 3, 20, 9, 0), at(newYork, 2026, 3, 1, 0, 0), at(newYork, 2026, 4, 1, 0, 0)},
+
+		// anual
+		{"yearly without anchor", features.ResetYearly, nil, utc,
+			at(utc, 2026, 7, 4, 9, 0), at(utc, 2026, 1, 1, 0, 0), at(utc, 2027, 1, 1, 0, 0)},
+		{"yearly anchored before anchor date", features.ResetYearly, anchor(at(utc, 2024, 9, 15, 0, 0)), utc,
+			at(utc, 2026, 7, 4, 9, 0), at(utc, 2025, 9, 15, 0, 0), at(utc, 2026, 9, 15, 0, 0)},
+		{"yearly anchored after anchor date", features.ResetYearly, anchor(at(utc, 2024, 9, 15, 0, 0)), utc,
+			at(utc, 2026, 10, 1, 9, 0), at(utc, 2026, 9, 15, 0, 0), at(utc, 2027, 9, 15, 0, 0)},
+		{"yearly leap anchor in non-leap year", features.ResetYearly, anchor(at(utc, 2024, 2, 29, 0, 0)), utc,
+			at(utc, 2027, 3, 1, 9, 0), at(utc, 2027, 2, 28, 0, 0), at(utc, 2028, 2, 29, 0, 0)},
+		{"yearly leap anchor before clamped day", features.ResetYearly, anchor(at(utc, 2024, 2, 29, 0, 0)), utc,
+			at(utc, 2027, 2, 27, 9, 0), at(utc, 2026, 2, 28, 0, 0), at(utc, 2027, 2, 28, 0, 0)},
+		{"yearly leap anchor in leap year", features.ResetYearly, anchor(at(utc, 2024, 2, 29, 0, 0)), utc,
+			at(utc, 2028, 2, 29, 9, 0), at(utc, 2028, 2, 29, 0, 0), at(utc, 2029, 2, 28, 0, 0)},
+		{"yearly new year in loc", features.ResetYearly, nil, madrid,
+			at(utc, 2026, 12, 31, 23, 30), at(madrid, 2027, 1, 1, 0, 0), at(madrid, 2028, 1, 1, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := periodBounds(tt.reset, tt.anchor, tt.loc, tt.now)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("periodBounds = [%s, %s), want [%s, %s)", start, end, tt.wantStart, tt.wantEnd)
+			}
+			if tt.now.Before(start) || !tt.now.Before(end) {
+				t.Errorf("now %s outside [%s, %s)", tt.now, start, end)
+			}
+		})
+	}
+}
+
+func TestPeriodBoundsDSTLength(t *testing.T) {
+	madrid := mustLoc(t, "Europe/Madrid")
+
+	start, end := periodBounds(features.ResetDaily, nil, madrid, at(madrid, 2026, 3, 29, 12, 0))
+	if d := end.Sub(start); d != 23*time.Hour {
+		t.Errorf("spring forward day lasts %v, want 23h", d)
+	}
+	start, end = periodBounds(features.ResetDaily, nil, madrid, at(madrid, 2026, 10, 25, 12, 0))
+	if d := end.Sub(start); d != 25*time.Hour {
+		t.Errorf("fall back day lasts %v, want 25h", d)
+	}
+}
+
+// Sao Paulo cambiaba de hora a medianoche: ese día la medianoche no existe.
+// Cada instante del día tiene que caer en su ventana y la ventana siguiente
+// tiene que empezar donde acaba esta.
+func TestPeriodBoundsMissingMidnight(t *testing.T) {
+	saoPaulo := mustLoc(t, "America/Sao_Paulo")
+
+	for _, reset := range []string{features.ResetDaily, features.ResetWeekly} {
+		for _, now := range []time.Time{
+			at(saoPaulo, 2018, 11, 4, 1, 0),
+			at(saoPaulo, 2018, 11, 4, 12, 0),
+			at(saoPaulo, 2018, 11, 4, 23, 30),
+		} {
+			start, end := periodBounds(reset, nil, saoPaulo, now)
+			if now.Before(start) || !now.Before(end) {
+				t.Errorf("%s: now %s outside [%s, %s)", reset, now, start, end)
+			}
+			if want := at(saoPaulo, 2018, 11, 5, 0, 0); reset == features.ResetDaily && !end.Equal(want) {
+				t.Errorf("daily: end = %s, want %s", end, want)
+			}
+			next, _ := periodBounds(reset, nil, saoPaulo, end)
+			if !next.Equal(end) {
+				t.Errorf("%s: window after %s starts at %s", reset, end, next)
+			}
+		}
+	}
+}
+
+// Ventanas consecutivas: el fin de una es el inicio de la siguiente
+func TestPeriodBoundsContiguous(t *testing.T) {
+	utc := time.UTC
+	anchors := []time.Time{
+		at(utc, 2024, 1, 31, 0, 0),
+		at(utc, 2024, 2, 29, 0, 0),
+		at(utc, 2024, 1, 30, 0, 0),
+		at(utc, 2024, 3, 15, 0, 0),
+	}
+	for _, reset := range []string{features.ResetWeekly, features.ResetMonthly, features.ResetYearly} {
+		for _, a := range anchors {
+			a := a
+			now := at(utc, 2025, 1, 1, 0, 0)
+			_, end := periodBounds(reset, &a, utc, now)
+			for i := 0; i < 60; i++ {
+				start, next := periodBounds(reset, &a, utc, end)
+				if !start.Equal(end) {
+					t.Fatalf("%s anchor %s: window after %s starts at %s", reset, a.Format("01-02"), end, start)
+				}
+				end = next
+			}
+		}
+	}
+}
+
+func TestPeriodBoundsLifetime(t *testing.T) {
+	for _, reset := range []string{features.ResetNone, ""} {
+		start, end := periodBounds(reset, nil, time.UTC, time.Now())
+		if !start.Equal(lifetimePeriod) || !end.IsZero() {
+			t.Errorf("periodBounds(%q) = [%s, %s), want lifetime", reset, start, end)
+		}
+	}
+}
@@ -0,0 +1,605 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Los límites son float64: math.Inf(1) = sin límite. enforce=false deja pasar
+// lo que no cabe (política soft) y solo registra el exceso en usage_events.overage.
+type UsageRepository interface {
+	Record(ctx context.Context, ev Event, limit float64) (Quota, error)
+	GetQuota(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, periodStart time.Time) (Quota, error)
+	ListQuota(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, periods map[uuid.UUID]time.Time) (map[string]Quota, error)
+	CycleAnchor(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (*time.Time, error)
+	Consume(ctx context.Context, ev Event, limit float64, enforce bool) (Quota, bool, error)
+	Reserve(ctx context.Context, res Reservation, limit float64, enforce bool) (*Reservation, Quota, bool, error)
+	GetReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID) (*Reservation, error)
+	CommitReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID, amount *float64, limit float64) (*Reservation, Quota, error)
+	ReleaseReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID) (*Reservation, error)
+	RecordAlerts(ctx context.Context, alerts []Alert) ([]Alert, error)
+	ListAlerts(ctx context.Context, projectID uuid.UUID, filter AlertFilter) ([]Alert, error)
+}
+
+type usageRepository struct {
+	db *sql.DB
+}
+
+func NewUsageRepository(db *sql.DB) UsageRepository {
+	return &usageRepository{db: db}
+}
+
+const reservationColumns = `r.id, r.tenant_id, r.project_id, r.feature_id, f.code, r.period_start, r.amount, r.status, r.expires_at, r.idempotency_key, r.created_at, r.updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanReservation(row rowScanner) (*Reservation, error) {
+	var res Reservation
+	var tenantID string
+	if err := row.Scan(&res.ID, &tenantID, &res.ProjectID, &res.FeatureID, &res.FeatureCode,
+		&res.PeriodStart, &res.Amount, &res.Status, &res.ExpiresAt, &res.IdempotencyKey, &res.CreatedAt, &res.UpdatedAt); err != nil {
+		return nil, err
+	}
+	parsed, err := uuid.Parse(tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("parse reservation tenant_id: %w", err)
+	}
+	res.TenantID = parsed
+	return &res, nil
+}
+
+// queryer permite leer dentro o fuera de una transacción
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Record guarda el evento y actualiza el agregado del periodo en la misma
+// transacción; devuelve la cuota resultante. Un idempotency_key repetido no
+// cuenta de nuevo y devuelve la cuota actual (error si el evento original era
+// de otra kind o amount). El uso registrado a posteriori
+// nunca se rechaza: lo que supere limit queda como overage del evento.
+func (r *usageRepository) Record(ctx context.Context, ev Event, limit float64) (Quota, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Quota{}, fmt.Errorf("begin record usage: %w", err)
+	}
+	defer tx.Rollback()
+
+	q, err := lockQuota(ctx, tx, ev.TenantID, ev.ProjectID, ev.FeatureID, ev.PeriodStart)
+	if err != nil {
+		return Quota{}, err
+	}
+	inserted, err := insertEvent(ctx, tx, ev)
+	if err != nil {
+		return Quota{}, err
+	}
+	if !inserted {
+		// reintento: el evento ya se contó
+		if err := checkReplay(ctx, tx, ev); err != nil {
+			return Quota{}, err
+		}
+		return q, nil
+	}
+
+	var used float64
+	if ev.Kind == KindGauge {
+		// un gauge anterior al último aplicado no lo sustituye
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO usage_aggregates (tenant_id, project_id, feature_id, period_start, used, gauge_at)
+             VALUES ($1, $2, $3, $4, $5, $6)
+             ON CONFLICT (tenant_id, project_id, feature_id, period_start) DO UPDATE
+             SET used = CASE WHEN usage_aggregates.gauge_at IS NULL OR usage_aggregates.gauge_at <= EXCLUDED.gauge_at
+                             THEN EXCLUDED.used ELSE usage_aggregates.used END,
+                 gauge_at = GREATEST(usage_aggregates.gauge_at, EXCLUDED.gauge_at),
+                 updated_at = NOW()
+             RETURNING used`,
+			ev.TenantID.String(), ev.ProjectID, ev.FeatureID, ev.PeriodStart, ev.Amount, ev.OccurredAt).Scan(&used)
+	} else {
+		used, err = addUsage(ctx, tx, ev.TenantID, ev.ProjectID, ev.FeatureID, ev.PeriodStart, ev.Amount)
+	}
+	if err != nil {
+		return Quota{}, fmt.Errorf("update usage aggregate: %w", err)
+	}
+	if err := setOverage(ctx, tx, ev.ID, overage(q.Used, used, limit)); err != nil {
+		return Quota{}, err
+	}
+	q.Used = used
+
+	if err := tx.Commit(); err != nil {
+		return Quota{}, fmt.Errorf("commit record usage: %w", err)
+	}
+	return q, nil
+}
+
+// GetQuota devuelve el uso agregado del periodo (0 si no hay eventos) y las reservas vivas
+func (r *usageRepository) GetQuota(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, periodStart time.Time) (Quota, error) {
+	var q Quota
+	err := r.db.QueryRowContext(ctx,
+		`SELECT
+             COALESCE((SELECT used FROM usage_aggregates
+                       WHERE tenant_id = $1 AND project_id = $2 AND feature_id = $3 AND period_start = $4), 0),
+             COALESCE((SELECT SUM(amount) FROM usage_reservations
+                       WHERE tenant_id = $1 AND project_id = $2 AND feature_id = $3 AND period_start = $4
+                         AND status = 'pending' AND expires_at > NOW()), 0)`,
+		tenantID.String(), projectID, featureID, periodStart).Scan(&q.Used, &q.Reserved)
+	if err != nil {
+		return Quota{}, fmt.Errorf("get usage: %w", err)
+	}
+	return q, nil
+}
+
+// ListQuota devuelve la cuota de las features de periods (feature → inicio de
+// su ventana actual) que tienen uso o reservas, por código
+func (r *usageRepository) ListQuota(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, periods map[uuid.UUID]time.Time) (map[string]Quota, error) {
+	quotas := map[string]Quota{}
+	if len(periods) == 0 {
+		return quotas, nil
+	}
+	featureIDs := make([]string, 0, len(periods))
+	starts := make([]time.Time, 0, len(periods))
+	for id, start := range periods {
+		featureIDs = append(featureIDs, id.String())
+		starts = append(starts, start)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT f.code, COALESCE(a.used, 0), COALESCE(rv.reserved, 0)
+         FROM unnest($3::text[], $4::timestamptz[]) AS p(feature_id, period_start)
+         JOIN features f ON f.id = p.feature_id::uuid AND f.project_id = $2
+         LEFT JOIN usage_aggregates a
+           ON a.tenant_id = $1 AND a.project_id = $2
+          AND a.feature_id = f.id AND a.period_start = p.period_start
+         LEFT JOIN LATERAL (
+             SELECT SUM(amount) AS reserved
+             FROM usage_reservations
+             WHERE tenant_id = $1 AND project_id = $2
+               AND feature_id = f.id AND period_start = p.period_start
+               AND status = 'pending' AND expires_at > NOW()
+         ) rv ON true
+         WHERE a.feature_id IS NOT NULL OR rv.reserved IS NOT NULL`,
+		tenantID.String(), projectID, featureIDs, starts)
+	if err != nil {
+		return nil, fmt.Errorf("list usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code string
+		var q Quota
+		if err := rows.Scan(&code, &q.Used, &q.Reserved); err != nil {
+			return nil, fmt.Errorf("scan usage: %w", err)
+		}
+		quotas[code] = q
+	}
+	return quotas, rows.Err()
+}
+
+// CycleAnchor devuelve el inicio del ciclo de facturación de la asignación
+// del tenant; nil si usa el plan por defecto (ventanas de calendario)
+func (r *usageRepository) CycleAnchor(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) (*time.Time, error) {
+	var anchor time.Time
+	err := r.db.QueryRowContext(ctx,
+		`SELECT cycle_anchor FROM tenant_plans WHERE tenant_id = $1 AND project_id = $2`,
+		tenantID.String(), projectID).Scan(&anchor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get cycle anchor: %w", err)
+	}
+	return &anchor, nil
+}
+
+// Consume descuenta ev.Amount solo si used + reservas vivas + amount <= limit
+// (o siempre, sin enforce). La fila del agregado se bloquea (FOR UPDATE) para
+// serializar las llamadas concurrentes de cualquier réplica. Devuelve false,
+// sin tocar nada, si no cabe. Un reintento con el mismo idempotency_key no
+// descuenta de nuevo (error si el original era de otro amount).
+func (r *usageRepository) Consume(ctx context.Context, ev Event, limit float64, enforce bool) (Quota, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Quota{}, false, fmt.Errorf("begin consume usage: %w", err)
+	}
+	defer tx.Rollback()
+
+	q, err := lockQuota(ctx, tx, ev.TenantID, ev.ProjectID, ev.FeatureID, ev.PeriodStart)
+	if err != nil {
+		return Quota{}, false, err
+	}
+
+	inserted, err := insertEvent(ctx, tx, ev)
+	if err != nil {
+		return Quota{}, false, err
+	}
+	if !inserted {
+		// reintento de un consumo ya aplicado
+		if err := checkReplay(ctx, tx, ev); err != nil {
+			return Quota{}, false, err
+		}
+		return q, true, nil
+	}
+	if enforce && q.Used+q.Reserved+ev.Amount > limit {
+		return q, false, nil
+	}
+
+	used, err := addUsage(ctx, tx, ev.TenantID, ev.ProjectID, ev.FeatureID, ev.PeriodStart, ev.Amount)
+	if err != nil {
+		return Quota{}, false, fmt.Errorf("update usage aggregate: %w", err)
+	}
+	if err := setOverage(ctx, tx, ev.ID, overage(q.Used, used, limit)); err != nil {
+		return Quota{}, false, err
+	}
+	q.Used = used
+	if err := tx.Commit(); err != nil {
+		return Quota{}, false, fmt.Errorf("commit consume usage: %w", err)
+	}
+	return q, true, nil
+}
+
+// Reserve crea la reserva si cabe en la cuota, con el mismo bloqueo que
+// Consume. Un idempotency_key repetido devuelve la reserva ya creada, sin
+// volver a comprobar la cuota (error si era de otro amount).
+func (r *usageRepository) Reserve(ctx context.Context, res Reservation, limit float64, enforce bool) (*Reservation, Quota, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Quota{}, false, fmt.Errorf("begin reserve usage: %w", err)
+	}
+	defer tx.Rollback()
+
+	q, err := lockQuota(ctx, tx, res.TenantID, res.ProjectID, res.FeatureID, res.PeriodStart)
+	if err != nil {
+		return nil, Quota{}, false, err
+	}
+	if res.IdempotencyKey != nil {
+		prev, err := reservationByKey(ctx, tx, res)
+		if err != nil {
+			return nil, Quota{}, false, err
+		}
+		if prev != nil {
+			return prev, q, true, nil
+		}
+	}
+	if enforce && q.Used+q.Reserved+res.Amount > limit {
+		return nil, q, false, nil
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO usage_reservations (id, tenant_id, project_id, feature_id, period_start, amount, requested_amount, status, expires_at, idempotency_key)
+         VALUES ($1, $2, $3, $4, $5, $6, $6, 'pending', $7, $8)`,
+		res.ID, res.TenantID.String(), res.ProjectID, res.FeatureID, res.PeriodStart, res.Amount, res.ExpiresAt, res.IdempotencyKey)
+	if err != nil {
+		return nil, Quota{}, false, fmt.Errorf("insert usage reservation: %w", err)
+	}
+	q.Reserved += res.Amount
+
+	if err := tx.Commit(); err != nil {
+		return nil, Quota{}, false, fmt.Errorf("commit reserve usage: %w", err)
+	}
+	return &res, q, true, nil
+}
+
+func (r *usageRepository) GetReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID) (*Reservation, error) {
+	return getReservation(ctx, r.db, tenantID, projectID, id, "")
+}
+
+// CommitReservation convierte una reserva pending y vigente en uso. amount
+// (opcional) no puede superar lo reservado; la diferencia se libera. Devuelve
+// también la cuota resultante.
+func (r *usageRepository) CommitReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID, amount *float64, limit float64) (*Reservation, Quota, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Quota{}, fmt.Errorf("begin commit reservation: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := getReservation(ctx, tx, tenantID, projectID, id, "FOR UPDATE OF r")
+	if err != nil {
+		return nil, Quota{}, err
+	}
+	if res.Status != ReservationPending {
+		return nil, Quota{}, errors.New("reservation is not pending")
+	}
+	if !res.ExpiresAt.After(time.Now()) {
+		return nil, Quota{}, errors.New("reservation expired")
+	}
+	used := res.Amount
+	if amount != nil {
+		if *amount > res.Amount {
+			return nil, Quota{}, errors.New("amount must not exceed the reserved amount")
+		}
+		used = *amount
+	}
+
+	q, err := lockQuota(ctx, tx, res.TenantID, res.ProjectID, res.FeatureID, res.PeriodStart)
+	if err != nil {
+		return nil, Quota{}, err
+	}
+	// la reserva deja de contar en reserved al confirmarse
+	q.Reserved -= res.Amount
+
+	if used > 0 {
+		ev := Event{
+			ID:          uuid.New(),
+			TenantID:    res.TenantID,
+			ProjectID:   res.ProjectID,
+			FeatureID:   res.FeatureID,
+			Kind:        KindIncrement,
+			Amount:      used,
+			PeriodStart: res.PeriodStart,
+			OccurredAt:  time.Now(),
+		}
+		if _, err := insertEvent(ctx, tx, ev); err != nil {
+			return nil, Quota{}, err
+		}
+		after, err := addUsage(ctx, tx, res.TenantID, res.ProjectID, res.FeatureID, res.PeriodStart, used)
+		if err != nil {
+			return nil, Quota{}, fmt.Errorf("update usage aggregate: %w", err)
+		}
+		if err := setOverage(ctx, tx, ev.ID, overage(q.Used, after, limit)); err != nil {
+			return nil, Quota{}, err
+		}
+		q.Used = after
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`UPDATE usage_reservations SET status = 'committed', amount = $2 WHERE id = $1 RETURNING updated_at`,
+		res.ID, used).Scan(&res.UpdatedAt)
+	if err != nil {
+		return nil, Quota{}, fmt.Errorf("commit reservation: %w", err)
+	}
+	res.Status = ReservationCommitted
+	res.Amount = used
+
+	if err := tx.Commit(); err != nil {
+		return nil, Quota{}, fmt.Errorf("commit reservation: %w", err)
+	}
+	return res, q, nil
+}
+
+// ReleaseReservation libera una reserva pending (caducada o no)
+func (r *usageRepository) ReleaseReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID) (*Reservation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin release reservation: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := getReservation(ctx, tx, tenantID, projectID, id, "FOR UPDATE OF r")
+	if err != nil {
+		return nil, err
+	}
+	if res.Status != ReservationPending {
+		return nil, errors.New("reservation is not pending")
+	}
+	err = tx.QueryRowContext(ctx,
+		`UPDATE usage_reservations SET status = 'released' WHERE id = $1 RETURNING updated_at`,
+		res.ID).Scan(&res.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("release reservation: %w", err)
+	}
+	res.Status = ReservationReleased
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit release reservation: %w", err)
+	}
+	return res, nil
+}
+
+// RecordAlerts guarda los umbrales cruzados y devuelve solo los nuevos: el
+// índice único hace que cada umbral se emita una vez por tenant y periodo
+func (r *usageRepository) RecordAlerts(ctx context.Context, alerts []Alert) ([]Alert, error) {
+	var created []Alert
+	for _, a := range alerts {
+		err := r.db.QueryRowContext(ctx,
+			`INSERT INTO usage_alerts (id, tenant_id, project_id, feature_id, period_start, threshold, used, limit_value)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+             ON CONFLICT (tenant_id, project_id, feature_id, period_start, threshold) DO NOTHING
+             RETURNING created_at`,
+			a.ID, a.TenantID.String(), a.ProjectID, a.FeatureID, a.PeriodStart, a.Threshold, a.Used, a.Limit).
+			Scan(&a.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record usage alert: %w", err)
+		}
+		created = append(created, a)
+	}
+	return created, nil
+}
+
+// ListAlerts devuelve las alertas del proyecto, de la más antigua a la más reciente
+func (r *usageRepository) ListAlerts(ctx context.Context, projectID uuid.UUID, filter AlertFilter) ([]Alert, error) {
+	var tenantID *string
+	if filter.TenantID != nil {
+		s := filter.TenantID.String()
+		tenantID = &s
+	}
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT a.id, a.tenant_id, a.project_id, a.feature_id, f.code, a.period_start,
+                a.threshold, a.used, a.limit_value, a.created_at
+         FROM usage_alerts a
+         JOIN features f ON f.id = a.feature_id
+         WHERE a.project_id = $1
+           AND ($2::text IS NULL OR a.tenant_id = $2)
+           AND ($3::timestamptz IS NULL OR a.created_at > $3)
+         ORDER BY a.created_at, a.id
+         LIMIT $4`,
+		projectID, tenantID, filter.Since, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("list usage alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		var a Alert
+		var tenant string
+		if err := rows.Scan(&a.ID, &tenant, &a.ProjectID, &a.FeatureID, &a.FeatureCode, &a.PeriodStart,
+			&a.Threshold, &a.Used, &a.Limit, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan usage alert: %w", err)
+		}
+		if a.TenantID, err = uuid.Parse(tenant); err != nil {
+			return nil, fmt.Errorf("parse alert tenant_id: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func getReservation(ctx context.Context, q queryer, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID, lock string) (*Reservation, error) {
+	row := q.QueryRowContext(ctx,
+		`SELECT `+reservationColumns+`
+         FROM usage_reservations r
+         JOIN features f ON f.id = r.feature_id
+         WHERE r.id = $1 AND r.tenant_id = $2 AND r.project_id = $3 `+lock,
+		id, tenantID.String(), projectID)
+	res, err := scanReservation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("reservation not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get reservation: %w", err)
+	}
+	return res, nil
+}
+
+// lockQuota crea (si falta) y bloquea la fila del agregado y devuelve la
+// cuota actual. Todas las operaciones que comprueban el límite pasan por
+// aquí, así que quedan serializadas por tenant/feature/periodo.
+func lockQuota(ctx context.Context, tx *sql.Tx, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, periodStart time.Time) (Quota, error) {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO usage_aggregates (tenant_id, project_id, feature_id, period_start)
+         VALUES ($1, $2, $3, $4)
+         ON CONFLICT (tenant_id, project_id, feature_id, period_start) DO NOTHING`,
+		tenantID.String(), projectID, featureID, periodStart)
+	if err != nil {
+		return Quota{}, fmt.Errorf("ensure usage aggregate: %w", err)
+	}
+
+	var q Quota
+	err = tx.QueryRowContext(ctx,
+		`SELECT used FROM usage_aggregates
+         WHERE tenant_id = $1 AND project_id = $2 AND feature_id = $3 AND period_start = $4
+         FOR UPDATE`,
+		tenantID.String(), projectID, featureID, periodStart).Scan(&q.Used)
+	if err != nil {
+		return Quota{}, fmt.Errorf("lock usage aggregate: %w", err)
+	}
+	q.Reserved, err = reservedAmount(ctx, tx, tenantID, projectID, featureID, periodStart)
+	if err != nil {
+		return Quota{}, err
+	}
+	return q, nil
+}
+
+func reservedAmount(ctx context.Context, q queryer, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, periodStart time.Time) (float64, error) {
+	var reserved float64
+	err := q.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM usage_reservations
+         WHERE tenant_id = $1 AND project_id = $2 AND feature_id = $3 AND period_start = $4
+           AND status = 'pending' AND expires_at > NOW()`,
+		tenantID.String(), projectID, featureID, periodStart).Scan(&reserved)
+	if err != nil {
+		return 0, fmt.Errorf("sum usage reservations: %w", err)
+	}
+	return reserved, nil
+}
+
+// insertEvent guarda el evento; false si su idempotency_key ya existía
+func insertEvent(ctx context.Context, tx *sql.Tx, ev Event) (bool, error) {
+	var inserted bool
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO usage_events (id, tenant_id, project_id, feature_id, kind, amount, period_start, idempotency_key, occurred_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+         ON CONFLICT (project_id, tenant_id, feature_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
+         RETURNING true`,
+		ev.ID, ev.TenantID.String(), ev.ProjectID, ev.FeatureID, ev.Kind, ev.Amount,
+		ev.PeriodStart, ev.IdempotencyKey, ev.OccurredAt).Scan(&inserted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("insert usage event: %w", err)
+	}
+	return true, nil
+}
+
+// errIdempotencyKeyUsed: la clave ya se usó en esta feature para otra petición
+var errIdempotencyKeyUsed = errors.New("idempotency_key already used")
+
+// checkReplay comprueba que el evento guardado con el idempotency_key de ev
+// es la misma petición (kind y amount); si no, el reintento es un error
+func checkReplay(ctx context.Context, tx *sql.Tx, ev Event) error {
+	var kind string
+	var amount float64
+	err := tx.QueryRowContext(ctx,
+		`SELECT kind, amount FROM usage_events
+         WHERE project_id = $1 AND tenant_id = $2 AND feature_id = $3 AND idempotency_key = $4`,
+		ev.ProjectID, ev.TenantID.String(), ev.FeatureID, *ev.IdempotencyKey).Scan(&kind, &amount)
+	if err != nil {
+		return fmt.Errorf("get usage event by idempotency_key: %w", err)
+	}
+	if kind != ev.Kind || amount != ev.Amount {
+		return errIdempotencyKeyUsed
+	}
+	return nil
+}
+
+// reservationByKey devuelve la reserva con el idempotency_key de res (nil si
+// no hay). Se compara con lo que se pidió al reservar (requested_amount),
+// porque amount cambia al confirmar menos de lo reservado.
+func reservationByKey(ctx context.Context, tx *sql.Tx, res Reservation) (*Reservation, error) {
+	var id uuid.UUID
+	var requested float64
+	err := tx.QueryRowContext(ctx,
+		`SELECT id, requested_amount FROM usage_reservations
+         WHERE project_id = $1 AND tenant_id = $2 AND feature_id = $3 AND idempotency_key = $4`,
+		res.ProjectID, res.TenantID.String(), res.FeatureID, *res.IdempotencyKey).Scan(&id, &requested)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get usage reservation by idempotency_key: %w", err)
+	}
+	if requested != res.Amount {
+		return nil, errIdempotencyKeyUsed
+	}
+	return getReservation(ctx, tx, res.TenantID, res.ProjectID, id, "")
+}
+
+func addUsage(ctx context.Context, tx *sql.Tx, tenantID uuid.UUID, projectID uuid.UUID, featureID uuid.UUID, periodStart time.Time, amount float64) (float64, error) {
+	var used float64
+	err := tx.QueryRowContext(ctx,
+		`INSERT INTO usage_aggregates (tenant_id, project_id, feature_id, period_start, used)
+         VALUES ($1, $2, $3, $4, $5)
+         ON CONFLICT (tenant_id, project_id, feature_id, period_start) DO UPDATE
+         SET used = usage_aggregates.used + EXCLUDED.used,
+             updated_at = NOW()
+         RETURNING used`,
+		tenantID.String(), projectID, featureID, periodStart, amount).Scan(&used)
+	return used, err
+}
+
+// overage es la parte del paso de before a after que queda por encima de limit
+func overage(before float64, after float64, limit float64) float64 {
+	return math.Max(math.Max(after-limit, 0)-math.Max(before-limit, 0), 0)
+}
+
+func setOverage(ctx context.Context, tx *sql.Tx, eventID uuid.UUID, overage float64) error {
+	if overage <= 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE usage_events SET overage = $2 WHERE id = $1`, eventID, overage); err != nil {
+		return fmt.Errorf("set usage overage: %w", err)
+	}
+	return nil
+}
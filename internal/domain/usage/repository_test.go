@@ -0,0 +1,431 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"plans-features/internal/db/dbtest"
+	"plans-features/internal/domain/planfeatures"
+)
+
+// testPeriod: las cuotas de los tests son todas del mismo periodo
+var testPeriod = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// quotaFixture añade al fixture una feature numérica y el repositorio
+type quotaFixture struct {
+	dbtest.Fixture
+	repo      UsageRepository
+	featureID uuid.UUID
+}
+
+func newQuotaFixture(t *testing.T) quotaFixture {
+	t.Helper()
+	f := dbtest.New(t)
+	return quotaFixture{Fixture: f, repo: NewUsageRepository(f.DB), featureID: f.Feature(t, "api_calls")}
+}
+
+func (f quotaFixture) event(amount float64, key string) Event {
+	ev := Event{
+		ID:          uuid.New(),
+		TenantID:    f.TenantID,
+		ProjectID:   f.ProjectID,
+		FeatureID:   f.featureID,
+		Kind:        KindIncrement,
+		Amount:      amount,
+		PeriodStart: testPeriod,
+		OccurredAt:  time.Now(),
+	}
+	if key != "" {
+		ev.IdempotencyKey = &key
+	}
+	return ev
+}
+
+func (f quotaFixture) reservation(amount float64, ttl time.Duration) Reservation {
+	return Reservation{
+		ID:          uuid.New(),
+		TenantID:    f.TenantID,
+		ProjectID:   f.ProjectID,
+		FeatureID:   f.featureID,
+		PeriodStart: testPeriod,
+		Amount:      amount,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+}
+
+func (f quotaFixture) quota(t *testing.T) Quota {
+	t.Helper()
+	q, err := f.repo.GetQuota(context.Background(), f.TenantID, f.ProjectID, f.featureID, testPeriod)
+	if err != nil {
+		t.Fatalf("GetQuota: %v", err)
+	}
+	return q
+}
+
+func (f quotaFixture) countEvents(t *testing.T) int {
+	t.Helper()
+	return f.Count(t, `SELECT COUNT(*) FROM usage_events WHERE tenant_id = $1 AND project_id = $2`,
+		f.TenantID.String(), f.ProjectID)
+}
+
+func wantQuota(t *testing.T, got Quota, used, reserved float64) {
+	t.Helper()
+	if got.Used != used || got.Reserved != reserved {
+		t.Errorf("quota = %+v, want used=%v reserved=%v", got, used, reserved)
+	}
+}
+
+func TestReserveCommit(t *testing.T) {
+	f := newQuotaFixture(t)
+	ctx := context.Background()
+
+	res := f.reservation(6, time.Minute)
+	_, q, ok, err := f.repo.Reserve(ctx, res, 10, true)
+	if err != nil || !ok {
+		t.Fatalf("Reserve = %v, %v", ok, err)
+	}
+	wantQuota(t, q, 0, 6)
+
+	// lo reservado cuenta contra el límite
+	if _, _, ok, err := f.repo.Reserve(ctx, f.reservation(5, time.Minute), 10, true); err != nil || ok {
+		t.Fatalf("Reserve over the limit = %v, %v; want rejected", ok, err)
+	}
+
+	over := 7.0
+	if _, _, err := f.repo.CommitReservation(ctx, f.TenantID, f.ProjectID, res.ID, &over, 10); err == nil ||
+		err.Error() != "amount must not exceed the reserved amount" {
+		t.Fatalf("CommitReservation over the reserved amount: %v", err)
+	}
+
+	// confirmar menos de lo reservado libera la diferencia
+	partial := 4.0
+	committed, q, err := f.repo.CommitReservation(ctx, f.TenantID, f.ProjectID, res.ID, &partial, 10)
+	if err != nil {
+		t.Fatalf("CommitReservation: %v", err)
+	}
+	if committed.Status != ReservationCommitted || committed.Amount != 4 {
+		t.Errorf("reservation = %s/%v, want committed/4", committed.Status, committed.Amount)
+	}
+	wantQuota(t, q, 4, 0)
+	wantQuota(t, f.quota(t), 4, 0)
+
+	if _, _, err := f.repo.CommitReservation(ctx, f.TenantID, f.ProjectID, res.ID, nil, 10); err == nil ||
+		err.Error() != "reservation is not pending" {
+		t.Errorf("second CommitReservation: %v", err)
+	}
+	if _, err := f.repo.ReleaseReservation(ctx, f.TenantID, f.ProjectID, res.ID); err == nil ||
+		err.Error() != "reservation is not pending" {
+		t.Errorf("ReleaseReservation after commit: %v", err)
+	}
+
+	// sin amount se confirma todo lo reservado
+	full := f.reservation(6, time.Minute)
+	if _, _, ok, err := f.repo.Reserve(ctx, full, 10, true); err != nil || !ok {
+		t.Fatalf("Reserve = %v, %v", ok, err)
+	}
+	if _, q, err = f.repo.CommitReservation(ctx, f.TenantID, f.ProjectID, full.ID, nil, 10); err != nil {
+		t.Fatalf("CommitReservation: %v", err)
+	}
+	wantQuota(t, q, 10, 0)
+	if n := f.countEvents(t); n != 2 {
+		t.Errorf("usage events = %d, want 2", n)
+	}
+}
+
+func TestReserveRelease(t *testing.T) {
+	f := newQuotaFixture(t)
+	ctx := context.Background()
+
+	res := f.reservation(8, time.Minute)
+	if _, _, ok, err := f.repo.Reserve(ctx, res, 10, true); err != nil || !ok {
+		t.Fatalf("Reserve = %v, %v", ok, err)
+	}
+	released, err := f.repo.ReleaseReservation(ctx, f.TenantID, f.ProjectID, res.ID)
+	if err != nil {
+		t.Fatalf("ReleaseReservation: %v", err)
+	}
+	if released.Status != ReservationReleased {
+		t.Errorf("status = %s, want released", released.Status)
+	}
+	wantQuota(t, f.quota(t), 0, 0)
+
+	if _, err := f.repo.ReleaseReservation(ctx, f.TenantID, f.ProjectID, res.ID); err == nil ||
+		err.Error() != "reservation is not pending" {
+		t.Errorf("second ReleaseReservation: %v", err)
+	}
+	if _, _, err := f.repo.CommitReservation(ctx, f.TenantID, f.ProjectID, res.ID, nil, 10); err == nil ||
+		err.Error() != "reservation is not pending" {
+		t.Errorf("CommitReservation after release: %v", err)
+	}
+	if _, err := f.repo.ReleaseReservation(ctx, uuid.New(), f.ProjectID, res.ID); err == nil ||
+		err.Error() != "reservation not found" {
+		t.Errorf("ReleaseReservation from another tenant: %v", err)
+	}
+
+	// la cuota liberada vuelve a estar disponible
+	if _, ok, err := f.repo.Consume(ctx, f.event(10, ""), 10, true); err != nil || !ok {
+		t.Errorf("Consume after release = %v, %v", ok, err)
+	}
+}
+
+func TestExpiredReservation(t *testing.T) {
+	f := newQuotaFixture(t)
+	ctx := context.Background()
+
+	res := f.reservation(8, -time.Second)
+	if _, _, ok, err := f.repo.Reserve(ctx, res, 10, true); err != nil || !ok {
+		t.Fatalf("Reserve = %v, %v", ok, err)
+	}
+	// caducada: ya no cuenta ni se puede confirmar, pero sí liberar
+	wantQuota(t, f.quota(t), 0, 0)
+	if _, _, err := f.repo.CommitReservation(ctx, f.TenantID, f.ProjectID, res.ID, nil, 10); err == nil ||
+		err.Error() != "reservation expired" {
+		t.Errorf("CommitReservation: %v", err)
+	}
+	if _, err := f.repo.ReleaseReservation(ctx, f.TenantID, f.ProjectID, res.ID); err != nil {
+		t.Errorf("ReleaseReservation: %v", err)
+	}
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	f := newQuotaFixture(t)
+	ctx := context.Background()
+
+	if _, ok, err := f.repo.Consume(ctx, f.event(3, "consume-1"), 10, true); err != nil || !ok {
+		t.Fatalf("Consume = %v, %v", ok, err)
+	}
+	// el reintento trae otro id pero la misma clave
+	q, ok, err := f.repo.Consume(ctx, f.event(3, "consume-1"), 10, true)
+	if err != nil || !ok {
+		t.Fatalf("replayed Consume = %v, %v", ok, err)
+	}
+	wantQuota(t, q, 3, 0)
+
+	if _, err := f.repo.Record(ctx, f.event(2, "record-1"), 10); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	q, err = f.repo.Record(ctx, f.event(2, "record-1"), 10)
+	if err != nil {
+		t.Fatalf("replayed Record: %v", err)
+	}
+	wantQuota(t, q, 5, 0)
+
+	// la misma clave vale para Consume y Record
+	if q, ok, err = f.repo.Consume(ctx, f.event(2, "record-1"), 10, true); err != nil || !ok {
+		t.Fatalf("Consume with a recorded key = %v, %v", ok, err)
+	}
+	wantQuota(t, q, 5, 0)
+
+	if n := f.countEvents(t); n != 2 {
+		t.Errorf("usage events = %d, want 2", n)
+	}
+}
+
+func TestIdempotencyKeyScope(t *testing.T) {
+	f := newQuotaFixture(t)
+	ctx := context.Background()
+
+	if _, ok, err := f.repo.Consume(ctx, f.event(3, "op-1"), 10, true); err != nil || !ok {
+		t.Fatalf("Consume = %v, %v", ok, err)
+	}
+
+	// la misma clave en otra feature es otro evento
+	other := f.event(3, "op-1")
+	other.FeatureID = f.Feature(t, "exports")
+	q, ok, err := f.repo.Consume(ctx, other, 10, true)
+	if err != nil || !ok {
+		t.Fatalf("Consume on another feature = %v, %v", ok, err)
+	}
+	wantQuota(t, q, 3, 0)
+
+	// en la misma feature, otro amount u otro kind no es un reintento
+	gauge := f.event(3, "op-1")
+	gauge.Kind = KindGauge
+	mismatched := []struct {
+		name string
+		call func() error
+	}{
+		{"consume amount", func() error { _, _, err := f.repo.Consume(ctx, f.event(4, "op-1"), 10, true); return err }},
+		{"record amount", func() error { _, err := f.repo.Record(ctx, f.event(4, "op-1"), 10); return err }},
+		{"record kind", func() error { _, err := f.repo.Record(ctx, gauge, 10); return err }},
+	}
+	for _, tt := range mismatched {
+		if err := tt.call(); err == nil || err.Error() != "idempotency_key already used" {
+			t.Errorf("%s: err = %v, want idempotency_key already used", tt.name, err)
+		}
+	}
+	wantQuota(t, f.quota(t), 3, 0)
+	if n := f.countEvents(t); n != 2 {
+		t.Errorf("usage events = %d, want 2", n)
+	}
+}
+
+func TestReserveIdempotentReplay(t *testing.T) {
+	f := newQuotaFixture(t)
+	ctx := context.Background()
+
+	reserve := func(amount float64) (*Reservation, Quota, bool, error) {
+		res := f.reservation(amount, time.Minute)
+		key := "reserve-1"
+		res.IdempotencyKey = &key
+		return f.repo.Reserve(ctx, res, 10, true)
+	}
+	first, _, ok, err := reserve(6)
+	if err != nil || !ok {
+		t.Fatalf("Reserve = %v, %v", ok, err)
+	}
+	// el reintento devuelve la misma reserva aunque ya no cabría otra
+	replayed, q, ok, err := reserve(6)
+	if err != nil || !ok {
+		t.Fatalf("replayed Reserve = %v, %v", ok, err)
+	}
+	if replayed.ID != first.ID {
+		t.Errorf("replayed reservation %s, want %s", replayed.ID, first.ID)
+	}
+	wantQuota(t, q, 0, 6)
+
+	if _, _, _, err := reserve(2); err == nil || err.Error() != "idempotency_key already used" {
+		t.Errorf("Reserve with another amount: %v", err)
+	}
+
+	// confirmada por menos sigue reconociéndose por lo que se pidió
+	partial := 4.0
+	if _, _, err := f.repo.CommitReservation(ctx, f.TenantID, f.ProjectID, first.ID, &partial, 10); err != nil {
+		t.Fatalf("CommitReservation: %v", err)
+	}
+	replayed, _, ok, err = reserve(6)
+	if err != nil || !ok || replayed.ID != first.ID || replayed.Status != ReservationCommitted {
+		t.Errorf("Reserve after commit = %+v, %v, %v", replayed, ok, err)
+	}
+	if _, _, _, err := reserve(4); err == nil || err.Error() != "idempotency_key already used" {
+		t.Errorf("Reserve with the committed amount: %v", err)
+	}
+	wantQuota(t, f.quota(t), 4, 0)
+}
+
+func TestConsumeEnforcement(t *testing.T) {
+	five := 5.0
+	tests := []struct {
+		name        string
+		policy      quotaPolicy
+		wantOK      bool
+		wantUsed    float64
+		wantEvents  int
+		wantOverage float64
+	}{
+		{"hard rejects", quotaPolicy{limit: &five, enforcement: planfeatures.EnforcementHard}, false, 4, 1, 0},
+		{"soft records overage", quotaPolicy{limit: &five, enforcement: planfeatures.EnforcementSoft}, true, 7, 2, 2},
+		{"unlimited ignores limit", quotaPolicy{limit: &five, enforcement: planfeatures.EnforcementUnlimited}, true, 7, 2, 0},
+		{"no limit rejects", quotaPolicy{enforcement: planfeatures.EnforcementHard}, false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newQuotaFixture(t)
+			ctx := context.Background()
+			limit, enforce := tt.policy.ceiling(), tt.policy.enforce()
+
+			// el primer consumo cabe salvo que no haya límite
+			_, ok, err := f.repo.Consume(ctx, f.event(4, ""), limit, enforce)
+			if err != nil {
+				t.Fatalf("Consume: %v", err)
+			}
+			if ok != (tt.policy.limit != nil) {
+				t.Fatalf("first Consume ok = %v", ok)
+			}
+
+			ev := f.event(3, "")
+			q, ok, err := f.repo.Consume(ctx, ev, limit, enforce)
+			if err != nil {
+				t.Fatalf("Consume: %v", err)
+			}
+			if ok != tt.wantOK {
+				t.Errorf("Consume ok = %v, want %v", ok, tt.wantOK)
+			}
+			wantQuota(t, q, tt.wantUsed, 0)
+			wantQuota(t, f.quota(t), tt.wantUsed, 0)
+			if n := f.countEvents(t); n != tt.wantEvents {
+				t.Errorf("usage events = %d, want %d", n, tt.wantEvents)
+			}
+			if tt.wantOK {
+				var overage float64
+				if err := f.DB.QueryRow(`SELECT overage FROM usage_events WHERE id = $1`, ev.ID).Scan(&overage); err != nil {
+					t.Fatalf("get overage: %v", err)
+				}
+				if overage != tt.wantOverage {
+					t.Errorf("overage = %v, want %v", overage, tt.wantOverage)
+				}
+			}
+		})
+	}
+}
+
+func TestReserveHardLimit(t *testing.T) {
+	f := newQuotaFixture(t)
+	ctx := context.Background()
+
+	if _, _, ok, err := f.repo.Reserve(ctx, f.reservation(3, time.Minute), 5, true); err != nil || !ok {
+		t.Fatalf("Reserve = %v, %v", ok, err)
+	}
+	if _, ok, err := f.repo.Consume(ctx, f.event(3, ""), 5, true); err != nil || ok {
+		t.Errorf("Consume over reserved quota = %v, %v; want rejected", ok, err)
+	}
+	if _, ok, err := f.repo.Consume(ctx, f.event(2, ""), 5, true); err != nil || !ok {
+		t.Errorf("Consume within quota = %v, %v", ok, err)
+	}
+	// sin enforce la reserva se crea aunque no quepa
+	if _, _, ok, err := f.repo.Reserve(ctx, f.reservation(3, time.Minute), 5, false); err != nil || !ok {
+		t.Errorf("soft Reserve = %v, %v", ok, err)
+	}
+}
+
+func TestConcurrentConsume(t *testing.T) {
+	f := newQuotaFixture(t)
+	ctx := context.Background()
+
+	const workers, amount, limit = 24, 2.0, 15.0
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var ok bool
+			var err error
+			// mitad consumos, mitad reservas: comparten el mismo bloqueo
+			if i%2 == 0 {
+				_, ok, err = f.repo.Consume(ctx, f.event(amount, ""), limit, true)
+			} else {
+				_, _, ok, err = f.repo.Reserve(ctx, f.reservation(amount, time.Minute), limit, true)
+			}
+			if err != nil {
+				t.Errorf("worker %d: %v", i, err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if want := int(math.Floor(limit / amount)); accepted != want {
+		t.Errorf("accepted = %d, want %d", accepted, want)
+	}
+	q := f.quota(t)
+	if q.Used+q.Reserved > limit {
+		t.Errorf("quota %+v exceeds limit %v", q, limit)
+	}
+	if got := q.Used + q.Reserved; got != float64(accepted)*amount {
+		t.Errorf("used + reserved = %v, want %v", got, float64(accepted)*amount)
+	}
+}
@@ -0,0 +1,540 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"plans-features/internal/domain/entitlements"
+	"plans-features/internal/domain/features"
+	"plans-features/internal/domain/planfeatures"
+
+	"github.com/google/uuid"
+)
+
+type UsageService interface {
+	RecordUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req RecordUsageRequest) (*UsageResponse, error)
+	GetUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string) (*UsageResponse, error)
+	ListUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) ([]UsageResponse, error)
+	ConsumeUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string, req ConsumeRequest) (*UsageResponse, error)
+	ReserveUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string, req ReserveRequest) (*ReservationResponse, error)
+	GetReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID) (*ReservationResponse, error)
+	CommitReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID, req CommitReservationRequest) (*ReservationResponse, error)
+	ReleaseReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID) (*ReservationResponse, error)
+	ListAlerts(ctx context.Context, projectID uuid.UUID, filter AlertFilter) ([]AlertResponse, error)
+}
+
+// ErrQuotaExceeded: el consumo o la reserva no cabe en la cuota restante
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// TTL de las reservas
+const (
+	defaultReservationTTL = 5 * time.Minute
+	maxReservationTTL     = 24 * time.Hour
+)
+
+// Tamaño de página de GET /usage/alerts
+const (
+	defaultAlertsLimit = 100
+	maxAlertsLimit     = 1000
+)
+
+type usageService struct {
+	repo               UsageRepository
+	featureRepo        features.FeatureRepository
+	planFeatureRepo    planfeatures.PlanFeatureRepository
+	entitlementService entitlements.EntitlementService
+	loc                *time.Location
+}
+
+// NewUsageService: loc es la zona horaria en la que empiezan las ventanas de reset
+func NewUsageService(
+	repo UsageRepository,
+	featureRepo features.FeatureRepository,
+	planFeatureRepo planfeatures.PlanFeatureRepository,
+	entitlementService entitlements.EntitlementService,
+	loc *time.Location,
+) UsageService {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return &usageService{
+		repo:               repo,
+		featureRepo:        featureRepo,
+		planFeatureRepo:    planFeatureRepo,
+		entitlementService: entitlementService,
+		loc:                loc,
+	}
+}
+
+// quotaPolicy: límite efectivo de la feature para el tenant (plan, add-ons,
+// overrides, default) y política de la asignación en el plan
+type quotaPolicy struct {
+	limit       *float64
+	enforcement string
+	thresholds  []float64
+}
+
+// ceiling es el límite que aplica el repositorio: sin límite con unlimited y
+// 0 si el tenant no tiene valor numérico
+func (p quotaPolicy) ceiling() float64 {
+	if p.enforcement == planfeatures.EnforcementUnlimited {
+		return math.Inf(1)
+	}
+	if p.limit == nil {
+		return 0
+	}
+	return *p.limit
+}
+
+// enforce: solo hard rechaza lo que no cabe
+func (p quotaPolicy) enforce() bool {
+	return p.enforcement != planfeatures.EnforcementSoft && p.enforcement != planfeatures.EnforcementUnlimited
+}
+
+// period devuelve la ventana de reset de la feature que contiene at, anclada
+// al ciclo del tenant (cycle_anchor de su asignación, si la tiene)
+func (s *usageService) period(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, feat *features.FeatureResponse, at time.Time) (time.Time, time.Time, error) {
+	if feat.ResetPeriod == "" || feat.ResetPeriod == features.ResetNone {
+		start, end := periodBounds(features.ResetNone, nil, s.loc, at)
+		return start, end, nil
+	}
+	anchor, err := s.repo.CycleAnchor(ctx, tenantID, projectID)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start, end := periodBounds(feat.ResetPeriod, anchor, s.loc, at)
+	return start, end, nil
+}
+
+// numericFeature busca la feature por código y exige que sea numeric
+func (s *usageService) numericFeature(ctx context.Context, projectID uuid.UUID, code string) (*features.FeatureResponse, error) {
+	if code == "" {
+		return nil, errors.New("feature_code is required")
+	}
+	feat, err := s.featureRepo.GetByCode(ctx, projectID, code)
+	if err != nil {
+		return nil, err
+	}
+	if !feat.IsActive {
+		return nil, errors.New("feature not found")
+	}
+	if feat.Type != "numeric" {
+		return nil, errors.New("usage can only be recorded for numeric features")
+	}
+	return feat, nil
+}
+
+func (s *usageService) RecordUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, req RecordUsageRequest) (*UsageResponse, error) {
+	feat, err := s.numericFeature(ctx, projectID, req.FeatureCode)
+	if err != nil {
+		return nil, err
+	}
+	if req.Kind == "" {
+		req.Kind = KindIncrement
+	}
+	switch req.Kind {
+	case KindIncrement:
+		if req.Amount == 0 {
+			return nil, errors.New("amount must not be 0")
+		}
+	case KindGauge:
+		if req.Amount < 0 {
+			return nil, errors.New("amount must be >= 0 for gauge")
+		}
+	default:
+		return nil, errors.New("kind must be increment or gauge")
+	}
+	if math.IsNaN(req.Amount) || math.IsInf(req.Amount, 0) {
+		return nil, errors.New("amount must be a finite number")
+	}
+
+	occurredAt := time.Now()
+	if req.OccurredAt != nil {
+		occurredAt = *req.OccurredAt
+	}
+	start, end, err := s.period(ctx, tenantID, projectID, feat, occurredAt)
+	if err != nil {
+		return nil, err
+	}
+	ev := Event{
+		ID:             uuid.New(),
+		TenantID:       tenantID,
+		ProjectID:      projectID,
+		FeatureID:      feat.ID,
+		Kind:           req.Kind,
+		Amount:         req.Amount,
+		PeriodStart:    start,
+		IdempotencyKey: req.IdempotencyKey,
+		OccurredAt:     occurredAt,
+	}
+	pol, err := s.policy(ctx, tenantID, projectID, feat.Code)
+	if err != nil {
+		return nil, err
+	}
+	q, err := s.repo.Record(ctx, ev, pol.ceiling())
+	if err != nil {
+		return nil, err
+	}
+	s.emitAlerts(ctx, tenantID, projectID, feat, start, pol, q.Used)
+	return newUsageResponse(tenantID, feat.Code, q, pol, start, end), nil
+}
+
+func (s *usageService) GetUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string) (*UsageResponse, error) {
+	feat, err := s.numericFeature(ctx, projectID, featureCode)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := s.period(ctx, tenantID, projectID, feat, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	q, err := s.repo.GetQuota(ctx, tenantID, projectID, feat.ID, start)
+	if err != nil {
+		return nil, err
+	}
+	pol, err := s.policy(ctx, tenantID, projectID, feat.Code)
+	if err != nil {
+		return nil, err
+	}
+	return newUsageResponse(tenantID, feat.Code, q, pol, start, end), nil
+}
+
+// ListUsage devuelve el uso de las features numéricas del tenant: las que
+// tiene en sus entitlements y las que tienen uso registrado, por código
+func (s *usageService) ListUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID) ([]UsageResponse, error) {
+	feats, err := s.featureRepo.List(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	anchor, err := s.repo.CycleAnchor(ctx, tenantID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	// cada feature tiene su propia ventana de reset
+	now := time.Now()
+	type window struct{ start, end time.Time }
+	windows := map[string]window{}
+	periods := map[uuid.UUID]time.Time{}
+	codes := map[uuid.UUID]string{}
+	for _, f := range feats {
+		if !f.IsActive || f.Type != "numeric" {
+			continue
+		}
+		start, end := periodBounds(f.ResetPeriod, anchor, s.loc, now)
+		windows[f.Code] = window{start: start, end: end}
+		periods[f.ID] = start
+		codes[f.ID] = f.Code
+	}
+	quotas, err := s.repo.ListQuota(ctx, tenantID, projectID, periods)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := map[string]quotaPolicy{}
+	ents, err := s.entitlementService.GetEntitlements(ctx, tenantID, projectID, nil)
+	if err != nil {
+		return nil, err
+	}
+	for code, fe := range ents.Features {
+		if _, ok := windows[code]; ok {
+			policies[code] = quotaPolicy{limit: numericValue(fe.Value), enforcement: planfeatures.EnforcementHard}
+		}
+	}
+	// política de la versión fijada o, sin versión, de la asignación más
+	// cercana en la cadena del plan; sin plan todo es hard
+	if ents.PlanID != nil {
+		var pfs []planfeatures.PlanFeatureResponse
+		if ents.PlanVersionID != nil {
+			pfs, err = s.planFeatureRepo.ListVersionFeatures(ctx, projectID, *ents.PlanVersionID)
+		} else {
+			pfs, err = s.planFeatureRepo.ListInherited(ctx, projectID, *ents.PlanID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, pf := range pfs {
+			if pol, ok := policies[codes[pf.FeatureID]]; ok {
+				pol.enforcement, pol.thresholds = pf.Enforcement, pf.AlertThresholds
+				policies[codes[pf.FeatureID]] = pol
+			}
+		}
+	}
+	for code := range quotas {
+		if _, ok := policies[code]; !ok {
+			policies[code] = quotaPolicy{}
+		}
+	}
+
+	sorted := make([]string, 0, len(policies))
+	for code := range policies {
+		sorted = append(sorted, code)
+	}
+	sort.Strings(sorted)
+
+	out := make([]UsageResponse, 0, len(sorted))
+	for _, code := range sorted {
+		w := windows[code]
+		out = append(out, *newUsageResponse(tenantID, code, quotas[code], policies[code], w.start, w.end))
+	}
+	return out, nil
+}
+
+// ConsumeUsage descuenta amount de la cuota de forma atómica. Con enforcement
+// hard, si no cabe devuelve ErrQuotaExceeded junto con la cuota actual (para
+// informar remaining); soft lo deja pasar y registra el exceso y unlimited no
+// comprueba nada. Sin valor numérico para el tenant la cuota es 0.
+func (s *usageService) ConsumeUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string, req ConsumeRequest) (*UsageResponse, error) {
+	feat, err := s.numericFeature(ctx, projectID, featureCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePositive(req.Amount); err != nil {
+		return nil, err
+	}
+	pol, err := s.policy(ctx, tenantID, projectID, feat.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	start, end, err := s.period(ctx, tenantID, projectID, feat, now)
+	if err != nil {
+		return nil, err
+	}
+	ev := Event{
+		ID:             uuid.New(),
+		TenantID:       tenantID,
+		ProjectID:      projectID,
+		FeatureID:      feat.ID,
+		Kind:           KindIncrement,
+		Amount:         req.Amount,
+		PeriodStart:    start,
+		IdempotencyKey: req.IdempotencyKey,
+		OccurredAt:     now,
+	}
+	q, ok, err := s.repo.Consume(ctx, ev, pol.ceiling(), pol.enforce())
+	if err != nil {
+		return nil, err
+	}
+	res := newUsageResponse(tenantID, feat.Code, q, pol, start, end)
+	if !ok {
+		return res, ErrQuotaExceeded
+	}
+	s.emitAlerts(ctx, tenantID, projectID, feat, start, pol, q.Used)
+	return res, nil
+}
+
+// ReserveUsage aparta amount de la cuota hasta commit, release o caducidad.
+// Un reintento con el mismo idempotency_key devuelve la reserva original.
+func (s *usageService) ReserveUsage(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string, req ReserveRequest) (*ReservationResponse, error) {
+	feat, err := s.numericFeature(ctx, projectID, featureCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePositive(req.Amount); err != nil {
+		return nil, err
+	}
+	ttl := defaultReservationTTL
+	if req.TTLSeconds != 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+		if ttl <= 0 || ttl > maxReservationTTL {
+			return nil, fmt.Errorf("ttl_seconds must be between 1 and %d", int(maxReservationTTL.Seconds()))
+		}
+	}
+	pol, err := s.policy(ctx, tenantID, projectID, feat.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	start, end, err := s.period(ctx, tenantID, projectID, feat, now)
+	if err != nil {
+		return nil, err
+	}
+	res := Reservation{
+		ID:             uuid.New(),
+		TenantID:       tenantID,
+		ProjectID:      projectID,
+		FeatureID:      feat.ID,
+		FeatureCode:    feat.Code,
+		PeriodStart:    start,
+		Amount:         req.Amount,
+		Status:         ReservationPending,
+		ExpiresAt:      now.Add(ttl),
+		IdempotencyKey: req.IdempotencyKey,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	saved, q, ok, err := s.repo.Reserve(ctx, res, pol.ceiling(), pol.enforce())
+	if err != nil {
+		return nil, err
+	}
+	if saved == nil {
+		saved = &res
+	}
+	out := ToResponse(saved, now)
+	usage := newUsageResponse(tenantID, feat.Code, q, pol, start, end)
+	out.Remaining, out.ResetsAt = usage.Remaining, usage.ResetsAt
+	if !ok {
+		return out, ErrQuotaExceeded
+	}
+	return out, nil
+}
+
+func (s *usageService) GetReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID) (*ReservationResponse, error) {
+	res, err := s.repo.GetReservation(ctx, tenantID, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+	return ToResponse(res, time.Now()), nil
+}
+
+func (s *usageService) CommitReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID, req CommitReservationRequest) (*ReservationResponse, error) {
+	if req.Amount != nil {
+		if math.IsNaN(*req.Amount) || math.IsInf(*req.Amount, 0) {
+			return nil, errors.New("amount must be a finite number")
+		}
+		if *req.Amount < 0 {
+			return nil, errors.New("amount must be >= 0")
+		}
+	}
+	current, err := s.repo.GetReservation(ctx, tenantID, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+	feat, err := s.featureRepo.GetByID(ctx, projectID, current.FeatureID)
+	if err != nil {
+		return nil, err
+	}
+	pol, err := s.policy(ctx, tenantID, projectID, feat.Code)
+	if err != nil {
+		return nil, err
+	}
+	res, q, err := s.repo.CommitReservation(ctx, tenantID, projectID, id, req.Amount, pol.ceiling())
+	if err != nil {
+		return nil, err
+	}
+	s.emitAlerts(ctx, tenantID, projectID, feat, res.PeriodStart, pol, q.Used)
+	return ToResponse(res, time.Now()), nil
+}
+
+func (s *usageService) ReleaseReservation(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, id uuid.UUID) (*ReservationResponse, error) {
+	res, err := s.repo.ReleaseReservation(ctx, tenantID, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+	return ToResponse(res, time.Now()), nil
+}
+
+func validatePositive(amount float64) error {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return errors.New("amount must be a finite number")
+	}
+	if amount <= 0 {
+		return errors.New("amount must be > 0")
+	}
+	return nil
+}
+
+func (s *usageService) ListAlerts(ctx context.Context, projectID uuid.UUID, filter AlertFilter) ([]AlertResponse, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAlertsLimit
+	}
+	if filter.Limit > maxAlertsLimit {
+		return nil, fmt.Errorf("limit must be at most %d", maxAlertsLimit)
+	}
+	alerts, err := s.repo.ListAlerts(ctx, projectID, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AlertResponse, 0, len(alerts))
+	for i := range alerts {
+		out = append(out, *ToAlertResponse(&alerts[i]))
+	}
+	return out, nil
+}
+
+// policy resuelve el límite y la política de la feature para el tenant
+func (s *usageService) policy(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, featureCode string) (quotaPolicy, error) {
+	check, err := s.entitlementService.CheckFeature(ctx, tenantID, projectID, featureCode, nil)
+	if err != nil {
+		return quotaPolicy{}, err
+	}
+	return quotaPolicy{
+		limit:       numericValue(check.Value),
+		enforcement: check.Enforcement,
+		thresholds:  check.AlertThresholds,
+	}, nil
+}
+
+// emitAlerts registra los umbrales (porcentajes del límite) que used ha
+// cruzado; cada uno se emite una sola vez por tenant y periodo. El uso ya
+// está guardado, así que un fallo aquí solo se registra en el log.
+func (s *usageService) emitAlerts(ctx context.Context, tenantID uuid.UUID, projectID uuid.UUID, feat *features.FeatureResponse, periodStart time.Time, pol quotaPolicy, used float64) {
+	if pol.enforcement == planfeatures.EnforcementUnlimited || pol.limit == nil || *pol.limit <= 0 {
+		return
+	}
+	var crossed []Alert
+	for _, t := range pol.thresholds {
+		if used >= *pol.limit*t/100 {
+			crossed = append(crossed, Alert{
+				ID:          uuid.New(),
+				TenantID:    tenantID,
+				ProjectID:   projectID,
+				FeatureID:   feat.ID,
+				FeatureCode: feat.Code,
+				PeriodStart: periodStart,
+				Threshold:   t,
+				Used:        used,
+				Limit:       *pol.limit,
+			})
+		}
+	}
+	if len(crossed) == 0 {
+		return
+	}
+	created, err := s.repo.RecordAlerts(ctx, crossed)
+	if err != nil {
+		log.Printf("record usage alerts failed: %v", err)
+		return
+	}
+	for _, a := range created {
+		log.Printf("usage alert: tenant %s feature %s crossed %g%% (%g of %g)", a.TenantID, a.FeatureCode, a.Threshold, a.Used, a.Limit)
+	}
+}
+
+func numericValue(v interface{}) *float64 {
+	if f, ok := v.(float64); ok {
+		return &f
+	}
+	return nil
+}
+
+// newUsageResponse: end cero = la feature no se reinicia (resets_at null)
+func newUsageResponse(tenantID uuid.UUID, featureCode string, q Quota, pol quotaPolicy, start time.Time, end time.Time) *UsageResponse {
+	res := &UsageResponse{
+		TenantID:    tenantID,
+		FeatureCode: featureCode,
+		Used:        q.Used,
+		Reserved:    q.Reserved,
+		Enforcement: pol.enforcement,
+		PeriodStart: start,
+	}
+	if !end.IsZero() {
+		res.ResetsAt = &end
+	}
+	if pol.limit != nil && pol.enforcement != planfeatures.EnforcementUnlimited {
+		limit := *pol.limit
+		remaining := math.Max(limit-q.Used-q.Reserved, 0)
+		res.Limit = &limit
+		res.Remaining = &remaining
+		res.Overage = math.Max(q.Used-limit, 0)
+	}
+	return res
+}
@@ -0,0 +1,93 @@
+package usage
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"plans-features/internal/domain/planfeatures"
+)
+
+func TestQuotaPolicy(t *testing.T) {
+	ten := 10.0
+	tests := []struct {
+		name        string
+		policy      quotaPolicy
+		wantCeiling float64
+		wantEnforce bool
+	}{
+		{"hard", quotaPolicy{limit: &ten, enforcement: planfeatures.EnforcementHard}, 10, true},
+		{"soft", quotaPolicy{limit: &ten, enforcement: planfeatures.EnforcementSoft}, 10, false},
+		{"unlimited", quotaPolicy{limit: &ten, enforcement: planfeatures.EnforcementUnlimited}, math.Inf(1), false},
+		{"unlimited without value", quotaPolicy{enforcement: planfeatures.EnforcementUnlimited}, math.Inf(1), false},
+		{"no value", quotaPolicy{enforcement: planfeatures.EnforcementHard}, 0, true},
+		{"no policy", quotaPolicy{}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.ceiling(); got != tt.wantCeiling {
+				t.Errorf("ceiling = %v, want %v", got, tt.wantCeiling)
+			}
+			if got := tt.policy.enforce(); got != tt.wantEnforce {
+				t.Errorf("enforce = %v, want %v", got, tt.wantEnforce)
+			}
+		})
+	}
+}
+
+func TestOverage(t *testing.T) {
+	tests := []struct {
+		before, after, limit, want float64
+	}{
+		{0, 5, 10, 0},
+		{8, 12, 10, 2},
+		{12, 15, 10, 3},
+		{10, 10, 10, 0},
+		{0, 5, 0, 5},
+		{0, 1e9, math.Inf(1), 0},
+	}
+	for _, tt := range tests {
+		if got := overage(tt.before, tt.after, tt.limit); got != tt.want {
+			t.Errorf("overage(%v, %v, %v) = %v, want %v", tt.before, tt.after, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestNewUsageResponse(t *testing.T) {
+	ten := 10.0
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	res := newUsageResponse(uuid.New(), "api_calls", Quota{Used: 12, Reserved: 3},
+		quotaPolicy{limit: &ten, enforcement: planfeatures.EnforcementSoft}, start, end)
+	if res.Limit == nil || *res.Limit != 10 {
+		t.Errorf("limit = %v, want 10", res.Limit)
+	}
+	if res.Remaining == nil || *res.Remaining != 0 {
+		t.Errorf("remaining = %v, want 0", res.Remaining)
+	}
+	if res.Overage != 2 {
+		t.Errorf("overage = %v, want 2", res.Overage)
+	}
+	if res.ResetsAt == nil || !res.ResetsAt.Equal(end) {
+		t.Errorf("resets_at = %v, want %v", res.ResetsAt, end)
+	}
+
+	res = newUsageResponse(uuid.New(), "api_calls", Quota{Used: 4, Reserved: 3},
+		quotaPolicy{limit: &ten, enforcement: planfeatures.EnforcementHard}, start, time.Time{})
+	if res.Remaining == nil || *res.Remaining != 3 {
+		t.Errorf("remaining = %v, want 3", res.Remaining)
+	}
+	if res.ResetsAt != nil {
+		t.Errorf("resets_at = %v, want nil", res.ResetsAt)
+	}
+
+	res = newUsageResponse(uuid.New(), "api_calls", Quota{Used: 40},
+		quotaPolicy{limit: &ten, enforcement: planfeatures.EnforcementUnlimited}, start, end)
+	if res.Limit != nil || res.Remaining != nil || res.Overage != 0 {
+		t.Errorf("unlimited response = limit %v, remaining %v, overage %v; want nil, nil, 0",
+			res.Limit, res.Remaining, res.Overage)
+	}
+}
@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every ejecuta fn cada interval (la primera vez al arrancar) hasta que se
+// cancele ctx. Un error solo se registra: la siguiente pasada lo reintenta.
+// Cada réplica ejecuta sus propios jobs, así que fn debe reclamar el trabajo
+// de forma segura (p. ej. FOR UPDATE SKIP LOCKED).
+func Every(ctx context.Context, interval time.Duration, name string, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := fn(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("job %s failed: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
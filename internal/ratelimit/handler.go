@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type RateLimitHandler struct {
+	limiter *Limiter
+}
+
+func NewRateLimitHandler(l *Limiter) *RateLimitHandler {
+	return &RateLimitHandler{limiter: l}
+}
+
+func errorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "project not found" || msg == "api key not found":
+		return http.StatusNotFound
+	case strings.HasPrefix(msg, "invalid rate limit"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GetProjectPolicy godoc
+// @Summary Get project rate limits
+// @Description Get the rate limit policy stored for the project and the effective one after applying server defaults
+// @Tags ratelimits
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param projectId path string true "Project ID"
+// @Success 200 {object} ratelimit.PolicyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/projects/{projectId}/ratelimits [get]
+func (h *RateLimitHandler) GetProjectPolicy(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
+		return
+	}
+	res, err := h.limiter.GetProjectPolicy(r.Context(), projectID)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// SetProjectPolicy godoc
+// @Summary Set project rate limits
+// @Description Replace the project rate limit policy. Omitted limits fall back to server defaults; a null body clears the policy. requests_per_second 0 disables the limit.
+// @Tags ratelimits
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param projectId path string true "Project ID"
+// @Param body body ratelimit.Policy true "Rate limit policy"
+// @Success 200 {object} ratelimit.PolicyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/projects/{projectId}/ratelimits [put]
+func (h *RateLimitHandler) SetProjectPolicy(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
+		return
+	}
+	var p *Policy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.limiter.SetProjectPolicy(r.Context(), projectID, p)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// GetKeyPolicy godoc
+// @Summary Get API key rate limits
+// @Description Get the rate limit override stored for the API key and the effective policy (defaults, project, key)
+// @Tags ratelimits
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param projectId path string true "Project ID"
+// @Param keyId path string true "API key ID"
+// @Success 200 {object} ratelimit.PolicyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/projects/{projectId}/apikeys/{keyId}/ratelimits [get]
+func (h *RateLimitHandler) GetKeyPolicy(w http.ResponseWriter, r *http.Request) {
+	projectID, keyID, ok := parseKeyParams(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.limiter.GetKeyPolicy(r.Context(), projectID, keyID)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+// SetKeyPolicy godoc
+// @Summary Set API key rate limits
+// @Description Override the project rate limit policy for one API key. Omitted limits inherit from the project; a null body clears the override.
+// @Tags ratelimits
+// @Accept json
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param projectId path string true "Project ID"
+// @Param keyId path string true "API key ID"
+// @Param body body ratelimit.Policy true "Rate limit policy"
+// @Success 200 {object} ratelimit.PolicyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/projects/{projectId}/apikeys/{keyId}/ratelimits [put]
+func (h *RateLimitHandler) SetKeyPolicy(w http.ResponseWriter, r *http.Request) {
+	projectID, keyID, ok := parseKeyParams(w, r)
+	if !ok {
+		return
+	}
+	var p *Policy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid body")
+		return
+	}
+	res, err := h.limiter.SetKeyPolicy(r.Context(), projectID, keyID, p)
+	if err != nil {
+		utils.Error(w, errorStatus(err), err.Error())
+		return
+	}
+	utils.JSON(w, http.StatusOK, res)
+}
+
+func parseKeyParams(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	projectID, err := uuid.Parse(chi.URLParam(r, "projectId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid project ID format")
+		return uuid.Nil, uuid.Nil, false
+	}
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyId"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid key ID format")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return projectID, keyID, true
+}
@@ -0,0 +1,233 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"plans-features/internal/auth"
+	"plans-features/internal/config"
+	"plans-features/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Limiter resuelve la política efectiva (defaults de config → proyecto → key)
+// y consume tokens del Store. Las políticas se cachean PolicyCacheTTL para no
+// consultar la BD en cada petición.
+type Limiter struct {
+	store    Store
+	repo     PolicyRepository
+	defaults Policy
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[policyKey]cachedPolicy
+}
+
+type policyKey struct {
+	projectID uuid.UUID
+	keyID     uuid.UUID
+}
+
+type cachedPolicy struct {
+	policy    Policy
+	expiresAt time.Time
+}
+
+func NewLimiter(store Store, repo PolicyRepository, cfg config.RateLimits) *Limiter {
+	return &Limiter{
+		store: store,
+		repo:  repo,
+		defaults: Policy{
+			Key:    &Limit{RequestsPerSecond: cfg.KeyRPS, Burst: cfg.KeyBurst},
+			Tenant: &Limit{RequestsPerSecond: cfg.TenantRPS, Burst: cfg.TenantBurst},
+		},
+		ttl:   cfg.PolicyCacheTTL,
+		cache: map[policyKey]cachedPolicy{},
+	}
+}
+
+// PerKey limita las peticiones de cada API key (también las hechas con un
+// access token emitido para ella). Debe ir después de auth.APIKey.
+func (l *Limiter) PerKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if l.allow(w, r, p, func(pol Policy) *Limit { return pol.Key }, "key:"+p.KeyID.String()) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// PerTenant limita las peticiones sobre cada tenant (parámetro de ruta param)
+// dentro del proyecto del principal.
+func (l *Limiter) PerTenant(param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.PrincipalFromContext(r.Context())
+			tenantID := chi.URLParam(r, param)
+			if !ok || tenantID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			bucket := "tenant:" + p.ProjectID.String() + ":" + tenantID
+			if l.allow(w, r, p, func(pol Policy) *Limit { return pol.Tenant }, bucket) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// allow consume un token y escribe las cabeceras RateLimit-*. Si no se puede
+// resolver la política o el store falla, deja pasar la petición (fail open).
+func (l *Limiter) allow(w http.ResponseWriter, r *http.Request, p *auth.Principal, pick func(Policy) *Limit, bucket string) bool {
+	pol, err := l.effective(r.Context(), p.ProjectID, p.KeyID)
+	if err != nil {
+		log.Printf("rate limit policy lookup failed: %v", err)
+		return true
+	}
+	limit := pick(pol)
+	if limit == nil || limit.unlimited() {
+		return true
+	}
+	res, err := l.store.Take(r.Context(), bucket, *limit)
+	if err != nil {
+		log.Printf("rate limit store failed: %v", err)
+		return true
+	}
+
+	writeHeaders(w, res)
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		utils.Error(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// writeHeaders publica el estado del bucket más restrictivo: si otro limitador
+// ya escribió las cabeceras con menos restantes, se conservan.
+func writeHeaders(w http.ResponseWriter, res Result) {
+	h := w.Header()
+	if prev, err := strconv.Atoi(h.Get("RateLimit-Remaining")); err == nil && prev <= res.Remaining {
+		return
+	}
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// effective devuelve la política aplicable a la key, usando la caché
+func (l *Limiter) effective(ctx context.Context, projectID, keyID uuid.UUID) (Policy, error) {
+	k := policyKey{projectID: projectID, keyID: keyID}
+	now := time.Now()
+
+	l.mu.Lock()
+	c, ok := l.cache[k]
+	l.mu.Unlock()
+	if ok && now.Before(c.expiresAt) {
+		return c.policy, nil
+	}
+
+	projectPolicy, keyPolicy, err := l.repo.Get(ctx, projectID, keyID)
+	if err != nil {
+		return Policy{}, err
+	}
+	pol := l.defaults.merge(projectPolicy).merge(keyPolicy)
+
+	l.mu.Lock()
+	l.cache[k] = cachedPolicy{policy: pol, expiresAt: now.Add(l.ttl)}
+	l.mu.Unlock()
+	return pol, nil
+}
+
+// invalidate descarta de la caché las políticas del proyecto tras un cambio
+func (l *Limiter) invalidate(projectID uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k := range l.cache {
+		if k.projectID == projectID {
+			delete(l.cache, k)
+		}
+	}
+}
+
+// GetProjectPolicy devuelve la política guardada en el proyecto y la efectiva
+func (l *Limiter) GetProjectPolicy(ctx context.Context, projectID uuid.UUID) (*PolicyResponse, error) {
+	projectPolicy, _, err := l.repo.Get(ctx, projectID, uuid.Nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyResponse{
+		Configured: projectPolicy,
+		Effective:  l.defaults.merge(projectPolicy),
+	}, nil
+}
+
+// GetKeyPolicy devuelve la política guardada en la key y la efectiva
+func (l *Limiter) GetKeyPolicy(ctx context.Context, projectID, keyID uuid.UUID) (*PolicyResponse, error) {
+	projectPolicy, keyPolicy, err := l.repo.Get(ctx, projectID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyResponse{
+		Configured: keyPolicy,
+		Effective:  l.defaults.merge(projectPolicy).merge(keyPolicy),
+	}, nil
+}
+
+// SetProjectPolicy guarda la política del proyecto; nil vuelve a los defaults
+func (l *Limiter) SetProjectPolicy(ctx context.Context, projectID uuid.UUID, p *Policy) (*PolicyResponse, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if err := l.repo.SetProject(ctx, projectID, p); err != nil {
+		return nil, err
+	}
+	l.invalidate(projectID)
+	return l.GetProjectPolicy(ctx, projectID)
+}
+
+// SetKeyPolicy guarda la política de la key; nil vuelve a la del proyecto
+func (l *Limiter) SetKeyPolicy(ctx context.Context, projectID, keyID uuid.UUID, p *Policy) (*PolicyResponse, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if err := l.repo.SetKey(ctx, projectID, keyID, p); err != nil {
+		return nil, err
+	}
+	l.invalidate(projectID)
+	return l.GetKeyPolicy(ctx, projectID, keyID)
+}
+
+func (p *Policy) validate() error {
+	if p == nil {
+		return nil
+	}
+	for name, limit := range map[string]*Limit{"key": p.Key, "tenant": p.Tenant} {
+		if limit == nil {
+			continue
+		}
+		if limit.RequestsPerSecond < 0 || limit.Burst < 0 {
+			return fmt.Errorf("invalid rate limit %s: values must be >= 0", name)
+		}
+		if limit.RequestsPerSecond > 0 && limit.Burst == 0 {
+			return fmt.Errorf("invalid rate limit %s: burst is required", name)
+		}
+	}
+	return nil
+}
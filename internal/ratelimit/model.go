@@ -0,0 +1,50 @@
+package ratelimit
+
+import "time"
+
+// Limit define un token bucket: RequestsPerSecond de recarga y Burst de capacidad.
+// RequestsPerSecond <= 0 significa sin límite.
+type Limit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+func (l Limit) unlimited() bool {
+	return l.RequestsPerSecond <= 0 || l.Burst <= 0
+}
+
+// Policy agrupa los límites por API key y por tenant. Se guarda como JSONB en
+// projects.rate_limits y api_keys.rate_limits; los campos nil heredan.
+type Policy struct {
+	Key    *Limit `json:"key,omitempty"`
+	Tenant *Limit `json:"tenant,omitempty"`
+}
+
+// merge aplica encima de p los campos definidos en override
+func (p Policy) merge(override *Policy) Policy {
+	if override == nil {
+		return p
+	}
+	if override.Key != nil {
+		p.Key = override.Key
+	}
+	if override.Tenant != nil {
+		p.Tenant = override.Tenant
+	}
+	return p
+}
+
+// Result es el estado del bucket tras consumir (o intentar consumir) un token
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // hasta que el bucket vuelve a estar lleno
+	RetryAfter time.Duration // hasta el próximo token (solo si !Allowed)
+}
+
+// PolicyResponse devuelve la política guardada y la efectiva tras aplicar defaults
+type PolicyResponse struct {
+	Configured *Policy `json:"configured"`
+	Effective  Policy  `json:"effective"`
+}
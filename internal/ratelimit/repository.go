@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type PolicyRepository interface {
+	// Get devuelve la política del proyecto y, si keyID no es Nil, la de la key
+	Get(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID) (*Policy, *Policy, error)
+	SetProject(ctx context.Context, projectID uuid.UUID, p *Policy) error
+	SetKey(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID, p *Policy) error
+}
+
+type policyRepository struct {
+	db *sql.DB
+}
+
+func NewPolicyRepository(db *sql.DB) PolicyRepository {
+	return &policyRepository{db: db}
+}
+
+func (r *policyRepository) Get(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID) (*Policy, *Policy, error) {
+	var projectJSON, keyJSON []byte
+	var keyFound bool
+	err := r.db.QueryRowContext(ctx,
+		`SELECT p.rate_limits, k.rate_limits, k.id IS NOT NULL
+         FROM projects p
+         LEFT JOIN api_keys k ON k.project_id = p.id AND k.id = $2
+         WHERE p.id = $1`,
+		projectID, keyID).
+		Scan(&projectJSON, &keyJSON, &keyFound)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, errors.New("project not found")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("get rate limits: %w", err)
+	}
+	if keyID != uuid.Nil && !keyFound {
+		return nil, nil, errors.New("api key not found")
+	}
+
+	projectPolicy, err := unmarshalPolicy(projectJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPolicy, err := unmarshalPolicy(keyJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+	return projectPolicy, keyPolicy, nil
+}
+
+func (r *policyRepository) SetProject(ctx context.Context, projectID uuid.UUID, p *Policy) error {
+	policyJSON, err := marshalPolicy(p)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE projects SET rate_limits = $1, updated_at = NOW() WHERE id = $2`,
+		policyJSON, projectID)
+	if err != nil {
+		return fmt.Errorf("set project rate limits: %w", err)
+	}
+	return expectOne(result, "project not found")
+}
+
+func (r *policyRepository) SetKey(ctx context.Context, projectID uuid.UUID, keyID uuid.UUID, p *Policy) error {
+	policyJSON, err := marshalPolicy(p)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET rate_limits = $1 WHERE id = $2 AND project_id = $3`,
+		policyJSON, keyID, projectID)
+	if err != nil {
+		return fmt.Errorf("set api key rate limits: %w", err)
+	}
+	return expectOne(result, "api key not found")
+}
+
+// JSONB NULL ↔ *Policy nil
+func unmarshalPolicy(raw []byte) (*Policy, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var p Policy
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("unmarshal rate limits: %w", err)
+	}
+	return &p, nil
+}
+
+func marshalPolicy(p *Policy) ([]byte, error) {
+	if p == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("marshal rate limits: %w", err)
+	}
+	return raw, nil
+}
+
+func expectOne(result sql.Result, notFound string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("check affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New(notFound)
+	}
+	return nil
+}
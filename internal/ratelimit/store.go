@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store guarda los contadores de los buckets. La implementación en memoria
+// sirve para una réplica; un backend compartido (p. ej. Redis) puede
+// implementar la misma interfaz.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// sweepInterval: cada cuánto se eliminan buckets que ya se habrían llenado
+const sweepInterval = time.Minute
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	burst := float64(limit.Burst)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		s.buckets[key] = b
+	}
+
+	// recarga proporcional al tiempo transcurrido, acotada a la capacidad
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(burst, b.tokens+elapsed*limit.RequestsPerSecond)
+	b.last = now
+	b.limit = limit
+
+	res := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.RequestsPerSecond)
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.ResetAfter = seconds((burst - b.tokens) / limit.RequestsPerSecond)
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+	return res, nil
+}
+
+// sweep elimina los buckets que ya estarían llenos: recrearlos da el mismo resultado
+func (s *memoryStore) sweep(now time.Time) {
+	for k, b := range s.buckets {
+		refilled := b.tokens + now.Sub(b.last).Seconds()*b.limit.RequestsPerSecond
+		if refilled >= float64(b.limit.Burst) {
+			delete(s.buckets, k)
+		}
+	}
+	s.lastSweep = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
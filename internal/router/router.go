@@ -1,25 +1,59 @@
 package router
 
 import (
+	"context"
+	"log"
 	"net/http"
+	"sync"
+	"time"
 
+	"plans-features/internal/auth"
+	"plans-features/internal/config"
 	"plans-features/internal/db"
+	"plans-features/internal/domain/addons"
+	"plans-features/internal/domain/admintokens"
 	"plans-features/internal/domain/apikeys"
+	"plans-features/internal/domain/credits"
+	"plans-features/internal/domain/entitlements"
 	"plans-features/internal/domain/features"
+	"plans-features/internal/domain/overrides"
 	"plans-features/internal/domain/planfeatures"
 	"plans-features/internal/domain/plans"
 	"plans-features/internal/domain/projects"
 	"plans-features/internal/domain/tenantplans"
+	"plans-features/internal/domain/usage"
+	"plans-features/internal/jobs"
+	"plans-features/internal/ratelimit"
 
 	"github.com/go-chi/chi/v5"
 )
 
-// key for context value
-type ctxKey string
+// StartJobs arranca los jobs en segundo plano (finalizar trials y aplicar
+// cambios de plan programados) hasta que se cancele ctx. La función devuelta
+// espera a que terminen.
+func StartJobs(ctx context.Context, db *db.DB, cfg *config.Config) (wait func()) {
+	tenantPlanService := tenantplans.NewTenantPlanService(
+		tenantplans.NewTenantPlanRepository(db.SQLDB()),
+		projects.NewProjectRepository(db.SQLDB()),
+		plans.NewPlanRepository(db.SQLDB()),
+		planfeatures.NewPlanFeatureRepository(db.SQLDB()),
+	)
 
-const CtxProjectID ctxKey = "project_id"
+	var wg sync.WaitGroup
+	run := func(name string, fn func(ctx context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jobs.Every(ctx, cfg.Scheduler.Interval, name, fn)
+		}()
+	}
+	run("finalize-trials", tenantPlanService.FinalizeExpiredTrials)
+	run("apply-plan-changes", tenantPlanService.ApplyScheduledChanges)
+	return wg.Wait
+}
 
-func NewRouter(db *db.DB) http.Handler {
+// NewRouter monta las rutas; no arranca ningún job (ver StartJobs)
+func NewRouter(db *db.DB, cfg *config.Config) http.Handler {
 	r := chi.NewRouter()
 
 	// -------------------------
@@ -29,8 +63,15 @@ func NewRouter(db *db.DB) http.Handler {
 	planRepo := plans.NewPlanRepository(db.SQLDB())
 	featureRepo := features.NewFeatureRepository(db.SQLDB())
 	tenantPlanRepo := tenantplans.NewTenantPlanRepository(db.SQLDB())
-	apiKeyRepo := apikeys.NewAPIKeyRepository(db.SQLDB())
+	apiKeyRepo := apikeys.NewAPIKeyRepository(db.SQLDB(), []byte(cfg.APIKeys.Pepper))
 	planFeatureRepo := planfeatures.NewPlanFeatureRepository(db.SQLDB())
+	adminTokenRepo := admintokens.NewAdminTokenRepository(db.SQLDB())
+	overrideRepo := overrides.NewOverrideRepository(db.SQLDB())
+	addonRepo := addons.NewAddonRepository(db.SQLDB())
+	entitlementRepo := entitlements.NewEntitlementRepository(db.SQLDB())
+	rateLimitRepo := ratelimit.NewPolicyRepository(db.SQLDB())
+	usageRepo := usage.NewUsageRepository(db.SQLDB())
+	creditRepo := credits.NewCreditRepository(db.SQLDB())
 
 	// -------------------------
 	// Services with dependencies
@@ -46,12 +87,55 @@ func NewRouter(db *db.DB) http.Handler {
 		tenantPlanRepo,
 		projectRepo,
 		planRepo,
+		planFeatureRepo,
 	)
 
-	apiKeyService := apikeys.NewAPIKeyService(apiKeyRepo, projectRepo)
+	apiKeyService := apikeys.NewAPIKeyService(apiKeyRepo, projectRepo, cfg.APIKeys)
 
 	planFeatureService := planfeatures.NewPlanFeatureService(planFeatureRepo, planRepo, featureRepo, projectRepo)
 
+	overrideService := overrides.NewOverrideService(overrideRepo, featureRepo, projectRepo)
+
+	addonService := addons.NewAddonService(addonRepo, featureRepo, projectRepo)
+
+	entitlementService := entitlements.NewEntitlementService(entitlementRepo, featureRepo, planFeatureRepo, overrideRepo, addonRepo)
+
+	usageLocation, err := time.LoadLocation(cfg.Usage.Timezone)
+	if err != nil {
+		log.Printf("invalid usage timezone %q, using UTC: %v", cfg.Usage.Timezone, err)
+		usageLocation = time.UTC
+	}
+	usageService := usage.NewUsageService(usageRepo, featureRepo, planFeatureRepo, entitlementService, usageLocation)
+
+	creditService := credits.NewCreditService(creditRepo, featureRepo, planRepo, entitlementRepo)
+
+	tokenIssuer, err := auth.NewTokenIssuer(cfg.Tokens)
+	if err != nil {
+		log.Printf("token signing keys invalid, token exchange disabled: %v", err)
+		tokenIssuer = nil
+	}
+
+	// sin proxies de confianza válidos se ignora X-Forwarded-For
+	clientIPs, err := auth.NewClientIPResolver(cfg.Server.TrustedProxies)
+	if err != nil {
+		log.Printf("invalid trusted proxies, X-Forwarded-For disabled: %v", err)
+		clientIPs = nil
+	}
+
+	// contadores en memoria (por réplica); un Store compartido se enchufa aquí
+	rateLimiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), rateLimitRepo, cfg.RateLimits)
+
+	adminTokenService := admintokens.NewAdminTokenService(adminTokenRepo)
+	if err := adminTokenService.Bootstrap(context.Background(), cfg.Admin.BootstrapTokens); err != nil {
+		log.Printf("admin token bootstrap failed: %v", err)
+	}
+	if cfg.APIKeys.Pepper == "" {
+		log.Printf("API_KEY_PEPPER not set; api keys are hashed with unpeppered SHA-256")
+	}
+	if len(cfg.Admin.BootstrapTokens) == 0 {
+		log.Printf("no admin bootstrap tokens configured; /admin only accepts tokens already stored")
+	}
+
 	// -------------------------
 	// Handlers
 	// -------------------------
@@ -62,38 +146,14 @@ func NewRouter(db *db.DB) http.Handler {
 	tenantPlanHandler := tenantplans.NewTenantPlanHandler(tenantPlanService)
 	apiKeyHandler := apikeys.NewAPIKeyHandler(apiKeyService)
 	planFeatureHandler := planfeatures.NewPlanFeatureHandler(planFeatureService)
-
-	// -------------------------
-	// Middleware: ApiKeyAuth
-	// -------------------------
-	/*apiKeyAuth := func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// read X-API-Key or Authorization Bearer
-			key := r.Header.Get("X-API-Key")
-			if key == "" {
-				auth := r.Header.Get("Authorization")
-				if len(auth) > 7 && auth[:7] == "Bearer " {
-					key = auth[7:]
-				}
-			}
-			if key == "" {
-				utils.Error(w, http.StatusUnauthorized, "missing api key")
-				return
-			}
-			// validate
-			projectID, err := apiKeyService.ValidateKey(r.Context(), key)
-			if err != nil {
-				utils.Error(w, http.StatusUnauthorized, "invalid api key")
-				return
-			}
-			// set project id in context
-			ctx := context.WithValue(r.Context(), CtxProjectID, projectID)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	}
-
-	// register middleware
-	r.Use(apiKeyAuth) */
+	adminTokenHandler := admintokens.NewAdminTokenHandler(adminTokenService)
+	tokenHandler := auth.NewTokenHandler(tokenIssuer)
+	overrideHandler := overrides.NewOverrideHandler(overrideService)
+	addonHandler := addons.NewAddonHandler(addonService)
+	entitlementHandler := entitlements.NewEntitlementHandler(entitlementService)
+	rateLimitHandler := ratelimit.NewRateLimitHandler(rateLimiter)
+	usageHandler := usage.NewUsageHandler(usageService)
+	creditHandler := credits.NewCreditHandler(creditService)
 
 	// -------------------------
 	// Routes
@@ -103,9 +163,17 @@ func NewRouter(db *db.DB) http.Handler {
 	// ADMIN routes (management)
 	// @Summary Admin endpoints
 	// @Description Administrative endpoints to manage Projects, Plans, Features, Tenant assignments and API keys
-	// @Tags projects, plans, features, apikeys, tenantplans
+	// @Tags projects, plans, features, addons, apikeys, tenantplans, overrides, admintokens, ratelimits, usage, credits
 	// -------------------------
 	r.Route("/admin", func(r chi.Router) {
+		r.Use(auth.Admin(adminTokenService))
+
+		// Admin tokens
+		r.Route("/tokens", func(r chi.Router) {
+			r.Get("/", adminTokenHandler.ListTokens)
+			r.Post("/", adminTokenHandler.CreateToken)
+			r.Post("/{tokenId}/revoke", adminTokenHandler.RevokeToken)
+		})
 
 		// Projects
 		r.Route("/projects", func(r chi.Router) {
@@ -115,63 +183,170 @@ func NewRouter(db *db.DB) http.Handler {
 			r.Put("/{projectId}", projectHandler.UpdateProject)
 
 			// API keys for project
+			r.Get("/{projectId}/apikeys", apiKeyHandler.ListKeys)
 			r.Post("/{projectId}/apikeys", apiKeyHandler.CreateKey)
 			r.Post("/{projectId}/apikeys/rotate", apiKeyHandler.RotateKey)
 			r.Post("/{projectId}/apikeys/revoke", apiKeyHandler.RevokeKey)
+			r.Patch("/{projectId}/apikeys/{keyId}", apiKeyHandler.UpdateKey)
+
+			// Rate limit policies (project and per-key override)
+			r.Get("/{projectId}/ratelimits", rateLimitHandler.GetProjectPolicy)
+			r.Put("/{projectId}/ratelimits", rateLimitHandler.SetProjectPolicy)
+			r.Get("/{projectId}/apikeys/{keyId}/ratelimits", rateLimitHandler.GetKeyPolicy)
+			r.Put("/{projectId}/apikeys/{keyId}/ratelimits", rateLimitHandler.SetKeyPolicy)
 
 			// Plans per project
 			r.Route("/{projectId}/plans", func(r chi.Router) {
+				r.Use(auth.AdminProject("projectId"))
 				r.Get("/", planHandler.ListPlans)
 				r.Post("/", planHandler.CreatePlan)
 				r.Get("/{planId}", planHandler.GetPlan)
 				r.Patch("/{planId}", planHandler.UpdatePlan)
+				r.Get("/{planId}/versions", planFeatureHandler.ListVersions)
+				r.Post("/{planId}/versions/publish", planFeatureHandler.PublishVersion)
+				r.Get("/{planId}/versions/{versionId}", planFeatureHandler.GetVersion)
 			})
 
+			// Bulk migration of tenants between plan versions
+			r.With(auth.AdminProject("projectId")).Post("/{projectId}/plan-versions/migrate", tenantPlanHandler.MigrateVersion)
+
 			// Features per project
 			r.Route("/{projectId}/features", func(r chi.Router) {
+				r.Use(auth.AdminProject("projectId"))
 				r.Get("/", featureHandler.ListFeatures)
 				r.Post("/", featureHandler.CreateFeature)
 				r.Get("/{featureId}", featureHandler.GetFeature)
 				r.Patch("/{featureId}", featureHandler.UpdateFeature)
 			})
+
+			// Add-ons per project
+			r.Route("/{projectId}/addons", func(r chi.Router) {
+				r.Use(auth.AdminProject("projectId"))
+				r.Get("/", addonHandler.ListAddons)
+				r.Post("/", addonHandler.CreateAddon)
+				r.Get("/{addonId}", addonHandler.GetAddon)
+				r.Patch("/{addonId}", addonHandler.UpdateAddon)
+				r.Get("/{addonId}/features", addonHandler.ListFeatures)
+				r.Post("/{addonId}/features", addonHandler.AssignFeature)
+			})
+
+			// Usage alerts per project
+			r.With(auth.AdminProject("projectId")).Get("/{projectId}/usage/alerts", usageHandler.ListAlerts)
+
+			// Credits of a tenant in the project
+			r.Route("/{projectId}/tenants/{tenantId}/credits", func(r chi.Router) {
+				r.Use(auth.AdminProject("projectId"))
+				r.Get("/", creditHandler.GetBalance)
+				r.Get("/ledger", creditHandler.ListLedger)
+				r.Post("/grants", creditHandler.GrantCredits)
+			})
 		})
 
 		// Tenant plan assignments
 		r.Route("/tenants/{tenantId}/assignments", func(r chi.Router) {
 			r.Get("/", tenantPlanHandler.ListAssignments)
 			r.Post("/", tenantPlanHandler.CreateAssignment)
+			r.Get("/history", tenantPlanHandler.ListHistory)
 			r.Patch("/{assignmentId}", tenantPlanHandler.UpdateAssignment)
 		})
+
+		// Per-tenant feature overrides
+		r.Route("/tenants/{tenantId}/overrides", func(r chi.Router) {
+			r.Get("/", overrideHandler.ListOverrides)
+			r.Post("/", overrideHandler.CreateOverride)
+			r.Get("/{overrideId}", overrideHandler.GetOverride)
+			r.Patch("/{overrideId}", overrideHandler.UpdateOverride)
+			r.Delete("/{overrideId}", overrideHandler.DeleteOverride)
+		})
+
+		// Add-ons attached to tenants
+		r.Route("/tenants/{tenantId}/addons", func(r chi.Router) {
+			r.Get("/", addonHandler.AdminListTenantAddons)
+			r.Post("/", addonHandler.AdminAttachAddon)
+			r.Delete("/{addonId}", addonHandler.AdminDetachAddon)
+		})
 	})
 
-	// API routes (require ApiKeyAuth middleware to set project_id in context)
+	// API routes (auth.APIKey sets the principal in context, each route checks its scope)
 	// -------------------------
 	// @Summary Public API endpoints (scoped by API key)
 	// @Description API endpoints accessible with X-API-Key header. These endpoints operate within the project context derived from the API key.
-	// @Tags plans, features, planfeatures, tenantplans
+	// @Tags plans, features, planfeatures, addons, tenantplans, entitlements, usage, credits
 	// @Param X-API-Key header string true "API Key"
 	// -------------------------
 	r.Route("/api", func(r chi.Router) {
-		r.Get("/plans", planHandler.ListPlans)
-		r.Post("/plans", planHandler.CreatePlan)
-		r.Get("/plans/{planId}", planHandler.GetPlan)
-		r.Put("/plans/{planId}", planHandler.UpdatePlan)
+		r.Use(auth.APIKey(apiKeyService, tokenIssuer, clientIPs))
+		r.Use(rateLimiter.PerKey)
+
+		// Exchange API key for a short-lived access token
+		r.Post("/token", tokenHandler.IssueToken)
+
+		catalogRead := auth.RequireScope(auth.ScopeCatalogRead)
+		catalogWrite := auth.RequireScope(auth.ScopeCatalogWrite)
+
+		r.With(catalogRead).Get("/plans", planHandler.ListPlans)
+		r.With(catalogWrite).Post("/plans", planHandler.CreatePlan)
+		r.With(catalogRead).Get("/plans/{planId}", planHandler.GetPlan)
+		r.With(catalogWrite).Put("/plans/{planId}", planHandler.UpdatePlan)
 
 		// Features API scoped by API key
-		r.Get("/features", featureHandler.ListFeatures)
-		r.Post("/features", featureHandler.CreateFeature)
-		r.Get("/features/{featureId}", featureHandler.GetFeature)
-		r.Put("/features/{featureId}", featureHandler.UpdateFeature)
+		r.With(catalogRead).Get("/features", featureHandler.ListFeatures)
+		r.With(catalogWrite).Post("/features", featureHandler.CreateFeature)
+		r.With(catalogRead).Get("/features/{featureId}", featureHandler.GetFeature)
+		r.With(catalogWrite).Put("/features/{featureId}", featureHandler.UpdateFeature)
 
 		// PlanFeatures routes
 		r.Route("/plans/{planId}/features", func(r chi.Router) {
-			r.Get("/", planFeatureHandler.List)
-			r.Post("/", planFeatureHandler.Assign)
+			r.With(catalogRead).Get("/", planFeatureHandler.List)
+			r.With(catalogWrite).Post("/", planFeatureHandler.Assign)
+			r.With(catalogWrite).Patch("/{featureId}", planFeatureHandler.Update)
 		})
 
+		// Plan versions: editing features opens a draft, publishing freezes it
+		r.With(catalogRead).Get("/plans/{planId}/versions", planFeatureHandler.ListVersions)
+		r.With(catalogWrite).Post("/plans/{planId}/versions/publish", planFeatureHandler.PublishVersion)
+		r.With(catalogRead).Get("/plans/{planId}/versions/{versionId}", planFeatureHandler.GetVersion)
+
+		// Add-ons catalog
+		r.With(catalogRead).Get("/addons", addonHandler.ListAddons)
+		r.With(catalogWrite).Post("/addons", addonHandler.CreateAddon)
+		r.With(catalogRead).Get("/addons/{addonId}", addonHandler.GetAddon)
+		r.With(catalogWrite).Put("/addons/{addonId}", addonHandler.UpdateAddon)
+		r.With(catalogRead).Get("/addons/{addonId}/features", addonHandler.ListFeatures)
+		r.With(catalogWrite).Post("/addons/{addonId}/features", addonHandler.AssignFeature)
+
+		// Entitlements for many tenants at once
+		r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Post("/entitlements:batch", entitlementHandler.BatchEntitlements)
+
+		// Usage threshold alerts
+		r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/usage/alerts", usageHandler.ListAlerts)
+
 		// TenantPlans API: get effective plan and assign plan (scoped by API key)
-		r.Get("/tenants/{tenantId}/plan", tenantPlanHandler.GetTenantPlan)
-		r.Post("/tenants/{tenantId}/plan", tenantPlanHandler.AssignTenantPlan)
+		r.Route("/tenants/{tenantId}", func(r chi.Router) {
+			r.Use(rateLimiter.PerTenant("tenantId"))
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/plan", tenantPlanHandler.GetTenantPlan)
+			r.With(auth.RequireScope(auth.ScopeTenantsWrite)).Post("/plan", tenantPlanHandler.AssignTenantPlan)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/plan/changes", tenantPlanHandler.ListChanges)
+			r.With(auth.RequireScope(auth.ScopeTenantsWrite)).Post("/plan/changes", tenantPlanHandler.ScheduleChange)
+			r.With(auth.RequireScope(auth.ScopeTenantsWrite)).Delete("/plan/changes/{changeId}", tenantPlanHandler.CancelChange)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/entitlements", entitlementHandler.GetEntitlements)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/features/{featureCode}", entitlementHandler.CheckFeature)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/addons", addonHandler.ListTenantAddons)
+			r.With(auth.RequireScope(auth.ScopeTenantsWrite)).Post("/addons", addonHandler.AttachAddon)
+			r.With(auth.RequireScope(auth.ScopeTenantsWrite)).Delete("/addons/{addonId}", addonHandler.DetachAddon)
+			r.With(auth.RequireScope(auth.ScopeUsageWrite)).Post("/usage", usageHandler.RecordUsage)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/usage", usageHandler.ListUsage)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/usage/{featureCode}", usageHandler.GetUsage)
+			r.With(auth.RequireScope(auth.ScopeUsageWrite)).Post("/features/{featureCode}/consume", usageHandler.ConsumeUsage)
+			r.With(auth.RequireScope(auth.ScopeUsageWrite)).Post("/features/{featureCode}/reservations", usageHandler.ReserveUsage)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/reservations/{reservationId}", usageHandler.GetReservation)
+			r.With(auth.RequireScope(auth.ScopeUsageWrite)).Post("/reservations/{reservationId}/commit", usageHandler.CommitReservation)
+			r.With(auth.RequireScope(auth.ScopeUsageWrite)).Post("/reservations/{reservationId}/release", usageHandler.ReleaseReservation)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/credits", creditHandler.GetBalance)
+			r.With(auth.RequireScope(auth.ScopeEntitlementsRead)).Get("/credits/ledger", creditHandler.ListLedger)
+			r.With(auth.RequireScope(auth.ScopeTenantsWrite)).Post("/credits/grants", creditHandler.GrantCredits)
+			r.With(auth.RequireScope(auth.ScopeUsageWrite)).Post("/credits/debits", creditHandler.DebitCredits)
+		})
 	})
 
 	// -------------------------